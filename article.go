@@ -0,0 +1,150 @@
+package nntp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+type Article struct {
+	Number    uint64
+	MessageID string
+	Header    ArticleHeaders
+	Body      []byte
+}
+
+var (
+	ErrNoSuchArticle = errors.New("no such article")
+
+	ErrInvalidArticleLineReturned = errors.New("invalid article response line returned. Line must start with the article number and message-id")
+)
+
+// Article retrieves the head and body of the article identified by id, which is either a message-id or an article number
+// in the currently selected group.
+func (c *Client) Article(id string) (Article, error) {
+	article, raw, err := c.retrieve("ARTICLE", id, 220, true)
+	if err != nil {
+		return article, err
+	}
+
+	article.Header, article.Body = splitArticle(raw)
+
+	return article, nil
+}
+
+func (c *Client) Head(id string) (Article, error) {
+	article, raw, err := c.retrieve("HEAD", id, 221, true)
+	if err != nil {
+		return article, err
+	}
+
+	article.Header = ParseArticleHeaders(raw)
+
+	return article, nil
+}
+
+func (c *Client) Body(id string) (Article, error) {
+	article, raw, err := c.retrieve("BODY", id, 222, true)
+	if err != nil {
+		return article, err
+	}
+
+	article.Body = raw
+
+	return article, nil
+}
+
+// Stat checks whether the article identified by id exists without transferring it.
+func (c *Client) Stat(id string) (Article, error) {
+	article, _, err := c.retrieve("STAT", id, 223, false)
+
+	return article, err
+}
+
+func (c *Client) retrieve(cmd, id string, expectCode int, multiline bool) (article Article, raw []byte, err error) {
+	reqID, err := c.connection.Cmd("%s %s", cmd, id)
+	if err != nil {
+		return article, nil, err
+	}
+
+	c.connection.StartResponse(reqID)
+	defer c.connection.EndResponse(reqID)
+
+	article, raw, _, err = c.readArticleResponse(expectCode, multiline)
+
+	return article, raw, err
+}
+
+// readArticleResponse reads the response to an ARTICLE, HEAD, BODY or STAT command.
+// broken reports whether the error left the connection in an undefined state, so no further responses can be read.
+func (c *Client) readArticleResponse(expectCode int, multiline bool) (article Article, raw []byte, broken bool, err error) {
+	_, line, err := c.connection.ReadCodeLine(expectCode)
+	if err != nil {
+		var protoErr *textproto.Error
+
+		return article, nil, !errors.As(err, &protoErr), articleError(err)
+	}
+
+	// Always consume the whole response, even if the response line turns out to be invalid.
+	if multiline {
+		if raw, err = c.connection.ReadDotBytes(); err != nil {
+			return article, nil, true, err
+		}
+	}
+
+	if article, err = parseArticleLine(line); err != nil {
+		return article, nil, false, err
+	}
+
+	return article, raw, false, nil
+}
+
+func articleError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && (protoErr.Code == 423 || protoErr.Code == 430) {
+		return fmt.Errorf("%w: %s", ErrNoSuchArticle, protoErr.Error())
+	}
+
+	return err
+}
+
+// IsNoSuchArticle reports whether err signals that the server does not have the requested article.
+func IsNoSuchArticle(err error) bool {
+	return errors.Is(err, ErrNoSuchArticle)
+}
+
+func parseArticleLine(line string) (article Article, err error) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		return article, fmt.Errorf("%w: Got '%s'", ErrInvalidArticleLineReturned, line)
+	}
+
+	if article.Number, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return article, fmt.Errorf("failed to parse article number '%s': %w", parts[0], err)
+	}
+
+	article.MessageID = parts[1]
+
+	return article, nil
+}
+
+func splitArticle(raw []byte) (ArticleHeaders, []byte) {
+	var head, body []byte
+
+	switch {
+	case bytes.HasPrefix(raw, []byte("\n")):
+		body = raw[1:]
+	default:
+		idx := bytes.Index(raw, []byte("\n\n"))
+		if idx < 0 {
+			head = raw
+		} else {
+			head, body = raw[:idx+1], raw[idx+2:]
+		}
+	}
+
+	return ParseArticleHeaders(head), body
+}
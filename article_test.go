@@ -0,0 +1,90 @@
+package nntp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestClient_Article(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	conn.RecordPrintfLine(t, "220 3000234 <45223423@example.com>")
+	conn.RecordDotMessage(t, `Path: pathost!demo!whitehouse!not-for-mail
+From: "Demo User" <nobody@example.net>
+Newsgroups: misc.test
+Subject: I am just a test article
+Message-ID: <45223423@example.com>
+
+This is just a test article.
+.with a leading dot
+`)
+
+	article, err := client.Article("<45223423@example.com>")
+	require.NoError(t, err, "Failed to retrieve article")
+
+	assert.Equal(t, uint64(3000234), article.Number)
+	assert.Equal(t, "<45223423@example.com>", article.MessageID)
+	assert.Equal(t, "I am just a test article", article.Header.Get("Subject"))
+	assert.Equal(t, "misc.test", article.Header.Get("Newsgroups"))
+	assert.Equal(t, "This is just a test article.\n.with a leading dot\n", string(article.Body))
+}
+
+func TestClient_Head(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	conn.RecordPrintfLine(t, "221 3000234 <45223423@example.com>")
+	conn.RecordDotMessage(t, `From: "Demo User" <nobody@example.net>
+Subject: I am just a test article
+`)
+
+	article, err := client.Head("3000234")
+	require.NoError(t, err, "Failed to retrieve head")
+
+	assert.Equal(t, "I am just a test article", article.Header.Get("Subject"))
+	assert.Empty(t, article.Body)
+}
+
+func TestClient_Body(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	conn.RecordPrintfLine(t, "222 3000234 <45223423@example.com>")
+	conn.RecordDotMessage(t, "some body\n")
+
+	article, err := client.Body("<45223423@example.com>")
+	require.NoError(t, err, "Failed to retrieve body")
+
+	assert.Equal(t, "<45223423@example.com>", article.MessageID)
+	assert.Equal(t, 0, article.Header.Len())
+	assert.Equal(t, "some body\n", string(article.Body))
+}
+
+func TestClient_Stat(t *testing.T) {
+	t.Run("successful", func(t *testing.T) {
+		client, conn := getAuthenticatedClient(t)
+		conn.RecordPrintfLine(t, "223 3000234 <45223423@example.com>")
+
+		article, err := client.Stat("<45223423@example.com>")
+		require.NoError(t, err, "Failed to stat article")
+
+		assert.Equal(t, uint64(3000234), article.Number)
+	})
+
+	t.Run("no such article", func(t *testing.T) {
+		client, conn := getAuthenticatedClient(t)
+		conn.RecordPrintfLine(t, "430 No article with that message-id")
+
+		_, err := client.Stat("<45223423@example.com>")
+		assert.True(t, errors.Is(err, nntp.ErrNoSuchArticle), "Expected %v, got %v", nntp.ErrNoSuchArticle, err)
+		assert.True(t, nntp.IsNoSuchArticle(err))
+	})
+
+	t.Run("no such article number", func(t *testing.T) {
+		client, conn := getAuthenticatedClient(t)
+		conn.RecordPrintfLine(t, "423 No article with that number")
+
+		_, err := client.Stat("5")
+		assert.True(t, errors.Is(err, nntp.ErrNoSuchArticle), "Expected %v, got %v", nntp.ErrNoSuchArticle, err)
+	})
+}
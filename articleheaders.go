@@ -0,0 +1,376 @@
+package nntp
+
+import (
+	"bytes"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderField is a single field of an article header.
+type HeaderField struct {
+	// Name as written, like "Message-ID"
+	Name string
+	// Unfolded value without surrounding whitespace
+	Value string
+	// Complete field including name & folding, without the final line ending
+	Raw string
+}
+
+// ArticleHeaders are the header fields of an article. Unlike textproto.MIMEHeader it keeps the order of all fields &
+// their raw folding. Lookups are case-insensitive & fields may occur multiple times.
+type ArticleHeaders struct {
+	fields []HeaderField
+}
+
+// ParseArticleHeaders parses a header block. The blank line terminating it is optional.
+// Malformed lines without colon get appended to the previous field instead of rejecting the whole header, as they are
+// usually the result of broken folding.
+func ParseArticleHeaders(raw []byte) ArticleHeaders {
+	var headers ArticleHeaders
+
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			// End of the header block
+			if len(headers.fields) > 0 {
+				break
+			}
+
+			continue
+		}
+
+		idx := bytes.IndexByte(line, ':')
+		isContinuation := line[0] == ' ' || line[0] == '\t' || idx <= 0 || bytes.ContainsAny(line[:idx], " \t")
+
+		if isContinuation {
+			if len(headers.fields) > 0 {
+				last := &headers.fields[len(headers.fields)-1]
+				last.Raw += "\r\n" + string(line)
+				last.Value = strings.TrimSpace(last.Value + " " + strings.TrimSpace(string(line)))
+			}
+
+			continue
+		}
+
+		headers.fields = append(headers.fields, HeaderField{
+			Name:  string(line[:idx]),
+			Value: strings.TrimSpace(string(line[idx+1:])),
+			Raw:   string(line),
+		})
+	}
+
+	return headers
+}
+
+// Add appends a field.
+func (h *ArticleHeaders) Add(name, value string) {
+	h.fields = append(h.fields, HeaderField{
+		Name:  name,
+		Value: value,
+		Raw:   name + ": " + value,
+	})
+}
+
+// Len returns the number of fields.
+func (h ArticleHeaders) Len() int {
+	return len(h.fields)
+}
+
+// Fields returns all fields in order.
+func (h ArticleHeaders) Fields() []HeaderField {
+	return append([]HeaderField(nil), h.fields...)
+}
+
+// Get returns the value of the first field with the given name, or an empty string.
+func (h ArticleHeaders) Get(name string) string {
+	for idx := range h.fields {
+		if strings.EqualFold(h.fields[idx].Name, name) {
+			return h.fields[idx].Value
+		}
+	}
+
+	return ""
+}
+
+// Has reports whether at least one field with the given name exists.
+func (h ArticleHeaders) Has(name string) bool {
+	for idx := range h.fields {
+		if strings.EqualFold(h.fields[idx].Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Values returns the values of all fields with the given name in order.
+func (h ArticleHeaders) Values(name string) []string {
+	var values []string
+
+	for idx := range h.fields {
+		if strings.EqualFold(h.fields[idx].Name, name) {
+			values = append(values, h.fields[idx].Value)
+		}
+	}
+
+	return values
+}
+
+// Bytes returns the header block as it was received, with CRLF line endings & without the terminating blank line.
+func (h ArticleHeaders) Bytes() []byte {
+	var b bytes.Buffer
+
+	for idx := range h.fields {
+		b.WriteString(h.fields[idx].Raw)
+		b.WriteString("\r\n")
+	}
+
+	return b.Bytes()
+}
+
+// MIMEHeader converts the fields to a textproto.MIMEHeader, for use with mime/multipart & friends.
+func (h ArticleHeaders) MIMEHeader() textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader, len(h.fields))
+	for idx := range h.fields {
+		header.Add(h.fields[idx].Name, h.fields[idx].Value)
+	}
+
+	return header
+}
+
+func splitList(value, separators string) []string {
+	var list []string
+
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// Newsgroups returns the groups the article is posted to.
+func (h ArticleHeaders) Newsgroups() []string {
+	return splitList(h.Get("Newsgroups"), ", \t")
+}
+
+// FollowupTo returns the groups followups should be posted to. "poster" means replies should be sent by email.
+func (h ArticleHeaders) FollowupTo() []string {
+	return splitList(h.Get("Followup-To"), ", \t")
+}
+
+// Distribution returns the distributions the article is restricted to.
+func (h ArticleHeaders) Distribution() []string {
+	return splitList(h.Get("Distribution"), ", \t")
+}
+
+// Path returns the path identities of the servers the article passed, the most recent first.
+// The last entry is usually "not-for-mail". Diagnostic entries like ".POSTED" are kept, empty entries caused by "!!"
+// are dropped.
+func (h ArticleHeaders) Path() []string {
+	return splitList(h.Get("Path"), "! \t")
+}
+
+// Xref parses the Xref field.
+func (h ArticleHeaders) Xref() (Xref, error) {
+	return ParseXref(h.Get("Xref"))
+}
+
+// Expires returns the date the article expires. ok is false if the field is missing or invalid.
+func (h ArticleHeaders) Expires() (expires time.Time, ok bool) {
+	value := h.Get("Expires")
+	if value == "" {
+		return expires, false
+	}
+
+	expires, err := ParseDate(value)
+
+	return expires, err == nil
+}
+
+// Control is the parsed value of a Control field, like "cancel <id@example.com>".
+type Control struct {
+	// Lowercase verb, like "cancel", "newgroup" or "rmgroup"
+	Verb      string
+	Arguments []string
+}
+
+// Control parses the Control field. ok is false if the article is no control message.
+func (h ArticleHeaders) Control() (control Control, ok bool) {
+	fields := strings.Fields(h.Get("Control"))
+	if len(fields) == 0 {
+		return control, false
+	}
+
+	control.Verb = strings.ToLower(fields[0])
+	control.Arguments = fields[1:]
+
+	return control, true
+}
+
+// Supersedes returns the message-id of the article this one replaces.
+func (h ArticleHeaders) Supersedes() (MessageID, bool) {
+	ids := ParseReferences(h.Get("Supersedes"))
+	if len(ids) == 0 {
+		return "", false
+	}
+
+	return ids[0], true
+}
+
+func (h ArticleHeaders) Organization() string {
+	return h.Get("Organization")
+}
+
+// InjectionInfo is the parsed value of an Injection-Info field according to RFC 5536 section 3.2.8, like
+// `news.example.com; posting-host="host.example.com"; mail-complaints-to="abuse@example.com"`.
+type InjectionInfo struct {
+	// Path identity of the injecting server
+	Server string
+	// Parameters keyed by their lowercase name, without quotes
+	Parameters map[string]string
+}
+
+// InjectionInfo parses the Injection-Info field. ok is false if the field is missing.
+func (h ArticleHeaders) InjectionInfo() (info InjectionInfo, ok bool) {
+	value := h.Get("Injection-Info")
+	if value == "" {
+		return info, false
+	}
+
+	parts := splitParameters(value)
+	info.Server = strings.TrimSpace(parts[0])
+	info.Parameters = map[string]string{}
+
+	for _, part := range parts[1:] {
+		idx := strings.IndexByte(part, '=')
+		if idx < 0 {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimSpace(part[:idx]))
+		paramValue := strings.TrimSpace(part[idx+1:])
+
+		if unquoted, err := strconv.Unquote(paramValue); err == nil && strings.HasPrefix(paramValue, `"`) {
+			paramValue = unquoted
+		} else {
+			paramValue = strings.Trim(paramValue, `"`)
+		}
+
+		info.Parameters[name] = paramValue
+	}
+
+	return info, true
+}
+
+// splitParameters splits at semicolons outside of quotes.
+func splitParameters(value string) []string {
+	var (
+		parts  []string
+		start  int
+		quoted bool
+	)
+
+	for idx := 0; idx < len(value); idx++ {
+		switch value[idx] {
+		case '\\':
+			idx++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, value[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+
+	return append(parts, value[start:])
+}
+
+// CancelLock is a single element of a Cancel-Lock field according to RFC 8315, like "sha256:s/pmK/3grrz++29ce2/mQydz".
+type CancelLock struct {
+	// Lowercase hash algorithm, like "sha1" or "sha256"
+	Scheme string
+	// Base64 encoded hash
+	Value string
+}
+
+// CancelLocks parses the Cancel-Lock field. Elements without scheme are skipped.
+func (h ArticleHeaders) CancelLocks() []CancelLock {
+	var locks []CancelLock
+
+	for _, element := range strings.Fields(h.Get("Cancel-Lock")) {
+		idx := strings.IndexByte(element, ':')
+		if idx <= 0 {
+			continue
+		}
+
+		locks = append(locks, CancelLock{
+			Scheme: strings.ToLower(element[:idx]),
+			Value:  element[idx+1:],
+		})
+	}
+
+	return locks
+}
+
+// Header fields which have a dedicated field in Header
+var overviewFields = []string{"Subject", "From", "Date", "Message-ID", "References", "Lines", "Bytes"}
+
+// Header converts the fields to the overview representation. Unparsable dates & line counts are left empty.
+// All fields which are not part of the default overview format are stored in Additional, using the first value of
+// fields occurring multiple times.
+func (h ArticleHeaders) Header() Header {
+	header := Header{
+		Subject:    h.Get("Subject"),
+		Author:     h.Get("From"),
+		MessageID:  h.Get("Message-ID"),
+		References: h.Get("References"),
+	}
+
+	if date, err := ParseDate(h.Get("Date")); err == nil {
+		header.Date = date
+	}
+
+	if lines, err := strconv.ParseUint(h.Get("Lines"), 10, 64); err == nil {
+		header.Lines = lines
+	}
+
+	if size, err := strconv.ParseUint(h.Get("Bytes"), 10, 64); err == nil {
+		header.Bytes = size
+	}
+
+	for idx := range h.fields {
+		field := h.fields[idx]
+		if isOverviewField(field.Name) {
+			continue
+		}
+
+		if header.Additional == nil {
+			header.Additional = map[string]string{}
+		}
+
+		key := textproto.CanonicalMIMEHeaderKey(field.Name)
+		if _, exists := header.Additional[key]; !exists {
+			header.Additional[key] = field.Value
+		}
+	}
+
+	return header
+}
+
+func isOverviewField(name string) bool {
+	for _, field := range overviewFields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package nntp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+const testArticleHeaders = "Path: pathost!demo!.POSTED!not-for-mail\r\n" +
+	"From: \"Demo User\" <nobody@example.net>\r\n" +
+	"Newsgroups: misc.test,\r\n" +
+	"\talt.test\r\n" +
+	"Followup-To: poster\r\n" +
+	"Subject: I am just a test article\r\n" +
+	"Date: 6 Oct 1998 04:38:40 -0500\r\n" +
+	"Expires: 6 Nov 1998 04:38:40 -0500\r\n" +
+	"Organization: An Example Net\r\n" +
+	"Message-ID: <45223423@example.com>\r\n" +
+	"Supersedes: <12345@example.com>\r\n" +
+	"Distribution: de, fi\r\n" +
+	"Xref: news.example.com misc.test:3000234 alt.test:42\r\n" +
+	"Injection-Info: news.example.com; posting-host=\"host.example.com\";\r\n" +
+	"  logging-data=\"12345\"; mail-complaints-to=\"abuse@example.com\"\r\n" +
+	"Cancel-Lock: sha1:bNXHc6ohSmeHaRHHW56BIWZJt+4= SHA256:s/pmK/3grrz++29ce2/mQydzJuc7iqHn1nqcJiQTPMc=\r\n" +
+	"Control: cancel <12345@example.com>\r\n" +
+	"X-Comment: first\r\n" +
+	"x-comment: second\r\n" +
+	"Lines: 17\r\n" +
+	"\r\n"
+
+func TestParseArticleHeaders(t *testing.T) {
+	h := nntp.ParseArticleHeaders([]byte(testArticleHeaders))
+
+	assert.Equal(t, 18, h.Len())
+	assert.Equal(t, "I am just a test article", h.Get("subject"))
+	assert.Equal(t, []string{"first", "second"}, h.Values("X-Comment"))
+	assert.True(t, h.Has("LINES"))
+	assert.False(t, h.Has("References"))
+
+	fields := h.Fields()
+	assert.Equal(t, nntp.HeaderField{
+		Name:  "Newsgroups",
+		Value: "misc.test, alt.test",
+		Raw:   "Newsgroups: misc.test,\r\n\talt.test",
+	}, fields[2])
+
+	// The raw header block is preserved
+	assert.Equal(t, testArticleHeaders[:len(testArticleHeaders)-2], string(h.Bytes()))
+}
+
+func TestParseArticleHeaders_Malformed(t *testing.T) {
+	h := nntp.ParseArticleHeaders([]byte("continuation without field\nSubject: broken\nfolding\nFrom: someone\n\nBody: not a header\n"))
+
+	assert.Equal(t, 2, h.Len())
+	assert.Equal(t, "broken folding", h.Get("Subject"))
+	assert.Equal(t, "someone", h.Get("From"))
+	assert.False(t, h.Has("Body"))
+}
+
+func TestArticleHeaders_Accessors(t *testing.T) {
+	h := nntp.ParseArticleHeaders([]byte(testArticleHeaders))
+
+	assert.Equal(t, []string{"misc.test", "alt.test"}, h.Newsgroups())
+	assert.Equal(t, []string{"poster"}, h.FollowupTo())
+	assert.Equal(t, []string{"pathost", "demo", ".POSTED", "not-for-mail"}, h.Path())
+	assert.Equal(t, []string{"de", "fi"}, h.Distribution())
+	assert.Equal(t, "An Example Net", h.Organization())
+
+	xref, err := h.Xref()
+	require.NoError(t, err, "Failed to parse xref")
+	assert.Equal(t, nntp.Xref{
+		Server:  "news.example.com",
+		Entries: []nntp.XrefEntry{{Group: "misc.test", Number: 3000234}, {Group: "alt.test", Number: 42}},
+	}, xref)
+
+	expires, ok := h.Expires()
+	require.True(t, ok)
+	assert.True(t, expires.Equal(time.Date(1998, 11, 6, 9, 38, 40, 0, time.UTC)))
+
+	control, ok := h.Control()
+	require.True(t, ok)
+	assert.Equal(t, nntp.Control{Verb: "cancel", Arguments: []string{"<12345@example.com>"}}, control)
+
+	supersedes, ok := h.Supersedes()
+	require.True(t, ok)
+	assert.Equal(t, nntp.MessageID("<12345@example.com>"), supersedes)
+
+	info, ok := h.InjectionInfo()
+	require.True(t, ok)
+	assert.Equal(t, nntp.InjectionInfo{
+		Server: "news.example.com",
+		Parameters: map[string]string{
+			"posting-host":       "host.example.com",
+			"logging-data":       "12345",
+			"mail-complaints-to": "abuse@example.com",
+		},
+	}, info)
+
+	assert.Equal(t, []nntp.CancelLock{
+		{Scheme: "sha1", Value: "bNXHc6ohSmeHaRHHW56BIWZJt+4="},
+		{Scheme: "sha256", Value: "s/pmK/3grrz++29ce2/mQydzJuc7iqHn1nqcJiQTPMc="},
+	}, h.CancelLocks())
+
+	var empty nntp.ArticleHeaders
+
+	_, ok = empty.Control()
+	assert.False(t, ok)
+	_, ok = empty.Expires()
+	assert.False(t, ok)
+	_, ok = empty.InjectionInfo()
+	assert.False(t, ok)
+	assert.Nil(t, empty.Newsgroups())
+}
+
+func TestArticleHeaders_Header(t *testing.T) {
+	h := nntp.ParseArticleHeaders([]byte(testArticleHeaders))
+
+	header := h.Header()
+	assert.Equal(t, "I am just a test article", header.Subject)
+	assert.Equal(t, `"Demo User" <nobody@example.net>`, header.Author)
+	assert.Equal(t, "<45223423@example.com>", header.MessageID)
+	assert.True(t, header.Date.Equal(time.Date(1998, 10, 6, 9, 38, 40, 0, time.UTC)))
+	assert.Equal(t, uint64(17), header.Lines)
+	assert.Equal(t, "news.example.com misc.test:3000234 alt.test:42", header.Additional["Xref"])
+	assert.Equal(t, "first", header.Additional["X-Comment"])
+	assert.NotContains(t, header.Additional, "Subject")
+}
+
+func TestArticleHeaders_MIMEHeader(t *testing.T) {
+	var h nntp.ArticleHeaders
+	h.Add("content-type", "text/plain")
+	h.Add("X-Test", "a")
+	h.Add("x-test", "b")
+
+	mimeHeader := h.MIMEHeader()
+	assert.Equal(t, "text/plain", mimeHeader.Get("Content-Type"))
+	assert.Equal(t, []string{"a", "b"}, mimeHeader.Values("X-Test"))
+	assert.Equal(t, "content-type: text/plain\r\nX-Test: a\r\nx-test: b\r\n", string(h.Bytes()))
+}
@@ -0,0 +1,375 @@
+// Package attachment extracts binary files embedded in article bodies.
+// It supports yEnc, uuencode (including "begin-base64" blocks) & MIME attachments encoded as base64 or
+// quoted-printable.
+package attachment
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+type Encoding string
+
+const (
+	EncodingNone            Encoding = ""
+	EncodingYEnc            Encoding = "yenc"
+	EncodingUU              Encoding = "uuencode"
+	EncodingUUBase64        Encoding = "uuencode-base64"
+	EncodingBase64          Encoding = "base64"
+	EncodingQuotedPrintable Encoding = "quoted-printable"
+)
+
+// File is a single file embedded in an article body. Reading Data returns the decoded content.
+type File struct {
+	Name     string
+	Encoding Encoding
+	// Only set for MIME attachments
+	ContentType string
+	Data        io.Reader
+}
+
+var (
+	ErrNoParts            = errors.New("no articles given")
+	ErrMissingParts       = errors.New("missing parts")
+	ErrMissingPartCounter = errors.New("subject does not contain a part counter")
+
+	ErrMissingBoundary = errors.New("multipart message without boundary")
+)
+
+var uuBeginRegexp = regexp.MustCompile(`^begin(-base64)? ([0-7]{3,4}) (.+)$`)
+
+// Detect returns the encoding of the first file embedded in the article.
+func Detect(article nntp.Article) Encoding {
+	files, err := Extract(article)
+	if err != nil || len(files) == 0 {
+		return EncodingNone
+	}
+
+	return files[0].Encoding
+}
+
+// Extract returns all files embedded in the article. MIME messages are recognized by their Content-Type header, all
+// other bodies are searched for yEnc & uuencoded blocks.
+func Extract(article nntp.Article) ([]File, error) {
+	if mediaType, params, ok := mimeType(article.Header.MIMEHeader()); ok {
+		return extractMIME(article.Header.MIMEHeader(), mediaType, params, bytes.NewReader(article.Body))
+	}
+
+	return extractText(article.Body)
+}
+
+// Segment is a single article of a post split over several articles.
+type Segment struct {
+	// Subject must contain the part counter, like "(2/5)"
+	Subject string
+	Body    []byte
+}
+
+// ExtractMultiArticle joins the bodies of a post split over several articles & extracts the embedded files.
+// The segments are ordered by the part counter of their subject. This is required for uuencoded posts, where only the
+// first article contains the "begin" & only the last the "end" line.
+// Multi part yEnc posts contain complete yEnc blocks per article & should be decoded per article instead.
+func ExtractMultiArticle(segments []Segment) ([]File, error) {
+	if len(segments) == 0 {
+		return nil, ErrNoParts
+	}
+
+	type numbered struct {
+		part nntp.SubjectPart
+		body []byte
+	}
+
+	parts := make([]numbered, len(segments))
+
+	for idx := range segments {
+		part, ok := nntp.ParseSubjectPart(segments[idx].Subject)
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrMissingPartCounter, segments[idx].Subject)
+		}
+
+		parts[idx] = numbered{part: part, body: segments[idx].Body}
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].part.Number < parts[j].part.Number
+	})
+
+	total := parts[len(parts)-1].part.Total
+
+	var missing []int
+
+	// Some posters number a text only introduction with 0
+	expected := 1
+	for _, p := range parts {
+		if p.part.Number < expected {
+			continue
+		}
+
+		for ; expected < p.part.Number; expected++ {
+			missing = append(missing, expected)
+		}
+
+		expected++
+	}
+
+	for ; expected <= total; expected++ {
+		missing = append(missing, expected)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrMissingParts, missing)
+	}
+
+	var (
+		body bytes.Buffer
+		last = -1
+	)
+
+	for _, p := range parts {
+		if p.part.Number == last {
+			continue
+		}
+
+		last = p.part.Number
+
+		body.Write(p.body)
+
+		if n := len(p.body); n > 0 && p.body[n-1] != '\n' {
+			body.WriteByte('\n')
+		}
+	}
+
+	return extractText(body.Bytes())
+}
+
+func mimeType(header textproto.MIMEHeader) (mediaType string, params map[string]string, ok bool) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		return "", nil, false
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", nil, false
+	}
+
+	return mediaType, params, true
+}
+
+func extractMIME(header textproto.MIMEHeader, mediaType string, params map[string]string, body io.Reader) ([]File, error) {
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return mimeFiles(header, mediaType, body)
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, ErrMissingBoundary
+	}
+
+	var files []File
+
+	r := multipart.NewReader(body, boundary)
+
+	for {
+		// NextPart would decode quoted-printable parts transparently & hide their encoding
+		part, err := r.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+
+		if err != nil {
+			return files, fmt.Errorf("failed to read MIME part: %w", err)
+		}
+
+		partType, partParams, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			// RFC 2045: Parts without (valid) content type are plain text
+			partType, partParams = "text/plain", nil
+		}
+
+		partFiles, err := extractMIME(part.Header, partType, partParams, part)
+		if err != nil {
+			return files, err
+		}
+
+		files = append(files, partFiles...)
+	}
+}
+
+// mimeFiles decodes a single, non multipart MIME entity. Text without file name is part of the message, but may
+// contain yEnc or uuencoded blocks itself.
+func mimeFiles(header textproto.MIMEHeader, mediaType string, body io.Reader) ([]File, error) {
+	name := fileName(header)
+
+	file := File{
+		Name:        name,
+		ContentType: mediaType,
+	}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))); encoding {
+	case "base64":
+		file.Encoding = EncodingBase64
+		body = base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: body})
+	case "quoted-printable":
+		file.Encoding = EncodingQuotedPrintable
+		body = quotedprintable.NewReader(body)
+	}
+
+	// The parts of a multipart reader can only be read in order, so the content has to be buffered.
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode MIME part '%s': %w", name, err)
+	}
+
+	if name == "" && strings.HasPrefix(mediaType, "text/") {
+		return extractText(data)
+	}
+
+	file.Data = bytes.NewReader(data)
+
+	return []File{file}, nil
+}
+
+func fileName(header textproto.MIMEHeader) string {
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && params["name"] != "" {
+		return params["name"]
+	}
+
+	return ""
+}
+
+// extractText searches a plain text body for yEnc & uuencoded blocks.
+func extractText(body []byte) ([]File, error) {
+	var files []File
+
+	lines := splitLines(body)
+
+	for idx := 0; idx < len(lines); idx++ {
+		line := lines[idx]
+
+		if bytes.HasPrefix(line, []byte("=ybegin ")) {
+			end := idx + 1
+			for end < len(lines) && !bytes.HasPrefix(lines[end], []byte("=yend")) {
+				end++
+			}
+
+			if end < len(lines) {
+				end++
+			}
+
+			file, err := yEncFile(lines[idx:end])
+			if err != nil {
+				return files, err
+			}
+
+			files = append(files, file)
+			idx = end - 1
+
+			continue
+		}
+
+		matches := uuBeginRegexp.FindSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		base64Block := len(matches[1]) > 0
+
+		terminator := []byte("end")
+		if base64Block {
+			terminator = []byte("====")
+		}
+
+		end := idx + 1
+		for end < len(lines) && !bytes.Equal(bytes.TrimRight(lines[end], " \r"), terminator) {
+			end++
+		}
+
+		file := File{
+			Name:     string(bytes.TrimRight(matches[3], " \r")),
+			Encoding: EncodingUU,
+			Data:     newUUDecoder(lines[idx+1 : end]),
+		}
+
+		if base64Block {
+			file.Encoding = EncodingUUBase64
+			file.Data = base64.NewDecoder(base64.StdEncoding, bytes.NewReader(bytes.Join(lines[idx+1:end], nil)))
+		}
+
+		files = append(files, file)
+		idx = end
+	}
+
+	return files, nil
+}
+
+func yEncFile(lines [][]byte) (File, error) {
+	d := yenc.NewDecoder(bytes.NewReader(bytes.Join(lines, []byte("\n"))))
+
+	header, err := d.Header()
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{
+		Name:     header.Name,
+		Encoding: EncodingYEnc,
+		Data:     d,
+	}, nil
+}
+
+// splitLines splits the body into lines without line endings.
+func splitLines(body []byte) [][]byte {
+	body = bytes.TrimSuffix(body, []byte("\n"))
+	if len(body) == 0 {
+		return nil
+	}
+
+	lines := bytes.Split(body, []byte("\n"))
+	for idx := range lines {
+		lines[idx] = bytes.TrimSuffix(lines[idx], []byte("\r"))
+	}
+
+	return lines
+}
+
+// newlineStripper removes line endings, which the base64 decoder doesn't accept in all positions.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s *newlineStripper) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+
+		kept := 0
+		for _, c := range p[:n] {
+			if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+				p[kept] = c
+				kept++
+			}
+		}
+
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package attachment_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/attachment"
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+func TestExtract_YEnc(t *testing.T) {
+	data := testData(300)
+
+	part, err := yenc.NewEncoder(bytes.NewReader(data), "file.bin", int64(len(data))).Next()
+	require.NoError(t, err, "Failed to encode")
+
+	article := nntp.Article{
+		Header: nntp.ParseArticleHeaders([]byte("Content-Type: text/plain; charset=ISO-8859-1\n")),
+		Body:   append([]byte("Some introduction\r\n"), part.Body...),
+	}
+
+	files, err := attachment.Extract(article)
+	require.NoError(t, err, "Failed to extract files")
+	require.Len(t, files, 1)
+
+	assert.Equal(t, "file.bin", files[0].Name)
+	assert.Equal(t, attachment.EncodingYEnc, files[0].Encoding)
+
+	decoded, err := ioutil.ReadAll(files[0].Data)
+	require.NoError(t, err, "Failed to decode file")
+	assert.Equal(t, data, decoded)
+
+	assert.Equal(t, attachment.EncodingYEnc, attachment.Detect(article))
+}
+
+func TestExtract_MIME(t *testing.T) {
+	data := testData(300)
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	body := "This is a multi-part message in MIME format.\r\n" +
+		"--boundary\r\n" +
+		"Content-Type: text/plain; charset=us-ascii\r\n" +
+		"\r\n" +
+		"Some text\r\n" +
+		"--boundary\r\n" +
+		"Content-Type: application/octet-stream; name=\"ignored.bin\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"file.bin\"\r\n" +
+		"\r\n" +
+		encoded[:76] + "\r\n" + encoded[76:] + "\r\n" +
+		"--boundary\r\n" +
+		"Content-Type: text/plain; name=\"notes.txt\"\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Caf=C3=A9 =\r\nnotes\r\n" +
+		"--boundary--\r\n"
+
+	article := nntp.Article{
+		Header: nntp.ParseArticleHeaders([]byte("Mime-Version: 1.0\nContent-Type: multipart/mixed; boundary=\"boundary\"\n")),
+		Body:   []byte(body),
+	}
+
+	files, err := attachment.Extract(article)
+	require.NoError(t, err, "Failed to extract files")
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "file.bin", files[0].Name)
+	assert.Equal(t, "application/octet-stream", files[0].ContentType)
+	assert.Equal(t, attachment.EncodingBase64, files[0].Encoding)
+
+	decoded, err := ioutil.ReadAll(files[0].Data)
+	require.NoError(t, err, "Failed to decode file")
+	assert.Equal(t, data, decoded)
+
+	assert.Equal(t, "notes.txt", files[1].Name)
+	assert.Equal(t, attachment.EncodingQuotedPrintable, files[1].Encoding)
+
+	decoded, err = ioutil.ReadAll(files[1].Data)
+	require.NoError(t, err, "Failed to decode file")
+	assert.Equal(t, "Café notes", string(decoded))
+
+	assert.Equal(t, attachment.EncodingBase64, attachment.Detect(article))
+}
+
+func TestDetect_None(t *testing.T) {
+	assert.Equal(t, attachment.EncodingNone, attachment.Detect(nntp.Article{Body: []byte("just some text\n")}))
+}
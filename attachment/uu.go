@@ -0,0 +1,93 @@
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrInvalidUULine = errors.New("invalid uuencoded line")
+
+// uuDecoder decodes the lines between a "begin" & "end" line of a uuencoded block.
+type uuDecoder struct {
+	lines   [][]byte
+	pending []byte
+	buf     []byte
+	err     error
+}
+
+func newUUDecoder(lines [][]byte) *uuDecoder {
+	return &uuDecoder{lines: lines}
+}
+
+func (d *uuDecoder) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 && d.err == nil {
+		if len(d.lines) == 0 {
+			d.err = io.EOF
+			break
+		}
+
+		line := d.lines[0]
+		d.lines = d.lines[1:]
+
+		d.buf, d.err = decodeUULine(d.buf[:0], line)
+		d.pending = d.buf
+	}
+
+	if len(d.pending) > 0 {
+		n := copy(p, d.pending)
+		d.pending = d.pending[n:]
+
+		return n, nil
+	}
+
+	return 0, d.err
+}
+
+func uuValue(c byte) byte {
+	// Both ' ' & '`' represent 0
+	return (c - ' ') & 0x3f
+}
+
+// decodeUULine appends the decoded data of a single line to dst.
+// Some transports strip trailing spaces, so missing characters at the end of a line are treated as zero.
+func decodeUULine(dst, line []byte) ([]byte, error) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return dst, nil
+	}
+
+	n := int(uuValue(line[0]))
+	if n == 0 {
+		return dst, nil
+	}
+
+	data := line[1:]
+
+	// Every 3 decoded bytes are represented by 4 characters
+	required := (n + 2) / 3 * 4
+	if len(data) < required {
+		if required-len(data) > 3 {
+			return dst, fmt.Errorf("%w: announced %d bytes, but only %d characters given", ErrInvalidUULine, n, len(data))
+		}
+
+		data = append(append([]byte(nil), data...), bytes.Repeat([]byte{' '}, required-len(data))...)
+	}
+
+	for i := 0; n > 0; i += 4 {
+		a, b, c, d := uuValue(data[i]), uuValue(data[i+1]), uuValue(data[i+2]), uuValue(data[i+3])
+
+		decoded := [3]byte{a<<2 | b>>4, b<<4 | c>>2, c<<6 | d}
+
+		take := 3
+		if n < take {
+			take = n
+		}
+
+		dst = append(dst, decoded[:take]...)
+		n -= take
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,132 @@
+package attachment_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/attachment"
+)
+
+func uuChar(v byte) byte {
+	if v == 0 {
+		return '`'
+	}
+
+	return v + ' '
+}
+
+// uuEncode returns the uuencoded lines of data, without begin & end lines.
+func uuEncode(data []byte) []string {
+	var lines []string
+
+	for len(data) > 0 {
+		n := 45
+		if len(data) < n {
+			n = len(data)
+		}
+
+		chunk := make([]byte, (n+2)/3*3)
+		copy(chunk, data[:n])
+		data = data[n:]
+
+		b := []byte{uuChar(byte(n))}
+		for i := 0; i < len(chunk); i += 3 {
+			b = append(b,
+				uuChar(chunk[i]>>2),
+				uuChar((chunk[i]<<4|chunk[i+1]>>4)&0x3f),
+				uuChar((chunk[i+1]<<2|chunk[i+2]>>6)&0x3f),
+				uuChar(chunk[i+2]&0x3f),
+			)
+		}
+
+		lines = append(lines, string(b))
+	}
+
+	return append(lines, "`")
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	return data
+}
+
+func TestExtract_UU(t *testing.T) {
+	data := testData(200)
+
+	body := "Some text before\n" +
+		"begin 644 some file.bin\n" +
+		strings.Join(uuEncode(data), "\n") + "\n" +
+		"end\n" +
+		"Some text after\n"
+
+	files, err := attachment.Extract(nntp.Article{Body: []byte(body)})
+	require.NoError(t, err, "Failed to extract files")
+	require.Len(t, files, 1)
+
+	assert.Equal(t, "some file.bin", files[0].Name)
+	assert.Equal(t, attachment.EncodingUU, files[0].Encoding)
+
+	decoded, err := ioutil.ReadAll(files[0].Data)
+	require.NoError(t, err, "Failed to decode file")
+	assert.Equal(t, data, decoded)
+
+	assert.Equal(t, attachment.EncodingUU, attachment.Detect(nntp.Article{Body: []byte(body)}))
+}
+
+func TestExtract_UU_StrippedTrailingSpaces(t *testing.T) {
+	// 'Cat' encodes to "#0V%T", the space encoded last group of "Ca" gets stripped by some transports
+	body := "begin 644 cat.txt\n\"0V$\n`\nend\n"
+
+	files, err := attachment.Extract(nntp.Article{Body: []byte(body)})
+	require.NoError(t, err, "Failed to extract files")
+	require.Len(t, files, 1)
+
+	decoded, err := ioutil.ReadAll(files[0].Data)
+	require.NoError(t, err, "Failed to decode file")
+	assert.Equal(t, "Ca", string(decoded))
+}
+
+func TestExtractMultiArticle(t *testing.T) {
+	data := testData(500)
+	lines := uuEncode(data)
+
+	segments := []attachment.Segment{
+		{
+			Subject: "file.bin (3/3)",
+			Body:    []byte(strings.Join(lines[8:], "\n") + "\nend\n"),
+		},
+		{
+			Subject: "file.bin (1/3)",
+			Body:    []byte("begin 600 file.bin\n" + strings.Join(lines[:4], "\n") + "\n"),
+		},
+		{
+			Subject: "file.bin (2/3)",
+			Body:    []byte(strings.Join(lines[4:8], "\n")),
+		},
+	}
+
+	files, err := attachment.ExtractMultiArticle(segments)
+	require.NoError(t, err, "Failed to extract files")
+	require.Len(t, files, 1)
+
+	assert.Equal(t, "file.bin", files[0].Name)
+
+	decoded, err := ioutil.ReadAll(files[0].Data)
+	require.NoError(t, err, "Failed to decode file")
+	assert.Equal(t, data, decoded)
+
+	t.Run("missing part", func(t *testing.T) {
+		_, err := attachment.ExtractMultiArticle([]attachment.Segment{segments[0], segments[2]})
+		assert.EqualError(t, err, fmt.Sprintf("%v: [1]", attachment.ErrMissingParts))
+	})
+}
@@ -0,0 +1,103 @@
+package nntp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Author is the parsed From header of an article.
+type Author struct {
+	// Display name without quotes, like "Joe Bloggs". Empty if the header only contains an address.
+	Name string
+	// Address as written, like "bloggs@nowhere.example". Empty if the header does not contain an address.
+	Address string
+	// Normalized identity of the poster: The lowercase address with common obfuscations like " at " & "[dot]" removed,
+	// or the lowercase name if there is no address. Posters with the same key are considered the same.
+	Key string
+}
+
+var (
+	obfuscatedAtRegexp  = regexp.MustCompile(`(?i)\s*[\[({]\s*at\s*[\])}]\s*|\s+at\s+`)
+	obfuscatedDotRegexp = regexp.MustCompile(`(?i)\s*[\[({]\s*dot\s*[\])}]\s*|\s+dot\s+`)
+)
+
+// ParseAuthor parses the value of a From header. Unlike net/mail it never fails, as lots of Usenet From headers are
+// not valid RFC 5322 addresses. The following forms are recognized:
+//
+//	"Name" <address>
+//	Name <address>
+//	address (Name)
+//	address
+//	Name
+//
+// Obfuscated addresses like "joe at example dot com" are recognized if they don't contain anything else.
+func ParseAuthor(from string) Author {
+	from = strings.TrimSpace(from)
+
+	var author Author
+
+	switch {
+	case strings.Contains(from, "<") && strings.LastIndex(from, ">") > strings.LastIndex(from, "<"):
+		start := strings.LastIndex(from, "<")
+		end := strings.LastIndex(from, ">")
+
+		author.Address = strings.TrimSpace(from[start+1 : end])
+		author.Name = from[:start] + " " + from[end+1:]
+	case strings.HasSuffix(from, ")") && strings.Contains(from, "("):
+		start := strings.Index(from, "(")
+
+		address := strings.TrimSpace(from[:start])
+		if isAddress(address) {
+			author.Address = address
+			author.Name = from[start+1 : len(from)-1]
+		} else {
+			author.Name = from
+		}
+	case isAddress(from):
+		author.Address = from
+	default:
+		author.Name = from
+	}
+
+	author.Name = cleanAuthorName(author.Name)
+	author.Key = authorKey(author)
+
+	return author
+}
+
+// isAddress reports whether s is a single address, optionally obfuscated.
+func isAddress(s string) bool {
+	s = deobfuscateAddress(s)
+
+	return s != "" && !strings.ContainsAny(s, " \t\"") && strings.Count(s, "@") == 1
+}
+
+func deobfuscateAddress(s string) string {
+	s = obfuscatedAtRegexp.ReplaceAllString(strings.TrimSpace(s), "@")
+
+	return obfuscatedDotRegexp.ReplaceAllString(s, ".")
+}
+
+func cleanAuthorName(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+
+	// Quoted names & comments
+	for len(name) >= 2 && (name[0] == '"' && name[len(name)-1] == '"' || name[0] == '(' && name[len(name)-1] == ')') {
+		name = strings.TrimSpace(name[1 : len(name)-1])
+	}
+
+	return strings.ReplaceAll(strings.ReplaceAll(name, `\"`, `"`), `\\`, `\`)
+}
+
+func authorKey(author Author) string {
+	if author.Address != "" {
+		return strings.ToLower(deobfuscateAddress(author.Address))
+	}
+
+	return strings.ToLower(author.Name)
+}
+
+// Poster parses the author of the header. The decoded author is used if available.
+func (h Header) Poster() Author {
+	return ParseAuthor(h.DisplayAuthor())
+}
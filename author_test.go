@@ -0,0 +1,82 @@
+package nntp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestParseAuthor(t *testing.T) {
+	tests := []struct {
+		from     string
+		expected nntp.Author
+	}{
+		{
+			from:     `"Joe Bloggs" <Bloggs@Nowhere.example>`,
+			expected: nntp.Author{Name: "Joe Bloggs", Address: "Bloggs@Nowhere.example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `Joe  Bloggs <bloggs@nowhere.example>`,
+			expected: nntp.Author{Name: "Joe Bloggs", Address: "bloggs@nowhere.example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `<bloggs@nowhere.example>`,
+			expected: nntp.Author{Address: "bloggs@nowhere.example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `bloggs@nowhere.example (Joe Bloggs)`,
+			expected: nntp.Author{Name: "Joe Bloggs", Address: "bloggs@nowhere.example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `bloggs@nowhere.example`,
+			expected: nntp.Author{Address: "bloggs@nowhere.example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `bloggs at nowhere dot example (Joe)`,
+			expected: nntp.Author{Name: "Joe", Address: "bloggs at nowhere dot example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `bloggs[at]nowhere[DOT]example`,
+			expected: nntp.Author{Address: "bloggs[at]nowhere[DOT]example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `"Joe \"The Poster\" Bloggs" <bloggs@nowhere.example>`,
+			expected: nntp.Author{Name: `Joe "The Poster" Bloggs`, Address: "bloggs@nowhere.example", Key: "bloggs@nowhere.example"},
+		},
+		{
+			from:     `Yenc@power-post.org (Yenc-PP-GUI)`,
+			expected: nntp.Author{Name: "Yenc-PP-GUI", Address: "Yenc@power-post.org", Key: "yenc@power-post.org"},
+		},
+		{
+			from:     `Some Poster`,
+			expected: nntp.Author{Name: "Some Poster", Key: "some poster"},
+		},
+		{
+			from:     `Some Poster (really)`,
+			expected: nntp.Author{Name: "Some Poster (really)", Key: "some poster (really)"},
+		},
+		{
+			from:     `Broken <address`,
+			expected: nntp.Author{Name: "Broken <address", Key: "broken <address"},
+		},
+		{
+			from:     ``,
+			expected: nntp.Author{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.from, func(t *testing.T) {
+			assert.Equal(t, test.expected, nntp.ParseAuthor(test.from))
+		})
+	}
+}
+
+func TestHeader_Poster(t *testing.T) {
+	header := nntp.Header{Author: "=?utf-8?q?J=C3=BCrgen?= <j@example.com>", DecodedAuthor: "Jürgen <j@example.com>"}
+
+	assert.Equal(t, nntp.Author{Name: "Jürgen", Address: "j@example.com", Key: "j@example.com"}, header.Poster())
+}
@@ -0,0 +1,396 @@
+// Package backfill fetches the overview data of a group back to a given date, by locating the article number of that
+// date & walking backwards from the newest article.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+)
+
+const (
+	// DefaultChunkSize is the default number of articles requested per XOVER command.
+	DefaultChunkSize = 10000
+	// DefaultProbeWindow is the default number of articles whose dates get requested to estimate the date at a
+	// position within a group.
+	DefaultProbeWindow = 20
+)
+
+// Client is the subset of nntp.Client used by the Backfiller.
+type Client interface {
+	Group(name string) (nntp.NewsgroupDetail, error)
+	Hdr(field, r string) ([]nntp.HeaderValue, error)
+	Xover(r string) ([]nntp.Header, error)
+}
+
+// Backfiller locates & fetches historical articles of groups.
+// Article dates are neither unique nor strictly ordered, as they are set by the posting client. The date of an article
+// is therefore taken as the median date of it & its neighbors, which ignores single articles with bogus dates. Probes
+// skip gaps left by expired or cancelled articles.
+type Backfiller struct {
+	// Number of articles requested per XOVER command. Defaults to DefaultChunkSize.
+	ChunkSize uint64
+	// Number of articles whose dates get requested per probe. Defaults to DefaultProbeWindow.
+	ProbeWindow uint64
+	// Use XOVER instead of HDR to retrieve dates. Gets enabled automatically if the server supports neither HDR nor
+	// XHDR.
+	UseOverview bool
+
+	client Client
+
+	// Serializes GROUP + HDR / XOVER sequences
+	lock sync.Mutex
+}
+
+func New(client Client) *Backfiller {
+	return &Backfiller{
+		ChunkSize:   DefaultChunkSize,
+		ProbeWindow: DefaultProbeWindow,
+		client:      client,
+	}
+}
+
+var ErrNoArticles = errors.New("group contains no articles")
+
+// Locate returns the number of the first article of the group posted at or after date. If all articles are older,
+// the high water mark plus one is returned. If all articles are newer, the low water mark is returned.
+func (b *Backfiller) Locate(ctx context.Context, group string, date time.Time) (uint64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	detail, err := b.client.Group(group)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select group '%s': %w", group, err)
+	}
+
+	return b.locate(ctx, detail, date)
+}
+
+func (b *Backfiller) locate(ctx context.Context, detail nntp.NewsgroupDetail, date time.Time) (uint64, error) {
+	// Binary search for the first article dated at or after date within [lo, hi)
+	lo, hi := detail.Low, detail.High+1
+	found := detail.High + 1
+
+	for lo < hi {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		mid := lo + (hi-lo)/2
+
+		window, err := b.probe(mid, hi-1)
+		if err != nil {
+			return 0, err
+		}
+
+		candidates := window.candidates()
+		if len(candidates) == 0 {
+			// Gap extending to hi
+			hi = mid
+			continue
+		}
+
+		idx := sort.Search(len(candidates), func(idx int) bool {
+			return !window.date(window.from + idx).Before(date)
+		})
+
+		switch idx {
+		case 0:
+			found = candidates[0].number
+			hi = mid
+		case len(candidates):
+			lo = candidates[len(candidates)-1].number + 1
+		default:
+			return candidates[idx].number, nil
+		}
+	}
+
+	return found, nil
+}
+
+type datedArticle struct {
+	number uint64
+	date   time.Time
+}
+
+// probeWindow holds the dated articles of a probed range, plus the articles right before & after it.
+type probeWindow struct {
+	articles []datedArticle
+	// Index range of the articles within the probed range
+	from, to int
+}
+
+func (w probeWindow) candidates() []datedArticle {
+	return w.articles[w.from:w.to]
+}
+
+// date returns the median date of the article & its neighbors, so single articles with bogus dates are ignored. The
+// lower median is used at the edges of the group.
+func (w probeWindow) date(idx int) time.Time {
+	start, end := idx-1, idx+2
+	if start < 0 {
+		start = 0
+	}
+
+	if end > len(w.articles) {
+		end = len(w.articles)
+	}
+
+	dates := make([]time.Time, 0, 3)
+	for _, article := range w.articles[start:end] {
+		dates = append(dates, article.date)
+	}
+
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	return dates[(len(dates)-1)/2]
+}
+
+// probe returns the dated articles of the first window starting at first which contains any. Windows double in size
+// while they are empty, so gaps up to last are skipped with few requests.
+func (b *Backfiller) probe(first, last uint64) (probeWindow, error) {
+	size := b.ProbeWindow
+	if size == 0 {
+		size = DefaultProbeWindow
+	}
+
+	for first <= last {
+		end := first + size - 1
+		if end > last || end < first {
+			end = last
+		}
+
+		// Include the neighbors of the range, so the dates at its edges can be smoothed
+		before := first
+		if before > 0 {
+			before--
+		}
+
+		articles, err := b.dates(before, end+1)
+		if err != nil {
+			return probeWindow{}, fmt.Errorf("failed to retrieve dates of %d-%d: %w", first, end, err)
+		}
+
+		window := probeWindow{articles: articles}
+		window.from = sort.Search(len(articles), func(idx int) bool {
+			return articles[idx].number >= first
+		})
+		window.to = sort.Search(len(articles), func(idx int) bool {
+			return articles[idx].number > end
+		})
+
+		if window.from < window.to || end == last {
+			return window, nil
+		}
+
+		first = end + 1
+		size *= 2
+	}
+
+	return probeWindow{}, nil
+}
+
+// dates returns the articles with parsable dates within the range, sorted by number.
+func (b *Backfiller) dates(first, last uint64) ([]datedArticle, error) {
+	r := fmt.Sprintf("%d-%d", first, last)
+
+	var articles []datedArticle
+
+	if !b.UseOverview {
+		values, err := b.client.Hdr("Date", r)
+
+		switch {
+		case isCode(err, 500):
+			b.UseOverview = true
+		case isCode(err, 423):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		default:
+			for _, value := range values {
+				if date, err := nntp.ParseDate(value.Value); err == nil {
+					articles = append(articles, datedArticle{number: value.Number, date: date})
+				}
+			}
+		}
+	}
+
+	if b.UseOverview {
+		headers, err := b.client.Xover(r)
+		if isCode(err, 423) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, header := range headers {
+			if !header.Date.IsZero() {
+				articles = append(articles, datedArticle{number: header.MessageNumber, date: header.Date})
+			}
+		}
+	}
+
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].number < articles[j].number
+	})
+
+	return articles, nil
+}
+
+func isCode(err error, code int) bool {
+	var protoErr *textproto.Error
+
+	return errors.As(err, &protoErr) && protoErr.Code == code
+}
+
+// Result describes a single backfill run.
+type Result struct {
+	Group string
+	// Range of article numbers requested. Last < First if there were no articles since the date.
+	First uint64
+	Last  uint64
+	// Number of headers returned by the server
+	Headers int
+	// Whether the date is older than the oldest article available, so the backfill ended at the retention limit of the
+	// provider
+	ReachedRetention bool
+}
+
+// HandlerFunc processes the headers of a single chunk. Returning an error aborts the run.
+type HandlerFunc func(headers []nntp.Header) error
+
+// Run fetches the overview data of all articles of the group posted since the given date. Chunks are fetched from the
+// newest article backwards, until the located article number or the low water mark is reached.
+func (b *Backfiller) Run(ctx context.Context, group string, since time.Time, fn HandlerFunc) (Result, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	result := Result{Group: group}
+
+	detail, err := b.client.Group(group)
+	if err != nil {
+		return result, fmt.Errorf("failed to select group '%s': %w", group, err)
+	}
+
+	first, err := b.locate(ctx, detail, since)
+	if err != nil {
+		return result, fmt.Errorf("failed to locate %s: %w", since, err)
+	}
+
+	if first <= detail.Low && detail.Low <= detail.High {
+		first = detail.Low
+		result.ReachedRetention = true
+	}
+
+	result.First, result.Last = detail.High+1, detail.High
+
+	chunkSize := b.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	for last := detail.High; last >= first && last != 0; last -= chunkSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		start := first
+		if last-first >= chunkSize {
+			start = last - chunkSize + 1
+		}
+
+		headers, err := b.client.Xover(fmt.Sprintf("%d-%d", start, last))
+		if err != nil && !isCode(err, 423) {
+			return result, fmt.Errorf("failed to fetch overview of '%s' %d-%d: %w", group, start, last, err)
+		}
+
+		if err := fn(headers); err != nil {
+			return result, err
+		}
+
+		result.First = start
+		result.Headers += len(headers)
+
+		if start == first {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// Retention describes the articles of a group available at a provider.
+type Retention struct {
+	Group string
+	Low   uint64
+	High  uint64
+	// Estimated dates of the oldest & newest articles
+	Oldest time.Time
+	Newest time.Time
+}
+
+// Age returns the effective retention of the provider for the group, the time since the oldest available article.
+func (r Retention) Age(now time.Time) time.Duration {
+	return now.Sub(r.Oldest)
+}
+
+// Retention probes the dates of the oldest & newest articles available in the group.
+func (b *Backfiller) Retention(ctx context.Context, group string) (Retention, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	detail, err := b.client.Group(group)
+	if err != nil {
+		return Retention{}, fmt.Errorf("failed to select group '%s': %w", group, err)
+	}
+
+	retention := Retention{Group: group, Low: detail.Low, High: detail.High}
+
+	if err := ctx.Err(); err != nil {
+		return retention, err
+	}
+
+	oldest, err := b.probe(detail.Low, detail.High)
+	if err != nil {
+		return retention, err
+	}
+
+	if len(oldest.candidates()) == 0 {
+		return retention, fmt.Errorf("%w: '%s'", ErrNoArticles, group)
+	}
+
+	window := b.ProbeWindow
+	if window == 0 {
+		window = DefaultProbeWindow
+	}
+
+	newestStart := detail.Low
+	if detail.High-detail.Low >= window {
+		newestStart = detail.High - window + 1
+	}
+
+	newest, err := b.probe(newestStart, detail.High)
+	if err != nil {
+		return retention, err
+	}
+
+	if len(newest.candidates()) == 0 {
+		// The newest articles have been cancelled, the oldest window is the best estimate available
+		newest = oldest
+	}
+
+	retention.Oldest = oldest.date(oldest.from)
+	retention.Newest = newest.date(newest.to - 1)
+
+	return retention, nil
+}
@@ -0,0 +1,155 @@
+package backfill_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/backfill"
+)
+
+var _ backfill.Client = (*nntp.Client)(nil)
+
+var base = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeClient serves articles 100-1000 dated one hour apart, with a gap at 300-499 & a few articles with bogus dates.
+type fakeClient struct {
+	noHdr    bool
+	requests []string
+}
+
+func (c *fakeClient) Group(name string) (nntp.NewsgroupDetail, error) {
+	return nntp.NewsgroupDetail{Name: name, Low: 100, High: 1000, Number: 700}, nil
+}
+
+func (c *fakeClient) headers(r string) ([]nntp.Header, error) {
+	c.requests = append(c.requests, r)
+
+	var first, last uint64
+	if _, err := fmt.Sscanf(r, "%d-%d", &first, &last); err != nil {
+		return nil, err
+	}
+
+	var headers []nntp.Header
+
+	for number := first; number <= last; number++ {
+		if number < 100 || number > 1000 || number >= 300 && number < 500 {
+			continue
+		}
+
+		date := base.Add(time.Duration(number) * time.Hour)
+		if number%50 == 7 {
+			date = time.Unix(0, 0)
+		}
+
+		headers = append(headers, nntp.Header{MessageNumber: number, Date: date})
+	}
+
+	if len(headers) == 0 {
+		return nil, &textproto.Error{Code: 423, Msg: "No articles in that range"}
+	}
+
+	return headers, nil
+}
+
+func (c *fakeClient) Hdr(field, r string) ([]nntp.HeaderValue, error) {
+	if c.noHdr {
+		return nil, &textproto.Error{Code: 500, Msg: "Unknown command"}
+	}
+
+	headers, err := c.headers(r)
+
+	values := make([]nntp.HeaderValue, len(headers))
+	for idx := range headers {
+		values[idx] = nntp.HeaderValue{Number: headers[idx].MessageNumber, Value: headers[idx].Date.Format(time.RFC1123Z)}
+	}
+
+	return values, err
+}
+
+func (c *fakeClient) Xover(r string) ([]nntp.Header, error) {
+	return c.headers(r)
+}
+
+func TestBackfiller_Locate(t *testing.T) {
+	tests := []struct {
+		name     string
+		date     time.Time
+		expected uint64
+	}{
+		{name: "exact", date: base.Add(600 * time.Hour), expected: 600},
+		{name: "gap", date: base.Add(400 * time.Hour), expected: 500},
+		// Article 157 is dated 1970, so 158 is the first article dated at or after the date
+		{name: "bogus date", date: base.Add(157 * time.Hour), expected: 158},
+		{name: "older than retention", date: base, expected: 100},
+		{name: "newer than all", date: base.Add(2000 * time.Hour), expected: 1001},
+	}
+
+	for _, noHdr := range []bool{false, true} {
+		for _, test := range tests {
+			test := test
+			t.Run(fmt.Sprintf("%s (no HDR: %v)", test.name, noHdr), func(t *testing.T) {
+				number, err := backfill.New(&fakeClient{noHdr: noHdr}).Locate(context.Background(), "misc.test", test.date)
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, number)
+			})
+		}
+	}
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	client := &fakeClient{}
+
+	backfiller := backfill.New(client)
+	backfiller.ChunkSize = 150
+
+	var numbers []uint64
+
+	result, err := backfiller.Run(context.Background(), "misc.test", base.Add(600*time.Hour), func(headers []nntp.Header) error {
+		for _, header := range headers {
+			numbers = append(numbers, header.MessageNumber)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, backfill.Result{Group: "misc.test", First: 600, Last: 1000, Headers: 401}, result)
+	assert.Equal(t, uint64(851), numbers[0], "Expected the newest chunk first")
+	assert.Contains(t, client.requests, "851-1000")
+	assert.Contains(t, client.requests, "701-850")
+	assert.Contains(t, client.requests, "600-700")
+
+	client.requests = nil
+	result, err = backfiller.Run(context.Background(), "misc.test", base, func([]nntp.Header) error {
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, result.ReachedRetention, "Expected backfill to end at the retention limit")
+	assert.Equal(t, uint64(100), result.First)
+	assert.Equal(t, 701, result.Headers)
+
+	errHandler := errors.New("handler failed")
+	_, err = backfiller.Run(context.Background(), "misc.test", base, func([]nntp.Header) error {
+		return errHandler
+	})
+	assert.True(t, errors.Is(err, errHandler), "Expected handler error, got %v", err)
+}
+
+func TestBackfiller_Retention(t *testing.T) {
+	retention, err := backfill.New(&fakeClient{}).Retention(context.Background(), "misc.test")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(100), retention.Low)
+	assert.Equal(t, uint64(1000), retention.High)
+	assert.True(t, base.Add(100*time.Hour).Equal(retention.Oldest), "Unexpected oldest date %s", retention.Oldest)
+	// Lower median of the two newest articles
+	assert.True(t, base.Add(999*time.Hour).Equal(retention.Newest), "Unexpected newest date %s", retention.Newest)
+	assert.Equal(t, 24*time.Hour, retention.Age(base.Add(124*time.Hour)))
+}
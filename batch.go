@@ -0,0 +1,178 @@
+package nntp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type BatchCommand string
+
+const (
+	BatchArticle BatchCommand = "ARTICLE"
+	BatchBody    BatchCommand = "BODY"
+	BatchStat    BatchCommand = "STAT"
+)
+
+type BatchRequest struct {
+	Command BatchCommand
+	// Message-id or article number in the currently selected group
+	ID string
+}
+
+type BatchResult struct {
+	Request BatchRequest
+	Article Article
+	Err     error
+}
+
+var ErrInvalidBatchCommand = errors.New("invalid batch command. Allowed: ARTICLE, BODY, STAT")
+
+type pendingBatchRequest struct {
+	request BatchRequest
+	id      uint
+	err     error
+}
+
+// Batch pipelines the given requests: Up to window commands get sent before their responses are read.
+// The results are returned in request order on the returned channel, which gets closed after the last result.
+// A failed request (like a missing article) does not abort the batch. If the connection breaks, all remaining requests
+// fail with the same error.
+// Once the context gets cancelled, no further commands are sent & the channel gets closed. The responses of the commands
+// already sent are read & discarded, so the connection stays usable.
+func (c *Client) Batch(ctx context.Context, requests []BatchRequest, window int) chan BatchResult {
+	if window < 1 {
+		window = 1
+	}
+
+	results := make(chan BatchResult, window)
+
+	go func() {
+		defer close(results)
+
+		var (
+			pending    = make([]pendingBatchRequest, 0, window)
+			next       int
+			brokenErr  error
+			sendFailed bool
+		)
+
+		// Reads the responses of all sent commands, after the consumer stopped receiving results
+		discard := func() {
+			for _, p := range pending {
+				c.receiveBatchResult(p, brokenErr)
+			}
+		}
+
+		send := func(result BatchResult) bool {
+			select {
+			case results <- result:
+				return true
+			case <-ctx.Done():
+				discard()
+				return false
+			}
+		}
+
+		for next < len(requests) || len(pending) > 0 {
+			if ctx.Err() != nil {
+				discard()
+				return
+			}
+
+			for brokenErr == nil && !sendFailed && len(pending) < window && next < len(requests) {
+				p := c.sendBatchRequest(requests[next])
+				pending = append(pending, p)
+				next++
+
+				// The responses of the requests sent before are still read
+				sendFailed = p.err != nil && !errors.Is(p.err, ErrInvalidBatchCommand)
+			}
+
+			if len(pending) == 0 {
+				// The connection broke before the remaining requests could be sent
+				if !send(BatchResult{Request: requests[next], Err: brokenErr}) {
+					return
+				}
+
+				next++
+
+				continue
+			}
+
+			p := pending[0]
+			pending = pending[1:]
+
+			result, broken := c.receiveBatchResult(p, brokenErr)
+			if broken && brokenErr == nil {
+				brokenErr = fmt.Errorf("connection broken by previous request: %w", result.Err)
+			}
+
+			if !send(result) {
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+func (c *Client) sendBatchRequest(request BatchRequest) pendingBatchRequest {
+	p := pendingBatchRequest{request: request}
+
+	switch request.Command {
+	case BatchArticle, BatchBody, BatchStat:
+	default:
+		p.err = fmt.Errorf("%w: Got '%s'", ErrInvalidBatchCommand, request.Command)
+		return p
+	}
+
+	p.id, p.err = c.connection.Cmd("%s %s", request.Command, request.ID)
+	if p.err != nil {
+		// Without knowing how much of the command got written, the connection can't be used anymore.
+		p.err = fmt.Errorf("failed to send command: %w", p.err)
+	}
+
+	return p
+}
+
+func (c *Client) receiveBatchResult(p pendingBatchRequest, brokenErr error) (result BatchResult, broken bool) {
+	result.Request = p.request
+
+	// Invalid commands never got sent & therefore don't have a response
+	if errors.Is(p.err, ErrInvalidBatchCommand) {
+		result.Err = p.err
+		return result, false
+	}
+
+	// Commands which failed to be sent don't have a response & their id isn't known
+	if p.err != nil {
+		result.Err = p.err
+		return result, true
+	}
+
+	c.connection.StartResponse(p.id)
+	defer c.connection.EndResponse(p.id)
+
+	if brokenErr != nil {
+		result.Err = brokenErr
+		return result, true
+	}
+
+	var raw []byte
+
+	switch p.request.Command {
+	case BatchArticle:
+		result.Article, raw, broken, result.Err = c.readArticleResponse(220, true)
+		if result.Err == nil {
+			result.Article.Header, result.Article.Body = splitArticle(raw)
+		}
+	case BatchBody:
+		result.Article, raw, broken, result.Err = c.readArticleResponse(222, true)
+		result.Article.Body = raw
+	case BatchStat:
+		result.Article, _, broken, result.Err = c.readArticleResponse(223, false)
+	}
+
+	return result, broken
+}
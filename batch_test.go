@@ -0,0 +1,194 @@
+package nntp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestClient_Batch(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	conn.RecordPrintfLine(t, "222 0 <a@example.com>")
+	conn.RecordDotMessage(t, "body a\n")
+	conn.RecordPrintfLine(t, "430 No such article")
+	conn.RecordPrintfLine(t, "223 0 <c@example.com>")
+	conn.RecordPrintfLine(t, "220 0 <d@example.com>")
+	conn.RecordDotMessage(t, "Subject: d\n\nbody d\n")
+
+	requests := []nntp.BatchRequest{
+		{Command: nntp.BatchBody, ID: "<a@example.com>"},
+		{Command: nntp.BatchBody, ID: "<b@example.com>"},
+		{Command: nntp.BatchStat, ID: "<c@example.com>"},
+		{Command: "POST", ID: "<x@example.com>"},
+		{Command: nntp.BatchArticle, ID: "<d@example.com>"},
+	}
+
+	var results []nntp.BatchResult
+	for result := range client.Batch(context.Background(), requests, 2) {
+		results = append(results, result)
+	}
+
+	require.Len(t, results, len(requests))
+
+	for idx := range requests {
+		assert.Equal(t, requests[idx], results[idx].Request)
+	}
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "body a\n", string(results[0].Article.Body))
+
+	assert.True(t, errors.Is(results[1].Err, nntp.ErrNoSuchArticle), "Expected %v, got %v", nntp.ErrNoSuchArticle, results[1].Err)
+
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, "<c@example.com>", results[2].Article.MessageID)
+
+	assert.True(t, errors.Is(results[3].Err, nntp.ErrInvalidBatchCommand), "Expected %v, got %v", nntp.ErrInvalidBatchCommand, results[3].Err)
+
+	assert.NoError(t, results[4].Err)
+	assert.Equal(t, "d", results[4].Article.Header.Get("Subject"))
+	assert.Equal(t, "body d\n", string(results[4].Article.Body))
+
+	assert.Equal(t, "AUTHINFO USER foo\r\nAUTHINFO PASS bar\r\nBODY <a@example.com>\r\nBODY <b@example.com>\r\nSTAT <c@example.com>\r\nARTICLE <d@example.com>\r\n", conn.write.String())
+}
+
+func TestClient_Batch_BrokenConnection(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	conn.RecordPrintfLine(t, "222 0 <a@example.com>")
+	conn.RecordDotMessage(t, "body a\n")
+
+	requests := []nntp.BatchRequest{
+		{Command: nntp.BatchBody, ID: "<a@example.com>"},
+		{Command: nntp.BatchBody, ID: "<b@example.com>"},
+		{Command: nntp.BatchBody, ID: "<c@example.com>"},
+		{Command: nntp.BatchBody, ID: "<d@example.com>"},
+	}
+
+	var results []nntp.BatchResult
+	for result := range client.Batch(context.Background(), requests, 2) {
+		results = append(results, result)
+	}
+
+	require.Len(t, results, len(requests))
+	assert.NoError(t, results[0].Err)
+
+	for _, result := range results[1:] {
+		assert.Error(t, result.Err)
+	}
+}
+
+// failingConnection fails all writes after the given number of successful ones.
+type failingConnection struct {
+	*bufferConnection
+	writes int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (c *failingConnection) Write(p []byte) (int, error) {
+	if c.writes == 0 {
+		return 0, errWriteFailed
+	}
+
+	c.writes--
+
+	return c.bufferConnection.Write(p)
+}
+
+func TestClient_Batch_SendError(t *testing.T) {
+	conn := &failingConnection{bufferConnection: newBufferConnection(), writes: 1}
+	conn.RecordPrintfLine(t, "200 some-newsserver")
+	conn.RecordPrintfLine(t, "222 0 <a@example.com>")
+	conn.RecordDotMessage(t, "body a\n")
+
+	client, err := nntp.NewFromConn(conn)
+	require.NoError(t, err)
+
+	requests := []nntp.BatchRequest{
+		{Command: nntp.BatchBody, ID: "<a@example.com>"},
+		{Command: nntp.BatchBody, ID: "<b@example.com>"},
+		{Command: nntp.BatchBody, ID: "<c@example.com>"},
+		{Command: nntp.BatchBody, ID: "<d@example.com>"},
+	}
+
+	results := collectBatch(t, client.Batch(context.Background(), requests, 2))
+
+	require.Len(t, results, len(requests))
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "body a\n", string(results[0].Article.Body))
+
+	for _, result := range results[1:] {
+		assert.True(t, errors.Is(result.Err, errWriteFailed), "Expected %v, got %v", errWriteFailed, result.Err)
+	}
+
+	assert.Equal(t, "BODY <a@example.com>\r\n", conn.write.String())
+}
+
+func TestClient_Batch_Cancel(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+
+	var requests []nntp.BatchRequest
+
+	// One more response than requests, which is left for the request after the batch
+	for i := 0; i <= 10; i++ {
+		conn.RecordPrintfLine(t, "223 %d <%d@example.com>", i, i)
+		requests = append(requests, nntp.BatchRequest{Command: nntp.BatchStat, ID: fmt.Sprintf("<%d@example.com>", i)})
+	}
+
+	requests = requests[:10]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := client.Batch(ctx, requests, 2)
+
+	first := <-results
+	require.NoError(t, first.Err)
+	assert.Equal(t, "<0@example.com>", first.Article.MessageID)
+
+	// The consumer stops receiving results, but the channel gets closed anyway
+	cancel()
+	collectBatch(t, results)
+
+	// At most the results fitting into the channel, the one blocked on sending & the pending ones got requested
+	assert.LessOrEqual(t, strings.Count(conn.write.String(), "STAT"), 6)
+
+	// The responses of all sent commands have been read, so the connection is still usable
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Stat("<10@example.com>")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connection blocked after cancelling the batch")
+	}
+}
+
+// collectBatch reads all results, failing the test if the channel doesn't get closed.
+func collectBatch(t *testing.T, results chan nntp.BatchResult) []nntp.BatchResult {
+	var collected []nntp.BatchResult
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return collected
+			}
+
+			collected = append(collected, result)
+		case <-timeout:
+			t.Fatalf("Batch did not finish, got %d results", len(collected))
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrUnknownCharset = errors.New("unknown charset")
+
+type charset struct {
+	// Nil for charsets mapping bytes to the code points of the same value (ISO-8859-1)
+	table *[128]rune
+	// UTF-8 & US-ASCII don't need to be converted
+	passthrough bool
+}
+
+// Keyed by the lowercase name without '-', '_' & spaces
+var charsets = map[string]charset{
+	"utf8":        {passthrough: true},
+	"usascii":     {passthrough: true},
+	"ascii":       {passthrough: true},
+	"iso88591":    {},
+	"latin1":      {},
+	"l1":          {},
+	"cp819":       {},
+	"iso88592":    {table: &iso88592},
+	"latin2":      {table: &iso88592},
+	"iso885915":   {table: &iso885915},
+	"latin9":      {table: &iso885915},
+	"windows1250": {table: &windows1250},
+	"cp1250":      {table: &windows1250},
+	"windows1251": {table: &windows1251},
+	"cp1251":      {table: &windows1251},
+	"windows1252": {table: &windows1252},
+	"cp1252":      {table: &windows1252},
+	"xcp1252":     {table: &windows1252},
+	"koi8r":       {table: &koi8r},
+}
+
+func lookupCharset(name string) (charset, error) {
+	// RFC 2231 allows a language suffix, like "utf-8*en"
+	if idx := strings.IndexByte(name, '*'); idx >= 0 {
+		name = name[:idx]
+	}
+
+	key := strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ':
+			return -1
+		}
+
+		return r
+	}, strings.ToLower(name))
+
+	cs, ok := charsets[key]
+	if !ok {
+		return cs, fmt.Errorf("%w: '%s'", ErrUnknownCharset, name)
+	}
+
+	return cs, nil
+}
+
+// DecodeCharset converts text in the given charset to UTF-8. Supported are UTF-8, US-ASCII, ISO-8859-1, ISO-8859-2,
+// ISO-8859-15, Windows-1250, Windows-1251, Windows-1252 & KOI8-R.
+func DecodeCharset(name string, b []byte) (string, error) {
+	cs, err := lookupCharset(name)
+	if err != nil {
+		return "", err
+	}
+
+	return cs.decode(b), nil
+}
+
+func (cs charset) decode(b []byte) string {
+	if cs.passthrough {
+		return string(b)
+	}
+
+	var s strings.Builder
+
+	s.Grow(len(b))
+
+	for _, c := range b {
+		s.WriteRune(cs.rune(c))
+	}
+
+	return s.String()
+}
+
+func (cs charset) rune(c byte) rune {
+	if c < 0x80 || cs.table == nil {
+		return rune(c)
+	}
+
+	return cs.table[c-0x80]
+}
+
+// CharsetReader returns a reader converting the input from the given charset to UTF-8. It can be used as
+// CharsetReader of mime.WordDecoder & xml.Decoder.
+func CharsetReader(name string, input io.Reader) (io.Reader, error) {
+	cs, err := lookupCharset(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if cs.passthrough {
+		return input, nil
+	}
+
+	return &charsetReader{charset: cs, r: input}, nil
+}
+
+type charsetReader struct {
+	charset charset
+	r       io.Reader
+	buf     []byte
+	out     []byte
+	// Converted bytes which did not fit into the last read
+	pending []byte
+}
+
+func (c *charsetReader) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if c.buf == nil {
+			c.buf = make([]byte, 4096)
+		}
+
+		n, err := c.r.Read(c.buf)
+
+		var encoded [utf8.UTFMax]byte
+
+		c.out = c.out[:0]
+		for _, b := range c.buf[:n] {
+			size := utf8.EncodeRune(encoded[:], c.charset.rune(b))
+			c.out = append(c.out, encoded[:size]...)
+		}
+
+		c.pending = c.out
+
+		if n == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
@@ -0,0 +1,381 @@
+// Package collector groups the overview headers of binary posts into files & collections.
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/nzb"
+)
+
+// DefaultWindow is the default maximum time between two files of the same collection.
+const DefaultWindow = 3 * time.Hour
+
+// Segment is a single article of a file.
+type Segment struct {
+	Number int
+	Header nntp.Header
+}
+
+// File is a single binary, posted as one or more segments.
+type File struct {
+	// Subject of the first segment
+	Subject string
+	// Name of the file, parsed from the subject
+	Name   string
+	Poster string
+	// Date of the oldest segment
+	Date time.Time
+	// File counter of the subject, like the 1 of "[01/20]"
+	Number int
+	// Expected number of segments
+	Total int
+	// Segments sorted by number. Duplicate numbers are dropped.
+	Segments []Segment
+
+	firstSegment int
+}
+
+// Missing returns the numbers of all segments which have not been seen.
+func (f *File) Missing() []int {
+	var missing []int
+
+	next := 0
+
+	for number := 1; number <= f.Total; number++ {
+		// Skip segments numbered below 1
+		for next < len(f.Segments) && f.Segments[next].Number < number {
+			next++
+		}
+
+		if next < len(f.Segments) && f.Segments[next].Number == number {
+			continue
+		}
+
+		missing = append(missing, number)
+	}
+
+	return missing
+}
+
+// Complete reports whether all segments have been seen.
+func (f *File) Complete() bool {
+	return f.seen() == f.Total
+}
+
+// seen returns the number of seen segments within 1 to Total.
+func (f *File) seen() (seen int) {
+	for idx := range f.Segments {
+		if number := f.Segments[idx].Number; number >= 1 && number <= f.Total {
+			seen++
+		}
+	}
+
+	return seen
+}
+
+// Bytes returns the size of all seen segments.
+func (f *File) Bytes() (bytes uint64) {
+	for idx := range f.Segments {
+		bytes += f.Segments[idx].Header.Bytes
+	}
+
+	return bytes
+}
+
+func (f *File) add(number int, header nntp.Header, subject string) {
+	idx := sort.Search(len(f.Segments), func(i int) bool {
+		return f.Segments[i].Number >= number
+	})
+
+	if idx < len(f.Segments) && f.Segments[idx].Number == number {
+		return
+	}
+
+	f.Segments = append(f.Segments, Segment{})
+	copy(f.Segments[idx+1:], f.Segments[idx:])
+	f.Segments[idx] = Segment{Number: number, Header: header}
+
+	if f.Date.IsZero() || header.Date.Before(f.Date) {
+		f.Date = header.Date
+	}
+
+	// Like most indexers, use the subject of the first segment
+	if f.firstSegment == 0 || number < f.firstSegment {
+		f.Subject = subject
+		f.firstSegment = number
+	}
+}
+
+// Collection is a set of files posted together, like the archive volumes & par2 files of a release.
+type Collection struct {
+	// Subject stem shared by all files
+	Name   string
+	Poster string
+	// Date of the oldest & newest segment
+	First time.Time
+	Last  time.Time
+	// Expected number of files, like the 20 of "[01/20]". Zero if the subjects don't have file counters.
+	Total int
+	// Files sorted by file counter & name
+	Files []*File
+
+	// Files by their subject without part counter
+	files map[string]*File
+}
+
+// Completeness is the number of seen & expected files and segments of a collection.
+type Completeness struct {
+	Files            int
+	ExpectedFiles    int
+	CompleteFiles    int
+	Segments         int
+	ExpectedSegments int
+}
+
+// Complete reports whether all files & segments have been seen.
+func (c Completeness) Complete() bool {
+	return c.Files >= c.ExpectedFiles && c.CompleteFiles == c.Files && c.Segments >= c.ExpectedSegments
+}
+
+// Percent returns the percentage of seen segments.
+func (c Completeness) Percent() float64 {
+	if c.ExpectedSegments == 0 {
+		return 0
+	}
+
+	return float64(c.Segments) * 100 / float64(c.ExpectedSegments)
+}
+
+// Completeness counts the seen & expected files and segments. Segments of unseen files can't be counted.
+func (c *Collection) Completeness() Completeness {
+	completeness := Completeness{
+		Files:         len(c.Files),
+		ExpectedFiles: c.Total,
+	}
+
+	if completeness.ExpectedFiles < completeness.Files {
+		completeness.ExpectedFiles = completeness.Files
+	}
+
+	for _, file := range c.Files {
+		completeness.Segments += file.seen()
+		completeness.ExpectedSegments += file.Total
+
+		if file.Complete() {
+			completeness.CompleteFiles++
+		}
+	}
+
+	return completeness
+}
+
+// NZB builds a document of all files. The name of the collection becomes the title.
+func (c *Collection) NZB(groups ...string) *nzb.NZB {
+	n := &nzb.NZB{
+		Meta: []nzb.Meta{{Type: "title", Value: c.Name}},
+	}
+
+	for _, file := range c.Files {
+		nzbFile := nzb.File{
+			Poster:  file.Poster,
+			Date:    file.Date.Unix(),
+			Subject: file.Subject,
+			Groups:  append([]string(nil), groups...),
+		}
+
+		for _, segment := range file.Segments {
+			nzbFile.Segments = append(nzbFile.Segments, nzb.Segment{
+				Bytes:     segment.Header.Bytes,
+				Number:    segment.Number,
+				MessageID: nntp.MessageID(segment.Header.MessageID).Bare(),
+			})
+		}
+
+		n.Files = append(n.Files, nzbFile)
+	}
+
+	return n
+}
+
+func (c *Collection) sortFiles() {
+	sort.SliceStable(c.Files, func(i, j int) bool {
+		if c.Files[i].Number != c.Files[j].Number {
+			return c.Files[i].Number < c.Files[j].Number
+		}
+
+		return c.Files[i].Name < c.Files[j].Name
+	})
+}
+
+// copy returns a deep copy of the collection with its files sorted.
+func (c *Collection) copy() *Collection {
+	copied := &Collection{
+		Name:   c.Name,
+		Poster: c.Poster,
+		First:  c.First,
+		Last:   c.Last,
+		Total:  c.Total,
+		Files:  make([]*File, len(c.Files)),
+	}
+
+	for idx, file := range c.Files {
+		copiedFile := *file
+		copiedFile.Segments = append([]Segment(nil), file.Segments...)
+		copied.Files[idx] = &copiedFile
+	}
+
+	copied.sortFiles()
+
+	return copied
+}
+
+func (c *Collection) within(date time.Time, window time.Duration) bool {
+	return !date.Before(c.First.Add(-window)) && !date.After(c.Last.Add(window))
+}
+
+type collectionKey struct {
+	poster string
+	stem   string
+}
+
+// Collector groups overview headers of binary posts into files & collections.
+// Segments get grouped into files by their subject without the part counter & their poster. Files get grouped into
+// collections by their poster key (see nntp.Author), subject stem & date: Files posted more than Window apart end up
+// in different collections.
+// All methods are safe for concurrent use.
+type Collector struct {
+	// Maximum time between two files of the same collection
+	Window time.Duration
+
+	lock        sync.Mutex
+	collections map[collectionKey][]*Collection
+	// Headers without part counter
+	skipped int
+}
+
+func New() *Collector {
+	return &Collector{
+		Window:      DefaultWindow,
+		collections: map[collectionKey][]*Collection{},
+	}
+}
+
+// Add collects the given headers. Headers without part counter are skipped.
+func (c *Collector) Add(headers ...nntp.Header) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for idx := range headers {
+		c.add(headers[idx])
+	}
+}
+
+// AddChan collects all headers from the channel until it gets closed, like the one returned by nntp.Client.XoverChan.
+func (c *Collector) AddChan(headers <-chan nntp.Header) {
+	for header := range headers {
+		c.Add(header)
+	}
+}
+
+// Skipped returns the number of headers skipped, because their subject does not contain a part counter.
+func (c *Collector) Skipped() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.skipped
+}
+
+func (c *Collector) add(header nntp.Header) {
+	subject, ok := ParseSubject(header.Subject)
+	if !ok {
+		c.skipped++
+		return
+	}
+
+	key := collectionKey{poster: header.Poster().Key, stem: strings.ToLower(subject.Stem)}
+	collection := c.collection(key, header.Date)
+
+	if collection == nil {
+		collection = &Collection{
+			Name:   subject.Stem,
+			Poster: header.Author,
+			First:  header.Date,
+			Last:   header.Date,
+			files:  map[string]*File{},
+		}
+		c.collections[key] = append(c.collections[key], collection)
+	}
+
+	if header.Date.Before(collection.First) {
+		collection.First = header.Date
+	}
+
+	if header.Date.After(collection.Last) {
+		collection.Last = header.Date
+	}
+
+	if subject.FileCounter.Total > collection.Total {
+		collection.Total = subject.FileCounter.Total
+	}
+
+	file := collection.files[subject.File]
+	if file == nil {
+		file = &File{
+			Name:   subject.Name,
+			Poster: header.Author,
+			Number: subject.FileCounter.Number,
+			Total:  subject.Part.Total,
+		}
+		collection.files[subject.File] = file
+		collection.Files = append(collection.Files, file)
+	}
+
+	if subject.Part.Total > file.Total {
+		file.Total = subject.Part.Total
+	}
+
+	file.add(subject.Part.Number, header, header.Subject)
+}
+
+func (c *Collector) collection(key collectionKey, date time.Time) *Collection {
+	for _, collection := range c.collections[key] {
+		if collection.within(date, c.Window) {
+			return collection
+		}
+	}
+
+	return nil
+}
+
+// Collections returns copies of all collections sorted by the date of their oldest segment, so they aren't affected by
+// headers added afterwards.
+func (c *Collector) Collections() []*Collection {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var collections []*Collection
+
+	for _, list := range c.collections {
+		for _, collection := range list {
+			collections = append(collections, collection.copy())
+		}
+	}
+
+	sort.Slice(collections, func(i, j int) bool {
+		if !collections[i].First.Equal(collections[j].First) {
+			return collections[i].First.Before(collections[j].First)
+		}
+
+		if collections[i].Name != collections[j].Name {
+			return collections[i].Name < collections[j].Name
+		}
+
+		return collections[i].Poster < collections[j].Poster
+	})
+
+	return collections
+}
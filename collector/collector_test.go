@@ -0,0 +1,239 @@
+package collector_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/collector"
+)
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		subject    string
+		expected   collector.Subject
+		expectedOK bool
+	}{
+		{
+			subject: `"show.s01e02.mkv" yEnc (12/87)`,
+			expected: collector.Subject{
+				File: `"show.s01e02.mkv" yEnc`,
+				Name: "show.s01e02.mkv",
+				Stem: "show.s01e02",
+				Part: nntp.SubjectPart{Number: 12, Total: 87},
+			},
+			expectedOK: true,
+		},
+		{
+			subject: `Show S01E02 [01/20] - "show.s01e02.part01.rar" yEnc (1/50) 3584000 bytes`,
+			expected: collector.Subject{
+				File:        `Show S01E02 [01/20] - "show.s01e02.part01.rar" yEnc  3584000 bytes`,
+				Name:        "show.s01e02.part01.rar",
+				Stem:        "Show S01E02",
+				Part:        nntp.SubjectPart{Number: 1, Total: 50},
+				FileCounter: nntp.SubjectPart{Number: 1, Total: 20},
+			},
+			expectedOK: true,
+		},
+		{
+			subject: `[03/20] - "show.s01e02.vol03+04.par2" yEnc (1/3)`,
+			expected: collector.Subject{
+				File:        `[03/20] - "show.s01e02.vol03+04.par2" yEnc`,
+				Name:        "show.s01e02.vol03+04.par2",
+				Stem:        "show.s01e02",
+				Part:        nntp.SubjectPart{Number: 1, Total: 3},
+				FileCounter: nntp.SubjectPart{Number: 3, Total: 20},
+			},
+			expectedOK: true,
+		},
+		{
+			subject: `picture.jpg [2 of 3]`,
+			expected: collector.Subject{
+				File: "picture.jpg",
+				Name: "picture.jpg",
+				Stem: "picture",
+				Part: nntp.SubjectPart{Number: 2, Total: 3},
+			},
+			expectedOK: true,
+		},
+		{
+			subject:    `some discussion`,
+			expectedOK: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.subject, func(t *testing.T) {
+			subject, ok := collector.ParseSubject(test.subject)
+			assert.Equal(t, test.expectedOK, ok)
+			assert.Equal(t, test.expected, subject)
+		})
+	}
+}
+
+func header(subject, author string, date time.Time, number int) nntp.Header {
+	return nntp.Header{
+		MessageNumber: uint64(number),
+		Subject:       subject,
+		Author:        author,
+		Date:          date,
+		MessageID:     fmt.Sprintf("<%d.%s@example.com>", number, date.Format("150405")),
+		Bytes:         100,
+	}
+}
+
+func TestCollector(t *testing.T) {
+	var (
+		start  = time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+		poster = "Poster <poster@example.com>"
+		c      = collector.New()
+	)
+
+	// First file complete, second file is missing part 2, third file not seen at all
+	c.Add(
+		header(`Show [1/3] - "show.part1.rar" yEnc (2/2)`, poster, start.Add(time.Minute), 2),
+		header(`Show [1/3] - "show.part1.rar" yEnc (1/2)`, poster, start, 1),
+		header(`Show [1/3] - "show.part1.rar" yEnc (1/2)`, poster, start, 1),
+		header(`Show [2/3] - "show.part2.rar" yEnc (1/3)`, poster, start.Add(2*time.Minute), 3),
+		header(`Show [2/3] - "show.part2.rar" yEnc (3/3)`, poster, start.Add(3*time.Minute), 4),
+		header(`Re: some discussion`, poster, start, 5),
+		// Same subjects by a different poster & much later by the same poster are different collections
+		header(`Show [1/3] - "show.part1.rar" yEnc (1/1)`, "Other <other@example.com>", start, 6),
+		header(`Show [1/3] - "show.part1.rar" yEnc (1/1)`, poster, start.Add(24*time.Hour), 7),
+	)
+
+	assert.Equal(t, 1, c.Skipped())
+
+	collections := c.Collections()
+	require.Len(t, collections, 3)
+
+	collection := collections[0]
+	if collection.Poster != poster {
+		collection = collections[1]
+	}
+
+	assert.Equal(t, "Show", collection.Name)
+	assert.Equal(t, poster, collection.Poster)
+	assert.Equal(t, start, collection.First)
+	assert.Equal(t, start.Add(3*time.Minute), collection.Last)
+	assert.Equal(t, 3, collection.Total)
+	require.Len(t, collection.Files, 2)
+
+	first, second := collection.Files[0], collection.Files[1]
+	assert.Equal(t, "show.part1.rar", first.Name)
+	assert.Equal(t, `Show [1/3] - "show.part1.rar" yEnc (1/2)`, first.Subject)
+	assert.Equal(t, start, first.Date)
+	assert.True(t, first.Complete())
+	assert.Len(t, first.Segments, 2)
+	assert.Equal(t, uint64(200), first.Bytes())
+
+	assert.Equal(t, 2, second.Number)
+	assert.False(t, second.Complete())
+	assert.Equal(t, []int{2}, second.Missing())
+
+	completeness := collection.Completeness()
+	assert.Equal(t, collector.Completeness{
+		Files:            2,
+		ExpectedFiles:    3,
+		CompleteFiles:    1,
+		Segments:         4,
+		ExpectedSegments: 5,
+	}, completeness)
+	assert.False(t, completeness.Complete())
+	assert.InDelta(t, 80, completeness.Percent(), 0.001)
+
+	assert.Equal(t, start.Add(24*time.Hour), collections[2].First)
+}
+
+func TestCollector_AddChan(t *testing.T) {
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	headers := make(chan nntp.Header, 2)
+	headers <- header(`"file.bin" yEnc (2/2)`, "poster", start, 2)
+	headers <- header(`"file.bin" yEnc (1/2)`, "poster", start, 1)
+	close(headers)
+
+	c := collector.New()
+	c.AddChan(headers)
+
+	collections := c.Collections()
+	require.Len(t, collections, 1)
+	assert.True(t, collections[0].Completeness().Complete())
+}
+
+func TestFile_Complete_OutOfRange(t *testing.T) {
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	c := collector.New()
+	c.Add(
+		header(`"file.bin" yEnc (0/2)`, "poster", start, 1),
+		header(`"file.bin" yEnc (1/2)`, "poster", start, 2),
+		header(`"file.bin" yEnc (3/2)`, "poster", start, 3),
+	)
+
+	collections := c.Collections()
+	require.Len(t, collections, 1)
+	require.Len(t, collections[0].Files, 1)
+
+	file := collections[0].Files[0]
+	assert.Equal(t, []int{2}, file.Missing())
+	assert.False(t, file.Complete(), "Segments outside of 1 to Total must not count")
+	assert.Equal(t, 1, collections[0].Completeness().Segments)
+}
+
+func TestCollector_Collections_Copies(t *testing.T) {
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	c := collector.New()
+	c.Add(header(`Show [2/2] - "show.par2" yEnc (1/2)`, "poster", start, 1))
+
+	collections := c.Collections()
+	require.Len(t, collections, 1)
+
+	c.Add(
+		header(`Show [2/2] - "show.par2" yEnc (2/2)`, "poster", start.Add(time.Minute), 2),
+		header(`Show [1/2] - "show.rar" yEnc (1/1)`, "poster", start.Add(time.Minute), 3),
+	)
+
+	// Headers added afterwards don't change the returned collections
+	require.Len(t, collections[0].Files, 1)
+	assert.Len(t, collections[0].Files[0].Segments, 1)
+	assert.Equal(t, start, collections[0].Last)
+
+	collections = c.Collections()
+	require.Len(t, collections[0].Files, 2)
+	assert.Equal(t, "show.rar", collections[0].Files[0].Name)
+	assert.Len(t, collections[0].Files[1].Segments, 2)
+}
+
+func TestCollection_NZB(t *testing.T) {
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	c := collector.New()
+	c.Add(
+		header(`Show [2/2] - "show.par2" yEnc (1/1)`, "poster", start.Add(time.Minute), 3),
+		header(`Show [1/2] - "show.rar" yEnc (2/2)`, "poster", start.Add(time.Minute), 2),
+		header(`Show [1/2] - "show.rar" yEnc (1/2)`, "poster", start, 1),
+	)
+
+	collections := c.Collections()
+	require.Len(t, collections, 1)
+
+	n := collections[0].NZB("alt.binaries.test")
+	require.NoError(t, n.Validate(), "Generated nzb must be valid")
+
+	assert.Equal(t, "Show", n.Meta[0].Value)
+	require.Len(t, n.Files, 2)
+	assert.Equal(t, `Show [1/2] - "show.rar" yEnc (1/2)`, n.Files[0].Subject)
+	assert.Equal(t, start.Unix(), n.Files[0].Date)
+	assert.Equal(t, []string{"alt.binaries.test"}, n.Files[0].Groups)
+	require.Len(t, n.Files[0].Segments, 2)
+	assert.Equal(t, 1, n.Files[0].Segments[0].Number)
+	assert.Equal(t, "1.120000@example.com", n.Files[0].Segments[0].MessageID)
+	assert.Equal(t, uint64(300), n.Bytes())
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mrincompetent/nntp"
+)
+
+var (
+	// Matches quoted file names like `"show.s01e02.part01.rar"`
+	quotedNameRegexp = regexp.MustCompile(`"([^"]+)"`)
+	// Matches unquoted file names with an extension, like "show.s01e02.part01.rar"
+	plainNameRegexp = regexp.MustCompile(`[\w\-.+()]+\.[A-Za-z0-9]{1,5}\b`)
+	// Matches the size, which some posters put behind the part counter, like "12345 bytes"
+	sizeRegexp = regexp.MustCompile(`(?i)\b\d+\s*bytes\b`)
+	// Matches the extensions of split archives & par2 volumes, like ".part01.rar", ".r01", ".001" or ".vol03+04.par2"
+	volumeRegexp = regexp.MustCompile(`(?i)(\.part\d+\.rar|\.vol\d+\+\d+\.par2|\.r\d{2,3}|\.\d{3}|\.[a-z0-9]{1,5})$`)
+)
+
+// Subject is the information of a binary post subject like `Show [01/20] - "show.s01e02.part01.rar" yEnc (12/87)`.
+type Subject struct {
+	// Subject without the part counter. All segments of a file share it.
+	File string
+	// File name, like "show.s01e02.part01.rar". Empty if no file name could be found.
+	Name string
+	// Subject without file name & counters, like "Show". All files of a collection share it.
+	Stem string
+	// Part counter, like "(12/87)"
+	Part nntp.SubjectPart
+	// File counter, like "[01/20]". Zero if the subject does not contain one.
+	FileCounter nntp.SubjectPart
+}
+
+// ParseSubject parses the subject of a binary post. ok is false if the subject does not have a part counter.
+func ParseSubject(subject string) (s Subject, ok bool) {
+	if s.Part, ok = nntp.ParseSubjectPart(subject); !ok {
+		return s, false
+	}
+
+	s.File = nntp.StripSubjectPart(subject)
+
+	rest := s.File
+
+	// A remaining counter numbers the files of the collection
+	if counter, ok := nntp.ParseSubjectPart(rest); ok {
+		s.FileCounter = counter
+		rest = nntp.StripSubjectPart(rest)
+	}
+
+	if match := quotedNameRegexp.FindStringSubmatchIndex(rest); match != nil {
+		s.Name = rest[match[2]:match[3]]
+		rest = rest[:match[0]] + " " + rest[match[1]:]
+	} else if match := plainNameRegexp.FindStringIndex(rest); match != nil {
+		s.Name = rest[match[0]:match[1]]
+		rest = rest[:match[0]] + " " + rest[match[1]:]
+	}
+
+	s.Name = path.Base(strings.TrimSpace(s.Name))
+	if s.Name == "." || s.Name == "/" {
+		s.Name = ""
+	}
+
+	rest = sizeRegexp.ReplaceAllString(rest, " ")
+	s.Stem = cleanStem(rest)
+
+	// Without any other text, all files named alike belong together
+	if s.Stem == "" && s.Name != "" {
+		s.Stem = baseName(s.Name)
+	}
+
+	return s, true
+}
+
+func cleanStem(s string) string {
+	fields := strings.Fields(s)
+
+	kept := fields[:0]
+
+	for _, field := range fields {
+		if strings.EqualFold(field, "yEnc") {
+			continue
+		}
+
+		kept = append(kept, field)
+	}
+
+	return strings.Trim(strings.Join(kept, " "), " -:|")
+}
+
+// baseName removes the extensions of split archives & par2 volumes, so all files of a release share it.
+func baseName(name string) string {
+	// The leftmost match wins, so ".vol03+04.par2" gets removed as a whole instead of just ".par2"
+	base := volumeRegexp.ReplaceAllString(name, "")
+
+	if base == "" {
+		return name
+	}
+
+	return base
+}
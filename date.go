@@ -0,0 +1,630 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidDateFormat = errors.New("invalid date format")
+
+// DateConfidence describes how reliable a parsed date is.
+type DateConfidence int
+
+const (
+	// DateConfidenceHigh is used for dates following the RFC 5322 syntax.
+	DateConfidenceHigh DateConfidence = iota
+	// DateConfidenceMedium is used for dates using the obsolete syntax, like two-digit years or named zones, which can
+	// be parsed unambiguously.
+	DateConfidenceMedium
+	// DateConfidenceLow is used for dates which required guessing, like unknown or missing zones.
+	DateConfidenceLow
+)
+
+func (c DateConfidence) String() string {
+	switch c {
+	case DateConfidenceHigh:
+		return "high"
+	case DateConfidenceMedium:
+		return "medium"
+	case DateConfidenceLow:
+		return "low"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(c))
+}
+
+// DateReport describes how a date got parsed.
+type DateReport struct {
+	// Date in the RFC 5322 format, like "Sun, 10 May 2020 00:32:22 +0000"
+	Normalized string
+	Confidence DateConfidence
+	// Deviations from the RFC 5322 syntax, like "two-digit year"
+	Notes []string
+}
+
+func (r *DateReport) note(confidence DateConfidence, format string, args ...interface{}) {
+	if confidence > r.Confidence {
+		r.Confidence = confidence
+	}
+
+	r.Notes = append(r.Notes, fmt.Sprintf(format, args...))
+}
+
+// Offsets of named zones in seconds east of UTC. RFC 5322 section 4.3 only defines the US zones, the others are
+// commonly found in Usenet articles.
+var dateZones = map[string]int{
+	"UT":   0,
+	"UTC":  0,
+	"GMT":  0,
+	"Z":    0,
+	"WET":  0,
+	"WEST": 1 * 3600,
+	"BST":  1 * 3600,
+	"CET":  1 * 3600,
+	"MET":  1 * 3600,
+	"MEZ":  1 * 3600,
+	"CEST": 2 * 3600,
+	"MEST": 2 * 3600,
+	"MESZ": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"MSK":  3 * 3600,
+	"JST":  9 * 3600,
+	"KST":  9 * 3600,
+	"HKT":  8 * 3600,
+	"AWST": 8 * 3600,
+	"ACST": 9*3600 + 1800,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+	"NZST": 12 * 3600,
+	"NZDT": 13 * 3600,
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"AKST": -9 * 3600,
+	"AKDT": -8 * 3600,
+	"HST":  -10 * 3600,
+}
+
+var (
+	dateMonths = map[string]time.Month{
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+		"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+		"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+	dateWeekdays = map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+)
+
+// ParseDate parses a date according to RFC 5322 section 3.3, including the obsolete syntax of section 4.3 & common
+// deviations found in Usenet articles: Missing weekdays & seconds, single-digit days, two & three-digit years, named
+// zones, "GMT+0200" style zones, comments, extra whitespace, RFC 850 dates like "Saturday, 01-Jan-83 00:00:00 GMT" &
+// asctime dates like "Sat Jan  1 00:00:00 1983".
+// Named zones are resolved to fixed offsets.
+func ParseDate(s string) (time.Time, error) {
+	if t, ok := parseDateFast(s); ok {
+		return t, nil
+	}
+
+	t, _, err := ParseDateReport(s)
+
+	return t, err
+}
+
+// ParseDateReport is like ParseDate, but additionally reports how reliable the result is.
+func ParseDateReport(s string) (time.Time, DateReport, error) {
+	var p dateParser
+
+	t, err := p.parse(s)
+	if err != nil {
+		return time.Time{}, DateReport{}, fmt.Errorf("%w: '%s': %v", ErrInvalidDateFormat, s, err)
+	}
+
+	p.report.Normalized = t.Format(time.RFC1123Z)
+
+	return t, p.report, nil
+}
+
+type dateParser struct {
+	report DateReport
+
+	weekday    time.Weekday
+	hasWeekday bool
+	day        int
+	month      time.Month
+	year       int
+	yearDigits int
+
+	hour, minute, second int
+	hasTime              bool
+
+	offset  int
+	zone    string
+	hasZone bool
+}
+
+func (p *dateParser) parse(s string) (time.Time, error) {
+	tokens, err := p.tokenize(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, token := range tokens {
+		if err := p.token(token); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	switch {
+	case p.day == 0:
+		return time.Time{}, errors.New("missing day")
+	case p.month == 0:
+		return time.Time{}, errors.New("missing month")
+	case p.yearDigits == 0:
+		return time.Time{}, errors.New("missing year")
+	case !p.hasTime:
+		return time.Time{}, errors.New("missing time")
+	}
+
+	return p.time()
+}
+
+// tokenize removes comments & splits the date at whitespace, commas & the dashes of RFC 850 dates.
+func (p *dateParser) tokenize(s string) ([]string, error) {
+	var (
+		b     strings.Builder
+		depth int
+	)
+
+	for idx := 0; idx < len(s); idx++ {
+		c := s[idx]
+
+		switch {
+		case c == '\\' && depth > 0:
+			idx++
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+
+			if depth == 0 {
+				b.WriteByte(' ')
+			}
+		case depth > 0:
+		case c == ',':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	if depth > 0 {
+		return nil, errors.New("unterminated comment")
+	}
+
+	if strings.Contains(s, "(") {
+		// Comments are valid, but some servers put the zone name in them
+		p.report.Notes = append(p.report.Notes, "comment ignored")
+	}
+
+	var tokens []string
+
+	for _, field := range strings.Fields(b.String()) {
+		// RFC 850: "01-Jan-83"
+		if parts := strings.Split(field, "-"); len(parts) > 1 && isDigits(parts[0]) {
+			if len(parts) != 3 || parts[1] == "" || parts[2] == "" || isDigits(parts[1]) {
+				return nil, fmt.Errorf("invalid RFC 850 date '%s'", field)
+			}
+
+			p.report.note(DateConfidenceMedium, "RFC 850 date")
+			tokens = append(tokens, parts...)
+
+			continue
+		}
+
+		tokens = append(tokens, field)
+	}
+
+	return tokens, nil
+}
+
+func (p *dateParser) token(token string) error {
+	if token == "" {
+		return errors.New("empty token")
+	}
+
+	lower := strings.ToLower(token)
+
+	switch {
+	case strings.Contains(token, ":") && token[0] >= '0' && token[0] <= '9':
+		return p.parseTime(token)
+	case isDigits(token):
+		return p.number(token)
+	case token[0] == '+' || token[0] == '-':
+		return p.numericZone(token, token)
+	}
+
+	if len(lower) >= 3 {
+		if month, ok := dateMonths[lower[:3]]; ok && p.month == 0 && isMonthName(lower) {
+			p.month = month
+
+			if len(lower) > 3 {
+				p.report.note(DateConfidenceMedium, "full month name")
+			}
+
+			return nil
+		}
+
+		if weekday, ok := dateWeekdays[lower[:3]]; ok && !p.hasWeekday && !p.hasTime && isWeekdayName(lower) {
+			p.weekday, p.hasWeekday = weekday, true
+
+			if len(lower) > 3 {
+				p.report.note(DateConfidenceMedium, "full weekday name")
+			}
+
+			return nil
+		}
+	}
+
+	return p.namedZone(token)
+}
+
+func (p *dateParser) number(token string) error {
+	value, err := strconv.Atoi(token)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.day == 0 && len(token) <= 2:
+		p.day = value
+	case p.yearDigits == 0:
+		p.year, p.yearDigits = value, len(token)
+	default:
+		return fmt.Errorf("unexpected number '%s'", token)
+	}
+
+	return nil
+}
+
+func (p *dateParser) parseTime(token string) error {
+	if p.hasTime {
+		return fmt.Errorf("unexpected time '%s'", token)
+	}
+
+	parts := strings.Split(token, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid time '%s'", token)
+	}
+
+	values := make([]int, 3)
+
+	for idx, part := range parts {
+		if !isDigits(part) || len(part) > 2 {
+			return fmt.Errorf("invalid time '%s'", token)
+		}
+
+		values[idx] = digitsValue(part)
+	}
+
+	if len(parts) == 2 {
+		p.report.note(DateConfidenceHigh, "missing seconds")
+	}
+
+	p.hour, p.minute, p.second = values[0], values[1], values[2]
+	p.hasTime = true
+
+	if p.hour > 23 || p.minute > 59 || p.second > 60 {
+		return fmt.Errorf("invalid time '%s'", token)
+	}
+
+	if p.second == 60 {
+		p.second = 59
+		p.report.note(DateConfidenceMedium, "leap second")
+	}
+
+	return nil
+}
+
+// numericZone parses zones like "+0200", "-05:00" or "+2".
+func (p *dateParser) numericZone(token, original string) error {
+	if p.hasZone {
+		return fmt.Errorf("unexpected zone '%s'", original)
+	}
+
+	sign := 1
+	if token[0] == '-' {
+		sign = -1
+	}
+
+	digits := strings.Replace(token[1:], ":", "", 1)
+	if !isDigits(digits) {
+		return fmt.Errorf("invalid zone '%s'", original)
+	}
+
+	var hours, minutes int
+
+	switch len(digits) {
+	case 1, 2:
+		hours = digitsValue(digits)
+	case 4:
+		hours = digitsValue(digits[:2])
+		minutes = digitsValue(digits[2:])
+	default:
+		return fmt.Errorf("invalid zone '%s'", original)
+	}
+
+	if hours > 23 || minutes > 59 {
+		return fmt.Errorf("invalid zone '%s'", original)
+	}
+
+	if len(digits) != 4 || strings.Contains(token, ":") {
+		p.report.note(DateConfidenceMedium, "non-standard numeric zone '%s'", original)
+	}
+
+	if token == "-0000" {
+		p.report.note(DateConfidenceHigh, "zone -0000: local time unknown")
+	}
+
+	p.offset = sign * (hours*3600 + minutes*60)
+	p.hasZone = true
+
+	return nil
+}
+
+func (p *dateParser) namedZone(token string) error {
+	if p.hasZone {
+		// Some servers repeat the zone name after the offset, like "+0200 CEST"
+		if _, ok := dateZones[strings.ToUpper(token)]; ok {
+			p.report.note(DateConfidenceHigh, "redundant zone name '%s'", token)
+			return nil
+		}
+
+		return fmt.Errorf("unexpected token '%s'", token)
+	}
+
+	upper := strings.ToUpper(token)
+
+	// "GMT+0200", "UTC-5"
+	if idx := strings.IndexAny(upper, "+-"); idx > 0 {
+		if offset, ok := dateZones[upper[:idx]]; ok && offset == 0 {
+			return p.numericZone(upper[idx:], token)
+		}
+	}
+
+	if offset, ok := dateZones[upper]; ok {
+		p.offset = offset
+		p.zone = upper
+		p.hasZone = true
+
+		if upper != "UT" && upper != "GMT" {
+			p.report.note(DateConfidenceMedium, "named zone '%s'", token)
+		}
+
+		return nil
+	}
+
+	// RFC 5322 section 4.3: Military zones should be treated as -0000, as their sign was commonly inverted
+	if len(upper) == 1 && upper[0] >= 'A' && upper[0] <= 'Z' && upper != "J" {
+		p.hasZone = true
+		p.report.note(DateConfidenceLow, "military zone '%s' treated as UTC", token)
+
+		return nil
+	}
+
+	if isAlpha(upper) && len(upper) <= 5 {
+		p.hasZone = true
+		p.report.note(DateConfidenceLow, "unknown zone '%s' treated as UTC", token)
+
+		return nil
+	}
+
+	return fmt.Errorf("unexpected token '%s'", token)
+}
+
+func (p *dateParser) time() (time.Time, error) {
+	year := p.year
+
+	switch p.yearDigits {
+	case 1, 2:
+		// RFC 5322 section 4.3
+		if year < 50 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+
+		p.report.note(DateConfidenceMedium, "two-digit year")
+	case 3:
+		year += 1900
+		p.report.note(DateConfidenceMedium, "three-digit year")
+	}
+
+	if p.day < 1 || p.day > daysIn(p.month, year) {
+		return time.Time{}, fmt.Errorf("invalid day %d", p.day)
+	}
+
+	if !p.hasZone {
+		p.report.note(DateConfidenceLow, "missing zone treated as UTC")
+	}
+
+	location := fixedZone(p.zone, p.offset)
+	t := time.Date(year, p.month, p.day, p.hour, p.minute, p.second, 0, location)
+
+	if p.hasWeekday && t.Weekday() != p.weekday {
+		p.report.note(DateConfidenceLow, "weekday %s does not match date", p.weekday)
+	}
+
+	return t, nil
+}
+
+type zoneKey struct {
+	name   string
+	offset int
+}
+
+// Locations by zone, as time.FixedZone allocates
+var zoneCache sync.Map
+
+func fixedZone(name string, offset int) *time.Location {
+	key := zoneKey{name: name, offset: offset}
+
+	if location, ok := zoneCache.Load(key); ok {
+		return location.(*time.Location)
+	}
+
+	location, _ := zoneCache.LoadOrStore(key, time.FixedZone(name, offset))
+
+	return location.(*time.Location)
+}
+
+// parseDateFast parses the most common format "[Mon, ]2 Jan 2006 15:04:05 -0700" without allocations. ok is false
+// for all other dates, which need the tokenizing parser.
+func parseDateFast(s string) (t time.Time, ok bool) {
+	// Optional weekday
+	if len(s) > 5 && s[3] == ',' && s[4] == ' ' {
+		if _, ok := dateWeekdays[lowerName(s[:3])]; !ok {
+			return t, false
+		}
+
+		s = s[5:]
+	}
+
+	var day int
+
+	switch {
+	case len(s) == 26 && s[2] == ' ' && isDigits(s[:2]):
+		day, s = digitsValue(s[:2]), s[3:]
+	case len(s) == 25 && s[1] == ' ' && isDigits(s[:1]):
+		day, s = digitsValue(s[:1]), s[2:]
+	default:
+		return t, false
+	}
+
+	// "Jan 2006 15:04:05 -0700"
+	if !isDigits(s[4:8]) || !isDigits(s[9:11]) || !isDigits(s[12:14]) || !isDigits(s[15:17]) || !isDigits(s[19:23]) ||
+		s[3] != ' ' || s[8] != ' ' || s[11] != ':' || s[14] != ':' || s[17] != ' ' || (s[18] != '+' && s[18] != '-') {
+		return t, false
+	}
+
+	month, ok := dateMonths[lowerName(s[:3])]
+	if !ok {
+		return t, false
+	}
+
+	year := digitsValue(s[4:8])
+	hour, minute, second := digitsValue(s[9:11]), digitsValue(s[12:14]), digitsValue(s[15:17])
+	zoneHours, zoneMinutes := digitsValue(s[19:21]), digitsValue(s[21:23])
+
+	if day < 1 || day > daysIn(month, year) || hour > 23 || minute > 59 || second > 59 ||
+		zoneHours > 23 || zoneMinutes > 59 {
+		return t, false
+	}
+
+	offset := zoneHours*3600 + zoneMinutes*60
+	if s[18] == '-' {
+		offset = -offset
+	}
+
+	return time.Date(year, month, day, hour, minute, second, 0, fixedZone("", offset)), true
+}
+
+// lowerName lowercases the first three letters of a month or weekday name. Map lookups using the result don't
+// allocate.
+func lowerName(s string) string {
+	var buf [3]byte
+
+	n := copy(buf[:], s)
+	for idx := 0; idx < n; idx++ {
+		if buf[idx] >= 'A' && buf[idx] <= 'Z' {
+			buf[idx] += 'a' - 'A'
+		}
+	}
+
+	return string(buf[:n])
+}
+
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] < '0' || s[idx] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// digitsValue returns the value of a short string for which isDigits is true.
+func digitsValue(s string) int {
+	var value int
+
+	for idx := 0; idx < len(s); idx++ {
+		value = value*10 + int(s[idx]-'0')
+	}
+
+	return value
+}
+
+func isAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for idx := 0; idx < len(s); idx++ {
+		if (s[idx] < 'a' || s[idx] > 'z') && (s[idx] < 'A' || s[idx] > 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isMonthName(lower string) bool {
+	if len(lower) == 3 {
+		return true
+	}
+
+	for _, name := range []string{
+		"january", "february", "march", "april", "may", "june",
+		"july", "august", "september", "october", "november", "december", "sept",
+	} {
+		if lower == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWeekdayName(lower string) bool {
+	if len(lower) == 3 {
+		return true
+	}
+
+	for _, name := range []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"} {
+		if lower == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package nntp_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestParseDateReport(t *testing.T) {
+	tests := []struct {
+		date               string
+		expected           time.Time
+		expectedConfidence nntp.DateConfidence
+	}{
+		{
+			date:               "Sun, 10 May 2020 00:32:22 +0000",
+			expected:           time.Date(2020, 5, 10, 0, 32, 22, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceHigh,
+		},
+		{
+			date:               "10 May 2020 00:32:22 EDT",
+			expected:           time.Date(2020, 5, 10, 4, 32, 22, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceMedium,
+		},
+		{
+			date:               "Sun, 3 May 2020 00:32:22 UT",
+			expected:           time.Date(2020, 5, 3, 0, 32, 22, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceHigh,
+		},
+		{
+			date:               "Sun, 10 May 98 00:32 -0500",
+			expected:           time.Date(1998, 5, 10, 5, 32, 0, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceMedium,
+		},
+		{
+			date:               "Sun, 10 May 2020 00:32:22 GMT+0200",
+			expected:           time.Date(2020, 5, 9, 22, 32, 22, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceHigh,
+		},
+		{
+			date:               "  Sun,  10   May 2020  00:32:22   +0200  (CEST)  ",
+			expected:           time.Date(2020, 5, 9, 22, 32, 22, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceHigh,
+		},
+		{
+			date:               "Saturday, 01-Jan-83 00:00:00 GMT",
+			expected:           time.Date(1983, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceMedium,
+		},
+		{
+			date:               "Sat Jan  1 00:00:00 1983",
+			expected:           time.Date(1983, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceLow,
+		},
+		{
+			date:               "Mon, 10 May 2020 00:32:22 XYZ",
+			expected:           time.Date(2020, 5, 10, 0, 32, 22, 0, time.UTC),
+			expectedConfidence: nntp.DateConfidenceLow,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.date, func(t *testing.T) {
+			date, report, err := nntp.ParseDateReport(test.date)
+			require.NoError(t, err, "Failed to parse date")
+
+			assert.True(t, test.expected.Equal(date), "Expected %s, got %s", test.expected, date)
+			assert.Equal(t, test.expectedConfidence, report.Confidence, "Unexpected confidence. Notes: %v", report.Notes)
+			assert.Equal(t, date.Format("Mon, 02 Jan 2006 15:04:05 -0700"), report.Normalized)
+		})
+	}
+}
+
+func TestParseDateReport_Notes(t *testing.T) {
+	_, report, err := nntp.ParseDateReport("Sat, 10 May 98 00:32:22 EDT (comment)")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Sun, 10 May 1998 00:32:22 -0400", report.Normalized)
+	assert.Equal(t, nntp.DateConfidenceLow, report.Confidence)
+	assert.Equal(t, []string{
+		"comment ignored",
+		"named zone 'EDT'",
+		"two-digit year",
+		"weekday Saturday does not match date",
+	}, report.Notes)
+}
+
+func TestParseDate_Invalid(t *testing.T) {
+	dates := []string{
+		"",
+		"not a date",
+		"10 May 2020",
+		"32 May 2020 00:32:22 +0000",
+		"29 Feb 2021 00:32:22 +0000",
+		"10 May 2020 24:32:22 +0000",
+		"10 May 2020 00:32:22 +2500",
+		"10 May 2020 00:32:22 +0000 (unterminated",
+		"10 May 2020 00:32:22 +0000 +0100",
+		"01-Jan- 00:00:00 GMT",
+		"01--83 00:00:00 GMT",
+		"Tue, 1: Oct 1998 04:38:40 -0500",
+		"0: Oct 1998 04:38:40 -0500",
+	}
+
+	for _, s := range dates {
+		s := s
+		t.Run(s, func(t *testing.T) {
+			_, err := nntp.ParseDate(s)
+			assert.True(t, errors.Is(err, nntp.ErrInvalidDateFormat), "Expected ErrInvalidDateFormat, got %v", err)
+		})
+	}
+}
+
+func TestParseDate_FastPath(t *testing.T) {
+	dates := []string{
+		"Sun, 10 May 2020 00:32:22 +0000",
+		"sun, 10 may 2020 00:32:22 +0000",
+		"Mon, 10 May 2020 00:32:22 +0000",
+		"1 Jan 2020 12:34:56 +0100",
+		"31 Dec 1999 23:59:59 -0930",
+		"29 Feb 2021 00:32:22 +0000",
+		"10 May 2020 00:32:60 +0000",
+		"10 Foo 2020 00:32:22 +0000",
+	}
+
+	for _, s := range dates {
+		s := s
+		t.Run(s, func(t *testing.T) {
+			date, err := nntp.ParseDate(s)
+			expected, _, expectedErr := nntp.ParseDateReport(s)
+
+			assert.Equal(t, expectedErr, err, "Fast path must fail like the tokenizing parser")
+			assert.Equal(t, expected, date, "Fast path must return the same date as the tokenizing parser")
+		})
+	}
+}
+
+func BenchmarkParseDate(b *testing.B) {
+	dates := []string{
+		"Tue, 06 Oct 1998 04:38:40 -0500",
+		"Tuesday, 06-Oct-98 04:38:40 EDT",
+	}
+
+	for _, date := range dates {
+		date := date
+		b.Run(date, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := nntp.ParseDate(date); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
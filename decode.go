@@ -0,0 +1,390 @@
+package nntp
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OverviewUnmarshaler is implemented by types which decode a raw overview field themselves.
+// For ":full" fields the value is passed without the field name prefix.
+type OverviewUnmarshaler interface {
+	UnmarshalOverview(value string) error
+}
+
+// NumberField can be used as tag to decode the article number, which is not part of the overview format.
+const NumberField = ":number"
+
+var (
+	ErrInvalidDecodeTarget  = errors.New("invalid decode target")
+	ErrMissingOverviewField = errors.New("overview format does not contain field")
+)
+
+var (
+	overviewUnmarshalerType = reflect.TypeOf((*OverviewUnmarshaler)(nil)).Elem()
+	textUnmarshalerType     = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType                = reflect.TypeOf(time.Time{})
+	messageIDsType          = reflect.TypeOf([]MessageID(nil))
+)
+
+// OverviewDecoder decodes overview lines into a struct type. The fields of the struct are mapped to the overview format
+// by their "nntp" tag, like:
+//
+//	type Post struct {
+//		Number  uint64     `nntp:":number"`
+//		Subject string     `nntp:"Subject:,decode"`
+//		Poster  nntp.Author `nntp:"From:"`
+//		Date    time.Time  `nntp:"Date:"`
+//		Xref    nntp.Xref  `nntp:"Xref:full,required"`
+//	}
+//
+// Tag names are matched case-insensitively & regardless of their colon & ":full" suffix, so "Bytes:" matches ":bytes"
+// & "Xref" matches "Xref:full". Supported options are "required", which fails compilation if the format lacks the
+// field, & "decode", which decodes encoded-words using the decoder of the format or a default one.
+// Supported field types are implementations of OverviewUnmarshaler & encoding.TextUnmarshaler, strings, integers,
+// floats, booleans, time.Time (see ParseDate), []MessageID (see ParseReferences) & pointers to them. Empty values leave
+// numbers at zero. Fields without tag or with tag "-" are ignored.
+//
+// The mapping is compiled once, so a decoder should be reused for all lines of the same format.
+type OverviewDecoder struct {
+	typ reflect.Type
+	// Decoder of the format or a default one, used for the "decode" option
+	headerDecoder *HeaderDecoder
+	// Operations by column. The first column is the article number.
+	columns [][]decodeOp
+}
+
+type decodeOp struct {
+	index  []int
+	set    func(field reflect.Value, value string) error
+	decode bool
+	full   string
+	name   string
+}
+
+// NewOverviewDecoder compiles the mapping of format to the struct type of v, which may be a struct or pointer to one.
+func NewOverviewDecoder(format *OverviewFormat, v interface{}) (*OverviewDecoder, error) {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is no struct", ErrInvalidDecodeTarget, v)
+	}
+
+	return compileOverviewDecoder(format, typ)
+}
+
+func compileOverviewDecoder(format *OverviewFormat, typ reflect.Type) (*OverviewDecoder, error) {
+	decoder := &OverviewDecoder{
+		typ:           typ,
+		headerDecoder: format.decoder,
+		columns:       make([][]decodeOp, len(format.fieldNames)+1),
+	}
+
+	if decoder.headerDecoder == nil {
+		decoder.headerDecoder = NewHeaderDecoder()
+	}
+
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := typ.Field(idx)
+
+		tag, ok := field.Tag.Lookup("nntp")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if field.PkgPath != "" {
+			return nil, fmt.Errorf("%w: field %s is unexported", ErrInvalidDecodeTarget, field.Name)
+		}
+
+		options := strings.Split(tag, ",")
+
+		set, err := fieldSetter(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		op := decodeOp{index: field.Index, set: set, name: options[0]}
+
+		var required bool
+
+		for _, option := range options[1:] {
+			switch option {
+			case "required":
+				required = true
+			case "decode":
+				op.decode = true
+			default:
+				return nil, fmt.Errorf("%w: field %s has unknown tag option '%s'", ErrInvalidDecodeTarget, field.Name, option)
+			}
+		}
+
+		column := overviewColumn(format, options[0])
+		if column < 0 {
+			if required {
+				return nil, fmt.Errorf("%w: '%s'", ErrMissingOverviewField, options[0])
+			}
+
+			continue
+		}
+
+		if column > 0 && strings.HasSuffix(format.lowercaseFieldNames[column-1], ":full") {
+			op.full = strings.TrimSuffix(format.fieldNames[column-1], "full")
+		}
+
+		decoder.columns[column] = append(decoder.columns[column], op)
+	}
+
+	return decoder, nil
+}
+
+// overviewColumn returns the column of the field within the line, or -1.
+func overviewColumn(format *OverviewFormat, name string) int {
+	if strings.EqualFold(name, NumberField) {
+		return 0
+	}
+
+	name = normalizeFieldName(name)
+
+	for idx, fieldName := range format.lowercaseFieldNames {
+		if normalizeFieldName(fieldName) == name {
+			return idx + 1
+		}
+	}
+
+	return -1
+}
+
+func normalizeFieldName(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ":full")
+
+	return strings.Trim(name, ":")
+}
+
+func fieldSetter(typ reflect.Type) (func(reflect.Value, string) error, error) {
+	switch {
+	// time.Time implements encoding.TextUnmarshaler, but only for RFC 3339
+	case typ == timeType:
+		return func(field reflect.Value, value string) error {
+			if strings.TrimSpace(value) == "" {
+				return nil
+			}
+
+			date, err := ParseDate(value)
+			if err != nil {
+				return err
+			}
+
+			field.Set(reflect.ValueOf(date))
+
+			return nil
+		}, nil
+	case reflect.PtrTo(typ).Implements(overviewUnmarshalerType):
+		return func(field reflect.Value, value string) error {
+			return field.Addr().Interface().(OverviewUnmarshaler).UnmarshalOverview(value)
+		}, nil
+	case reflect.PtrTo(typ).Implements(textUnmarshalerType):
+		return func(field reflect.Value, value string) error {
+			return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		}, nil
+	case typ == messageIDsType:
+		return func(field reflect.Value, value string) error {
+			field.Set(reflect.ValueOf(ParseReferences(value)))
+			return nil
+		}, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		set, err := fieldSetter(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return func(field reflect.Value, value string) error {
+			if field.IsNil() {
+				field.Set(reflect.New(typ.Elem()))
+			}
+
+			return set(field.Elem(), value)
+		}, nil
+	case reflect.String:
+		return func(field reflect.Value, value string) error {
+			field.SetString(value)
+			return nil
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(field reflect.Value, value string) error {
+			if value = strings.TrimSpace(value); value == "" {
+				return nil
+			}
+
+			i, err := strconv.ParseInt(value, 10, typ.Bits())
+			if err != nil {
+				return err
+			}
+
+			field.SetInt(i)
+
+			return nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(field reflect.Value, value string) error {
+			if value = strings.TrimSpace(value); value == "" {
+				return nil
+			}
+
+			u, err := strconv.ParseUint(value, 10, typ.Bits())
+			if err != nil {
+				return err
+			}
+
+			field.SetUint(u)
+
+			return nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(field reflect.Value, value string) error {
+			if value = strings.TrimSpace(value); value == "" {
+				return nil
+			}
+
+			f, err := strconv.ParseFloat(value, typ.Bits())
+			if err != nil {
+				return err
+			}
+
+			field.SetFloat(f)
+
+			return nil
+		}, nil
+	case reflect.Bool:
+		return func(field reflect.Value, value string) error {
+			if value = strings.TrimSpace(value); value == "" {
+				return nil
+			}
+
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+
+			field.SetBool(b)
+
+			return nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidDecodeTarget, typ)
+}
+
+// Decode decodes a single overview line into v, which must be a pointer to the struct type of the decoder.
+// Failing fields are returned as FieldError. Its index is -1 for the article number.
+func (d *OverviewDecoder) Decode(line string, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Type() != d.typ {
+		return fmt.Errorf("%w: expected *%s, got %T", ErrInvalidDecodeTarget, d.typ, v)
+	}
+
+	return d.decode(line, target.Elem(), d.headerDecoder)
+}
+
+// decode uses the header decoder instead of the compiled one if set, as the decoder of a format may change after the
+// decoder got cached.
+func (d *OverviewDecoder) decode(line string, target reflect.Value, headerDecoder *HeaderDecoder) error {
+	if headerDecoder == nil {
+		headerDecoder = d.headerDecoder
+	}
+
+	column := 0
+
+	for start := 0; column < len(d.columns); column++ {
+		end := strings.IndexByte(line[start:], '\t')
+		if end < 0 {
+			end = len(line)
+		} else {
+			end += start
+		}
+
+		value := line[start:end]
+
+		for _, op := range d.columns[column] {
+			if err := op.apply(target, value, headerDecoder); err != nil {
+				return FieldError{Index: column - 1, Field: op.name, Value: value, Err: err}
+			}
+		}
+
+		if end == len(line) {
+			break
+		}
+
+		start = end + 1
+	}
+
+	return nil
+}
+
+func (op decodeOp) apply(target reflect.Value, value string, headerDecoder *HeaderDecoder) error {
+	if op.full != "" && len(value) >= len(op.full) && strings.EqualFold(value[:len(op.full)], op.full) {
+		value = value[len(op.full):]
+	}
+
+	if op.full != "" || op.decode {
+		value = strings.TrimSpace(value)
+	}
+
+	if op.decode {
+		value = headerDecoder.Decode(value)
+	}
+
+	return op.set(target.FieldByIndex(op.index), value)
+}
+
+// Unmarshal decodes a single overview line into v, which must be a pointer to a struct. See OverviewDecoder for the
+// supported tags. The compiled mapping is cached per struct type.
+func (h *OverviewFormat) Unmarshal(line string, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is no pointer to a struct", ErrInvalidDecodeTarget, v)
+	}
+
+	decoder, err := h.decoderFor(target.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	return decoder.decode(line, target.Elem(), h.decoder)
+}
+
+func (h *OverviewFormat) decoderFor(typ reflect.Type) (*OverviewDecoder, error) {
+	if cached, ok := h.decoders.Load(typ); ok {
+		return cached.(*OverviewDecoder), nil
+	}
+
+	decoder, err := compileOverviewDecoder(h, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	h.decoders.Store(typ, decoder)
+
+	return decoder, nil
+}
+
+// UnmarshalOverview parses the value of an Xref field.
+func (x *Xref) UnmarshalOverview(value string) (err error) {
+	*x, err = ParseXref(value)
+	return err
+}
+
+// UnmarshalOverview parses the value of a From field.
+func (a *Author) UnmarshalOverview(value string) error {
+	*a = ParseAuthor(value)
+	return nil
+}
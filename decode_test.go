@@ -0,0 +1,109 @@
+package nntp_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+type upperString string
+
+func (s *upperString) UnmarshalOverview(value string) error {
+	*s = upperString(strings.ToUpper(value))
+	return nil
+}
+
+type testPost struct {
+	Number     uint64           `nntp:":number"`
+	Subject    string           `nntp:"Subject:,decode"`
+	RawSubject upperString      `nntp:"Subject:"`
+	Poster     nntp.Author      `nntp:"From:"`
+	Date       time.Time        `nntp:"Date:"`
+	MessageID  nntp.MessageID   `nntp:"Message-ID:"`
+	References []nntp.MessageID `nntp:"References:"`
+	Bytes      *int             `nntp:"Bytes:"`
+	Lines      uint32           `nntp:":lines"`
+	Xref       nntp.Xref        `nntp:"Xref,required"`
+	Ignored    string
+}
+
+const testOverviewLine = "3000234\t=?UTF-8?Q?gr=C3=BC=C3=9Fe?=\t\"Test Author\" <test@example.com>\t" +
+	"6 Oct 1998 04:38:40 -0500\t<id@example.com>\t<ref1@example.net> <ref2@example.net>\t" +
+	"1234\t17\tXref: news.example.com misc.test:3000234"
+
+func testOverviewFormat() *nntp.OverviewFormat {
+	return nntp.NewOverviewFormat([]string{
+		"Subject:",
+		"From:",
+		"Date:",
+		"Message-ID:",
+		"References:",
+		":bytes",
+		":lines",
+		"Xref:full",
+	})
+}
+
+func TestOverviewFormat_Unmarshal(t *testing.T) {
+	var post testPost
+	require.NoError(t, testOverviewFormat().Unmarshal(testOverviewLine, &post), "Failed to decode line")
+
+	bytes := 1234
+	assert.Equal(t, testPost{
+		Number:     3000234,
+		Subject:    "grüße",
+		RawSubject: "=?UTF-8?Q?GR=C3=BC=C3=9FE?=",
+		Poster:     nntp.Author{Name: "Test Author", Address: "test@example.com", Key: "test@example.com"},
+		Date:       time.Date(1998, 10, 6, 4, 38, 40, 0, time.FixedZone("", -5*3600)),
+		MessageID:  "<id@example.com>",
+		References: []nntp.MessageID{"<ref1@example.net>", "<ref2@example.net>"},
+		Bytes:      &bytes,
+		Lines:      17,
+		Xref: nntp.Xref{
+			Server:  "news.example.com",
+			Entries: []nntp.XrefEntry{{Group: "misc.test", Number: 3000234}},
+		},
+	}, post)
+}
+
+func TestOverviewDecoder_Decode(t *testing.T) {
+	decoder, err := nntp.NewOverviewDecoder(testOverviewFormat(), testPost{})
+	require.NoError(t, err, "Failed to compile decoder")
+
+	var post testPost
+	require.NoError(t, decoder.Decode("1\tsubject\tauthor\t\t<id@example.com>", &post), "Failed to decode short line")
+	assert.Equal(t, uint64(1), post.Number)
+	assert.Equal(t, "subject", post.Subject)
+	assert.True(t, post.Date.IsZero(), "Empty date must be left zero")
+	assert.Nil(t, post.Bytes, "Missing field must be left nil")
+
+	err = decoder.Decode("1\tsubject\tauthor\tnot a date", &post)
+
+	var fieldError nntp.FieldError
+	require.True(t, errors.As(err, &fieldError), "Expected FieldError, got %v", err)
+	assert.Equal(t, 2, fieldError.Index)
+	assert.Equal(t, "Date:", fieldError.Field)
+	assert.True(t, errors.Is(err, nntp.ErrInvalidDateFormat), "Expected ErrInvalidDateFormat, got %v", err)
+
+	err = decoder.Decode("1", &struct{}{})
+	assert.True(t, errors.Is(err, nntp.ErrInvalidDecodeTarget), "Expected ErrInvalidDecodeTarget, got %v", err)
+}
+
+func TestNewOverviewDecoder_Invalid(t *testing.T) {
+	_, err := nntp.NewOverviewDecoder(nntp.DefaultOverviewFormat(), testPost{})
+	assert.True(t, errors.Is(err, nntp.ErrMissingOverviewField), "Expected ErrMissingOverviewField, got %v", err)
+
+	_, err = nntp.NewOverviewDecoder(nntp.DefaultOverviewFormat(), struct {
+		Subject chan int `nntp:"Subject:"`
+	}{})
+	assert.True(t, errors.Is(err, nntp.ErrInvalidDecodeTarget), "Expected ErrInvalidDecodeTarget, got %v", err)
+
+	_, err = nntp.NewOverviewDecoder(nntp.DefaultOverviewFormat(), "no struct")
+	assert.True(t, errors.Is(err, nntp.ErrInvalidDecodeTarget), "Expected ErrInvalidDecodeTarget, got %v", err)
+}
@@ -0,0 +1,679 @@
+// Package download fetches the files of an NZB document & assembles them on disk.
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/nzb"
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+// Fetcher retrieves article bodies. It is implemented by nntp.Client & nntp.Failover.
+type Fetcher interface {
+	Body(id string) (nntp.Article, error)
+}
+
+type EventType string
+
+const (
+	// A segment got downloaded, decoded & written
+	EventSegmentDone EventType = "segment-done"
+	// A segment attempt failed & the segment will be retried
+	EventSegmentRetry EventType = "segment-retry"
+	// All attempts of a segment failed
+	EventSegmentFailed EventType = "segment-failed"
+	// All segments of a file have been processed
+	EventFileDone EventType = "file-done"
+)
+
+type Event struct {
+	Type EventType
+	// Index of the file within the NZB
+	File    int
+	Segment nzb.Segment
+	Attempt int
+	Err     error
+
+	// Progress of the whole download at the time of the event
+	DoneSegments   int
+	FailedSegments int
+	TotalSegments  int
+	// Decoded bytes written to disk
+	WrittenBytes int64
+
+	// Only set for EventSegmentDone: The yEnc metadata of the segment & the file it got written to
+	Header   yenc.Header
+	Trailer  yenc.Trailer
+	FileInfo FileInfo
+}
+
+// FileInfo describes a file assembled on disk.
+type FileInfo struct {
+	// Name announced by the yEnc header
+	Name string
+	Path string
+	Size int64
+
+	ExpectedCRC32    uint32
+	HasExpectedCRC32 bool
+}
+
+type CRCStatus string
+
+const (
+	CRCValid   CRCStatus = "valid"
+	CRCInvalid CRCStatus = "invalid"
+	// None of the downloaded segments contained the checksum of the whole file
+	CRCUnknown CRCStatus = "unknown"
+	// No segment of the file could be downloaded
+	CRCMissing CRCStatus = "missing"
+)
+
+type FileResult struct {
+	Subject string
+	// Name announced by the yEnc header & Path of the assembled file. Both are empty if no segment could be downloaded.
+	Name string
+	Path string
+	Size int64
+
+	CRCStatus     CRCStatus
+	CRC32         uint32
+	ExpectedCRC32 uint32
+
+	MissingSegments []nzb.Segment
+}
+
+type MissingSegment struct {
+	File    int
+	Segment nzb.Segment
+	Err     error
+}
+
+type Result struct {
+	Files   []FileResult
+	Missing []MissingSegment
+}
+
+var (
+	ErrNoFetchers = errors.New("no fetchers configured")
+
+	ErrNameMismatch = errors.New("segment belongs to a different file")
+
+	ErrInvalidPart = errors.New("yEnc part doesn't fit into the file")
+)
+
+// Downloader downloads the segments of an NZB concurrently, by default using one worker per fetcher.
+// Decoded parts are written at their yEnc offsets into files within Dir, which get pre-allocated to their full size.
+// A Downloader must not be used for multiple downloads at the same time.
+type Downloader struct {
+	Fetchers []Fetcher
+	Dir      string
+	// Number of attempts per segment. Each attempt uses the next fetcher. Defaults to 3.
+	Attempts int
+	// Events receives progress events if set. It must be drained, otherwise the download blocks.
+	Events chan<- Event
+	// Connections limits the number of concurrent workers. Defaults to one worker per fetcher.
+	Connections int
+	// BytesPerSecond limits the download rate by delaying requests according to the segment sizes listed by the NZB.
+	// 0 means unlimited.
+	BytesPerSecond int64
+
+	// Skip reports segments which have been downloaded by an earlier run. Skipped segments count as done.
+	Skip func(file int, segment nzb.Segment) bool
+	// Resume contains the files written by an earlier run, keyed by their index within the NZB.
+	// Their content is kept, so only the missing segments need to be downloaded. Other existing files get replaced.
+	Resume map[int]FileInfo
+
+	lock     sync.Mutex
+	files    []*fileState
+	progress Event
+	limiter  *rateLimiter
+	// Index of the file written to a path, so files with the same name don't overwrite each other
+	paths map[string]int
+}
+
+type fileState struct {
+	subject string
+	// Number of segments not processed yet
+	remaining int
+
+	name string
+	path string
+	size int64
+	f    *os.File
+	// Encoded size of all segments listed by the NZB, which limits the size announced by the yEnc headers. 0 if unknown.
+	maxSize int64
+
+	expectedCRC32    uint32
+	hasExpectedCRC32 bool
+
+	missing []MissingSegment
+}
+
+type job struct {
+	file    int
+	segment nzb.Segment
+}
+
+func New(dir string, fetchers ...Fetcher) *Downloader {
+	return &Downloader{
+		Fetchers: fetchers,
+		Dir:      dir,
+		Attempts: 3,
+	}
+}
+
+// Download fetches all segments of the NZB. Segments which can't be downloaded are reported in the result. The
+// returned error is only set if the download couldn't be completed, like when the context gets cancelled.
+func (d *Downloader) Download(ctx context.Context, n *nzb.NZB) (*Result, error) {
+	if len(d.Fetchers) == 0 {
+		return nil, ErrNoFetchers
+	}
+
+	d.files = make([]*fileState, len(n.Files))
+	d.paths = map[string]int{}
+	d.progress = Event{}
+	d.limiter = newRateLimiter(d.BytesPerSecond)
+
+	defer d.closeFiles()
+
+	var jobs []job
+
+	for fileIdx := range n.Files {
+		file := n.Files[fileIdx]
+		file.Segments = append([]nzb.Segment(nil), file.Segments...)
+		file.Normalize()
+
+		state := &fileState{
+			subject: file.Subject,
+			maxSize: int64(file.Bytes()),
+		}
+		d.files[fileIdx] = state
+
+		if info, ok := d.Resume[fileIdx]; ok {
+			if err := state.resume(info); err != nil {
+				return nil, fmt.Errorf("failed to resume '%s': %w", info.Path, err)
+			}
+
+			d.paths[info.Path] = fileIdx
+		}
+
+		for _, segment := range file.Segments {
+			d.progress.TotalSegments++
+
+			if d.Skip != nil && d.Skip(fileIdx, segment) {
+				d.progress.DoneSegments++
+				continue
+			}
+
+			state.remaining++
+			jobs = append(jobs, job{file: fileIdx, segment: segment})
+		}
+	}
+
+	if err := d.run(ctx, jobs); err != nil {
+		return nil, err
+	}
+
+	return d.result()
+}
+
+func (d *Downloader) run(ctx context.Context, jobs []job) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobChan := make(chan job)
+
+	var (
+		wg       sync.WaitGroup
+		errLock  sync.Mutex
+		firstErr error
+	)
+
+	workers := d.Connections
+	if workers < 1 {
+		workers = len(d.Fetchers)
+	}
+
+	for idx := 0; idx < workers; idx++ {
+		wg.Add(1)
+
+		go func(worker int) {
+			defer wg.Done()
+
+			for j := range jobChan {
+				if err := d.process(ctx, worker, j); err != nil {
+					errLock.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errLock.Unlock()
+
+					cancel()
+				}
+			}
+		}(idx)
+	}
+
+feed:
+	for _, j := range jobs {
+		select {
+		case jobChan <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobChan)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// process downloads a single segment. Only errors which should abort the whole download get returned.
+func (d *Downloader) process(ctx context.Context, worker int, j job) error {
+	attempts := d.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fetcher := d.Fetchers[(worker+attempt)%len(d.Fetchers)]
+
+		var event Event
+		if event, err = d.fetch(ctx, fetcher, j); err == nil {
+			return d.segmentDone(ctx, j, attempt, event)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			// Problems with the target directory won't go away by retrying
+			return err
+		}
+
+		if attempt+1 < attempts {
+			d.lock.Lock()
+			event := d.event(EventSegmentRetry, j, attempt, err)
+			d.lock.Unlock()
+
+			if err := d.emit(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.segmentFailed(ctx, j, attempts-1, err)
+}
+
+// fetch downloads, decodes & writes a single segment. The returned event contains the metadata of the segment.
+func (d *Downloader) fetch(ctx context.Context, fetcher Fetcher, j job) (event Event, err error) {
+	// The rate is limited before the request, using the size listed by the NZB
+	if err := d.limiter.wait(ctx, int64(j.segment.Bytes)); err != nil {
+		return event, err
+	}
+
+	article, err := fetcher.Body(j.segment.ArticleID())
+	if err != nil {
+		return event, err
+	}
+
+	// Without a listed size, the limit can only apply to the following requests
+	if j.segment.Bytes == 0 {
+		if err := d.limiter.wait(ctx, int64(len(article.Body))); err != nil {
+			return event, err
+		}
+	}
+
+	decoder := yenc.NewDecoder(bytes.NewReader(article.Body))
+
+	data, err := ioutil.ReadAll(decoder)
+	if err != nil {
+		return event, fmt.Errorf("failed to decode segment %d: %w", j.segment.Number, err)
+	}
+
+	if event.Header, err = decoder.Header(); err != nil {
+		return event, err
+	}
+
+	event.Trailer = decoder.Trailer()
+
+	if err := validatePart(event.Header, int64(len(data))); err != nil {
+		return event, fmt.Errorf("segment %d: %w", j.segment.Number, err)
+	}
+
+	f, err := d.open(j.file, event.Header, event.Trailer)
+	if err != nil {
+		return event, err
+	}
+
+	if _, err := f.WriteAt(data, event.Header.Offset()); err != nil {
+		return event, err
+	}
+
+	event.WrittenBytes = int64(len(data))
+
+	return event, nil
+}
+
+// validatePart checks that the decoded data of a part lies within the announced file size.
+func validatePart(header yenc.Header, decoded int64) error {
+	switch {
+	case header.Size < 0:
+		return fmt.Errorf("%w: invalid size %d", ErrInvalidPart, header.Size)
+	case header.Begin > 0 && (header.End < header.Begin || header.End > header.Size):
+		return fmt.Errorf("%w: range %d-%d, size %d", ErrInvalidPart, header.Begin, header.End, header.Size)
+	case header.Offset()+decoded > header.Size:
+		return fmt.Errorf("%w: %d bytes at offset %d, size %d", ErrInvalidPart, decoded, header.Offset(), header.Size)
+	}
+
+	return nil
+}
+
+// open returns the target file of a segment. The file gets created & pre-allocated by the first decoded segment.
+func (d *Downloader) open(fileIdx int, header yenc.Header, trailer yenc.Trailer) (*os.File, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	state := d.files[fileIdx]
+
+	if trailer.HasCRC32 {
+		state.expectedCRC32 = trailer.CRC32
+		state.hasExpectedCRC32 = true
+	}
+
+	if state.f != nil {
+		if header.Name != state.name || header.Size != state.size {
+			return nil, fmt.Errorf("%w: expected '%s' (%d bytes), got '%s' (%d bytes)", ErrNameMismatch, state.name, state.size, header.Name, header.Size)
+		}
+
+		return state.f, nil
+	}
+
+	// The size is announced by the poster, don't pre-allocate more than the NZB lists
+	if state.maxSize > 0 && header.Size > state.maxSize {
+		return nil, fmt.Errorf("%w: '%s' announces %d bytes, the NZB lists %d",
+			ErrInvalidPart, header.Name, header.Size, state.maxSize)
+	}
+
+	name := sanitizeName(header.Name)
+	if name == "" {
+		name = fmt.Sprintf("file-%d", fileIdx)
+	}
+
+	path := filepath.Join(d.Dir, name)
+	if other, ok := d.paths[path]; ok && other != fileIdx {
+		ext := filepath.Ext(name)
+		path = filepath.Join(d.Dir, fmt.Sprintf("%s.%d%s", strings.TrimSuffix(name, ext), fileIdx, ext))
+	}
+
+	d.paths[path] = fileIdx
+
+	// Files of earlier runs are passed as Resume, so existing content at the path is stale
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(header.Size); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	state.name, state.path, state.size, state.f = header.Name, path, header.Size, f
+
+	return f, nil
+}
+
+func (state *fileState) resume(info FileInfo) error {
+	f, err := os.OpenFile(info.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(info.Size); err != nil {
+		f.Close()
+		return err
+	}
+
+	state.name, state.path, state.size, state.f = info.Name, info.Path, info.Size, f
+	state.expectedCRC32, state.hasExpectedCRC32 = info.ExpectedCRC32, info.HasExpectedCRC32
+
+	return nil
+}
+
+func (state *fileState) info() FileInfo {
+	return FileInfo{
+		Name:             state.name,
+		Path:             state.path,
+		Size:             state.size,
+		ExpectedCRC32:    state.expectedCRC32,
+		HasExpectedCRC32: state.hasExpectedCRC32,
+	}
+}
+
+// sanitizeName makes sure the name announced by the poster can't escape the target directory.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+
+		return r
+	}, strings.TrimSpace(name))
+
+	if name == "." || name == ".." {
+		return ""
+	}
+
+	return name
+}
+
+func (d *Downloader) segmentDone(ctx context.Context, j job, attempt int, segment Event) error {
+	d.lock.Lock()
+	d.progress.DoneSegments++
+	d.progress.WrittenBytes += segment.WrittenBytes
+	fileDone := d.segmentProcessed(j.file)
+	event := d.event(EventSegmentDone, j, attempt, nil)
+	event.Header = segment.Header
+	event.Trailer = segment.Trailer
+	event.FileInfo = d.files[j.file].info()
+	d.lock.Unlock()
+
+	return d.emitSegment(ctx, event, fileDone)
+}
+
+func (d *Downloader) segmentFailed(ctx context.Context, j job, attempt int, err error) error {
+	d.lock.Lock()
+	d.progress.FailedSegments++
+	d.files[j.file].missing = append(d.files[j.file].missing, MissingSegment{File: j.file, Segment: j.segment, Err: err})
+	fileDone := d.segmentProcessed(j.file)
+	event := d.event(EventSegmentFailed, j, attempt, err)
+	d.lock.Unlock()
+
+	return d.emitSegment(ctx, event, fileDone)
+}
+
+func (d *Downloader) segmentProcessed(fileIdx int) (fileDone bool) {
+	d.files[fileIdx].remaining--
+
+	return d.files[fileIdx].remaining == 0
+}
+
+func (d *Downloader) event(t EventType, j job, attempt int, err error) Event {
+	event := d.progress
+	event.Type = t
+	event.File = j.file
+	event.Segment = j.segment
+	event.Attempt = attempt
+	event.Err = err
+
+	return event
+}
+
+func (d *Downloader) emitSegment(ctx context.Context, event Event, fileDone bool) error {
+	if err := d.emit(ctx, event); err != nil {
+		return err
+	}
+
+	if !fileDone {
+		return nil
+	}
+
+	event.Type = EventFileDone
+	event.Segment = nzb.Segment{}
+	event.Attempt = 0
+	event.Err = nil
+	event.Header = yenc.Header{}
+	event.Trailer = yenc.Trailer{}
+
+	return d.emit(ctx, event)
+}
+
+func (d *Downloader) emit(ctx context.Context, event Event) error {
+	if d.Events == nil {
+		return nil
+	}
+
+	select {
+	case d.Events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Downloader) result() (*Result, error) {
+	result := &Result{
+		Files: make([]FileResult, len(d.files)),
+	}
+
+	for idx, state := range d.files {
+		// Segments fail in the order the workers finish them
+		sort.Slice(state.missing, func(i, j int) bool {
+			return state.missing[i].Segment.Number < state.missing[j].Segment.Number
+		})
+
+		file := FileResult{
+			Subject:       state.subject,
+			Name:          state.name,
+			Path:          state.path,
+			Size:          state.size,
+			ExpectedCRC32: state.expectedCRC32,
+			CRCStatus:     CRCMissing,
+		}
+
+		for _, missing := range state.missing {
+			file.MissingSegments = append(file.MissingSegments, missing.Segment)
+			result.Missing = append(result.Missing, missing)
+		}
+
+		if state.f != nil {
+			crc, err := fileCRC32(state.f)
+			if err != nil {
+				return nil, fmt.Errorf("failed to calculate checksum of '%s': %w", state.path, err)
+			}
+
+			file.CRC32 = crc
+
+			switch {
+			case !state.hasExpectedCRC32:
+				file.CRCStatus = CRCUnknown
+			case crc == state.expectedCRC32:
+				file.CRCStatus = CRCValid
+			default:
+				file.CRCStatus = CRCInvalid
+			}
+		}
+
+		result.Files[idx] = file
+	}
+
+	return result, nil
+}
+
+func fileCRC32(f *os.File) (uint32, error) {
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		return 0, err
+	}
+
+	return h.Sum32(), nil
+}
+
+func (d *Downloader) closeFiles() {
+	for _, state := range d.files {
+		if state != nil && state.f != nil {
+			state.f.Close()
+		}
+	}
+}
+
+// rateLimiter delays the caller until the average rate since its creation drops below the limit.
+type rateLimiter struct {
+	bytesPerSecond int64
+
+	lock  sync.Mutex
+	start time.Time
+	bytes int64
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		bytesPerSecond: bytesPerSecond,
+		start:          time.Now(),
+	}
+}
+
+func (l *rateLimiter) wait(ctx context.Context, n int64) error {
+	if l.bytesPerSecond <= 0 {
+		return nil
+	}
+
+	l.lock.Lock()
+	l.bytes += n
+	until := l.start.Add(time.Duration(float64(l.bytes) / float64(l.bytesPerSecond) * float64(time.Second)))
+	l.lock.Unlock()
+
+	delay := time.Until(until)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
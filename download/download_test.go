@@ -0,0 +1,350 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/download"
+	"github.com/mrincompetent/nntp/nzb"
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+type fakeFetcher struct {
+	lock     sync.Mutex
+	articles map[string][]byte
+	// Number of failures before an article gets served
+	failures map[string]int
+	requests map[string]int
+}
+
+func newFakeFetcher() *fakeFetcher {
+	return &fakeFetcher{
+		articles: map[string][]byte{},
+		failures: map[string]int{},
+		requests: map[string]int{},
+	}
+}
+
+func (f *fakeFetcher) Body(id string) (nntp.Article, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.requests[id]++
+
+	if f.failures[id] > 0 {
+		f.failures[id]--
+		return nntp.Article{}, errors.New("connection reset")
+	}
+
+	body, ok := f.articles[id]
+	if !ok {
+		return nntp.Article{}, nntp.ErrNoSuchArticle
+	}
+
+	return nntp.Article{MessageID: id, Body: body}, nil
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	return data
+}
+
+// post encodes the data & stores the parts in the fetcher. It returns the NZB file entry.
+func post(t testing.TB, f *fakeFetcher, name string, data []byte, partSize int64) nzb.File {
+	e := yenc.NewEncoder(bytes.NewReader(data), name, int64(len(data)))
+	e.PartSize = partSize
+
+	file := nzb.File{Subject: name, Groups: []string{"alt.binaries.test"}}
+
+	for {
+		part, err := e.Next()
+		if errors.Is(err, io.EOF) {
+			return file
+		}
+
+		require.NoError(t, err, "Failed to encode")
+
+		id := fmt.Sprintf("%s-%d@test", name, part.Number)
+		f.articles["<"+id+">"] = part.Body
+
+		file.Segments = append(file.Segments, nzb.Segment{Number: part.Number, Bytes: uint64(len(part.Body)), MessageID: id})
+	}
+}
+
+func TestDownloader_Download(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+
+	a := testData(10000)
+	b := testData(2500)
+
+	n := &nzb.NZB{Files: []nzb.File{
+		post(t, fetcher, "a.bin", a, 1000),
+		post(t, fetcher, "b.bin", b, 1000),
+	}}
+
+	// Needs a retry
+	fetcher.failures["<a.bin-3@test>"] = 1
+
+	// Reverse the segments, so the file is written out of order
+	segments := n.Files[0].Segments
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+
+	events := make(chan download.Event, 100)
+
+	d := download.New(dir, fetcher, fetcher, fetcher)
+	d.Events = events
+
+	result, err := d.Download(context.Background(), n)
+	require.NoError(t, err, "Failed to download")
+	close(events)
+
+	require.Len(t, result.Files, 2)
+	assert.Empty(t, result.Missing)
+
+	for idx, data := range [][]byte{a, b} {
+		file := result.Files[idx]
+		assert.Equal(t, download.CRCValid, file.CRCStatus)
+		assert.Equal(t, crc32.ChecksumIEEE(data), file.CRC32)
+		assert.Equal(t, int64(len(data)), file.Size)
+
+		written, err := ioutil.ReadFile(file.Path)
+		require.NoError(t, err, "Failed to read file")
+		assert.Equal(t, data, written)
+	}
+
+	assert.Equal(t, filepath.Join(dir, "a.bin"), result.Files[0].Path)
+
+	counts := map[download.EventType]int{}
+
+	var last download.Event
+	for event := range events {
+		counts[event.Type]++
+		last = event
+	}
+
+	assert.Equal(t, map[download.EventType]int{
+		download.EventSegmentDone:  13,
+		download.EventSegmentRetry: 1,
+		download.EventFileDone:     2,
+	}, counts)
+	assert.Equal(t, 13, last.TotalSegments)
+	assert.Equal(t, 13, last.DoneSegments)
+	assert.Equal(t, int64(12500), last.WrittenBytes)
+}
+
+func TestDownloader_Download_Missing(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+
+	n := &nzb.NZB{Files: []nzb.File{
+		post(t, fetcher, "a.bin", testData(3000), 1000),
+		{Subject: "gone", Segments: []nzb.Segment{{Number: 1, MessageID: "gone@test"}}},
+	}}
+
+	delete(fetcher.articles, "<a.bin-2@test>")
+
+	d := download.New(dir, fetcher)
+	d.Attempts = 2
+
+	result, err := d.Download(context.Background(), n)
+	require.NoError(t, err, "Failed to download")
+
+	assert.Equal(t, 2, fetcher.requests["<a.bin-2@test>"])
+
+	require.Len(t, result.Missing, 2)
+	assert.Equal(t, 0, result.Missing[0].File)
+	assert.Equal(t, 2, result.Missing[0].Segment.Number)
+	assert.True(t, errors.Is(result.Missing[0].Err, nntp.ErrNoSuchArticle))
+
+	assert.Equal(t, download.CRCInvalid, result.Files[0].CRCStatus)
+	assert.Equal(t, []nzb.Segment{n.Files[0].Segments[1]}, result.Files[0].MissingSegments)
+
+	assert.Equal(t, download.CRCMissing, result.Files[1].CRCStatus)
+	assert.Empty(t, result.Files[1].Path)
+}
+
+func TestDownloader_Download_Cancelled(t *testing.T) {
+	fetcher := newFakeFetcher()
+	n := &nzb.NZB{Files: []nzb.File{post(t, fetcher, "a.bin", testData(3000), 1000)}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := download.New(t.TempDir(), fetcher).Download(ctx, n)
+	assert.True(t, errors.Is(err, context.Canceled), "Expected %v, got %v", context.Canceled, err)
+}
+
+func TestDownloader_Download_Resume(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+	data := testData(3000)
+
+	n := &nzb.NZB{Files: []nzb.File{post(t, fetcher, "a.bin", data, 1000)}}
+
+	events := make(chan download.Event, 100)
+
+	// First run only gets the first segment
+	first := download.New(dir, fetcher)
+	first.Events = events
+	first.Skip = func(file int, segment nzb.Segment) bool {
+		return segment.Number != 1
+	}
+
+	_, err := first.Download(context.Background(), n)
+	require.NoError(t, err, "Failed to download")
+	close(events)
+
+	event := <-events
+	require.Equal(t, download.EventSegmentDone, event.Type)
+	assert.Equal(t, download.FileInfo{Name: "a.bin", Path: filepath.Join(dir, "a.bin"), Size: 3000}, event.FileInfo)
+	assert.Equal(t, 3, event.DoneSegments)
+
+	// Second run gets the remaining segments
+	second := download.New(dir, fetcher)
+	second.Connections = 1
+	second.BytesPerSecond = 1 << 30
+	second.Resume = map[int]download.FileInfo{0: event.FileInfo}
+	second.Skip = func(file int, segment nzb.Segment) bool {
+		return segment.Number == 1
+	}
+
+	result, err := second.Download(context.Background(), n)
+	require.NoError(t, err, "Failed to download")
+
+	assert.Equal(t, download.CRCValid, result.Files[0].CRCStatus)
+	assert.Equal(t, 1, fetcher.requests["<a.bin-1@test>"])
+
+	written, err := ioutil.ReadFile(result.Files[0].Path)
+	require.NoError(t, err, "Failed to read file")
+	assert.Equal(t, data, written)
+}
+
+func TestDownloader_Download_TruncatesStaleFile(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+
+	n := &nzb.NZB{Files: []nzb.File{post(t, fetcher, "a.bin", testData(3000), 1000)}}
+	delete(fetcher.articles, "<a.bin-2@test>")
+
+	stale := bytes.Repeat([]byte{0xff}, 4000)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.bin"), stale, 0o644), "Failed to write stale file")
+
+	result, err := download.New(dir, fetcher).Download(context.Background(), n)
+	require.NoError(t, err, "Failed to download")
+	require.Len(t, result.Missing, 1)
+
+	written, err := ioutil.ReadFile(result.Files[0].Path)
+	require.NoError(t, err, "Failed to read file")
+
+	// The missing segment doesn't keep the content of the stale file
+	expected := testData(3000)
+	copy(expected[1000:2000], make([]byte, 1000))
+	assert.Equal(t, expected, written)
+}
+
+func TestDownloader_Download_RateLimitBeforeRequest(t *testing.T) {
+	fetcher := newFakeFetcher()
+	n := &nzb.NZB{Files: []nzb.File{post(t, fetcher, "a.bin", testData(3000), 1000)}}
+
+	d := download.New(t.TempDir(), fetcher)
+	// The first segment alone takes more than a second
+	d.BytesPerSecond = 100
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := d.Download(ctx, n)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Expected %v, got %v", context.DeadlineExceeded, err)
+	assert.Empty(t, fetcher.requests, "Expected no request before the rate allows it")
+}
+
+func TestDownloader_Download_SameName(t *testing.T) {
+	dir := t.TempDir()
+
+	fetcher := newFakeFetcher()
+	a := post(t, fetcher, "a.bin", testData(1500), 1000)
+
+	// Same yEnc name, but different content & message-ids
+	other := newFakeFetcher()
+	b := post(t, other, "a.bin", testData(2500), 1000)
+
+	for idx := range b.Segments {
+		id := "b-" + b.Segments[idx].MessageID
+		fetcher.articles["<"+id+">"] = other.articles["<"+b.Segments[idx].MessageID+">"]
+		b.Segments[idx].MessageID = id
+	}
+
+	result, err := download.New(dir, fetcher).Download(context.Background(), &nzb.NZB{Files: []nzb.File{a, b}})
+	require.NoError(t, err, "Failed to download")
+	require.Len(t, result.Files, 2)
+
+	assert.Equal(t, filepath.Join(dir, "a.bin"), result.Files[0].Path)
+	assert.Equal(t, filepath.Join(dir, "a.1.bin"), result.Files[1].Path)
+
+	for idx, size := range []int{1500, 2500} {
+		assert.Equal(t, download.CRCValid, result.Files[idx].CRCStatus)
+
+		written, err := ioutil.ReadFile(result.Files[idx].Path)
+		require.NoError(t, err, "Failed to read file")
+		assert.Equal(t, testData(size), written)
+	}
+}
+
+func TestDownloader_Download_InvalidPart(t *testing.T) {
+	for name, body := range map[string]string{
+		"end beyond size": "=ybegin part=1 total=1 line=128 size=4 name=a.bin\r\n=ypart begin=6 end=9\r\nabcd\r\n=yend size=4 part=1\r\n",
+		"begin after end": "=ybegin part=1 total=1 line=128 size=4 name=a.bin\r\n=ypart begin=5 end=4\r\nabcd\r\n=yend size=4 part=1\r\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fetcher := newFakeFetcher()
+			fetcher.articles["<a@test>"] = []byte(body)
+
+			n := &nzb.NZB{Files: []nzb.File{{Subject: "a", Segments: []nzb.Segment{{Number: 1, MessageID: "a@test"}}}}}
+
+			result, err := download.New(t.TempDir(), fetcher).Download(context.Background(), n)
+			require.NoError(t, err, "Failed to download")
+
+			require.Len(t, result.Missing, 1)
+			assert.True(t, errors.Is(result.Missing[0].Err, download.ErrInvalidPart), "Unexpected error %v", result.Missing[0].Err)
+			assert.Empty(t, result.Files[0].Path)
+		})
+	}
+}
+
+func TestDownloader_Download_SizeExceedsNZB(t *testing.T) {
+	fetcher := newFakeFetcher()
+	file := post(t, fetcher, "a.bin", testData(3000), 1000)
+
+	// The NZB lists fewer bytes than the poster announces in the yEnc headers
+	for idx := range file.Segments {
+		file.Segments[idx].Bytes = 100
+	}
+
+	result, err := download.New(t.TempDir(), fetcher).Download(context.Background(), &nzb.NZB{Files: []nzb.File{file}})
+	require.NoError(t, err, "Failed to download")
+
+	require.Len(t, result.Missing, 3)
+	assert.True(t, errors.Is(result.Missing[0].Err, download.ErrInvalidPart), "Unexpected error %v", result.Missing[0].Err)
+	assert.Empty(t, result.Files[0].Path)
+}
@@ -0,0 +1,204 @@
+package nntp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields returns the field names of the format, like "Subject:" or "Xref:full".
+func (h *OverviewFormat) Fields() []string {
+	return append([]string(nil), h.fieldNames...)
+}
+
+var overviewValueReplacer = strings.NewReplacer("\r\n", " ", "\t", " ", "\r", " ", "\n", " ", "\x00", "")
+
+// FormatXoverLine renders the header as a single XOVER / OVER line without line ending, the inverse of
+// ParseXoverLine. Tabs, CR & LF within values are replaced by a space as required by RFC 3977 section 8.3.2 & the
+// values of ":full" fields are prefixed by the field name. Fields which are not known to the header are left empty.
+func (h *OverviewFormat) FormatXoverLine(header Header) string {
+	var b strings.Builder
+
+	b.WriteString(strconv.FormatUint(header.MessageNumber, 10))
+
+	for idx := range h.fieldNames {
+		b.WriteByte('\t')
+		b.WriteString(overviewValueReplacer.Replace(h.fieldValue(idx, header)))
+	}
+
+	return b.String()
+}
+
+func (h *OverviewFormat) fieldValue(idx int, header Header) string {
+	fieldName := h.fieldNames[idx]
+
+	switch h.lowercaseFieldNames[idx] {
+	case "subject:":
+		return header.Subject
+	case "from:":
+		return header.Author
+	case "date:":
+		if header.Date.IsZero() {
+			return ""
+		}
+
+		return header.Date.Format(time.RFC1123Z)
+	case "message-id:":
+		return header.MessageID
+	case "references:":
+		return header.References
+	case "bytes:", ":bytes":
+		return strconv.FormatUint(header.Bytes, 10)
+	case "lines:", ":lines":
+		return strconv.FormatUint(header.Lines, 10)
+	}
+
+	isFull := strings.HasSuffix(h.lowercaseFieldNames[idx], ":full")
+	if isFull {
+		fieldName = fieldName[0 : len(fieldName)-4]
+	}
+
+	name := strings.TrimSuffix(fieldName, ":")
+
+	for key, value := range header.Additional {
+		if !strings.EqualFold(key, name) {
+			continue
+		}
+
+		if isFull {
+			return fieldName + " " + value
+		}
+
+		return value
+	}
+
+	return ""
+}
+
+// WriteOverviewFmt writes the format as response body of LIST OVERVIEW.FMT, including the terminating dot line.
+func (h *OverviewFormat) WriteOverviewFmt(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	dw := textproto.NewWriter(bw).DotWriter()
+	for _, fieldName := range h.fieldNames {
+		if _, err := fmt.Fprintf(dw, "%s\n", fieldName); err != nil {
+			return fmt.Errorf("failed to write field '%s': %w", fieldName, err)
+		}
+	}
+
+	if err := dw.Close(); err != nil {
+		return fmt.Errorf("failed to terminate list: %w", err)
+	}
+
+	return bw.Flush()
+}
+
+// FormatIssue is a deviation of an overview format from RFC 3977 section 8.4.
+type FormatIssue struct {
+	// Index of the field within the format, -1 for missing fields
+	Index int
+	Field string
+	// Description, like "expected ':bytes'"
+	Problem string
+	// Legacy deviations are explicitly allowed by RFC 3977 for compatibility, like "Bytes:" instead of ":bytes"
+	Legacy bool
+}
+
+func (i FormatIssue) String() string {
+	if i.Index < 0 {
+		return fmt.Sprintf("'%s': %s", i.Field, i.Problem)
+	}
+
+	return fmt.Sprintf("field %d ('%s'): %s", i.Index, i.Field, i.Problem)
+}
+
+var ErrInvalidOverviewFormat = errors.New("invalid overview format")
+
+// The mandatory first fields of every overview format
+var mandatoryOverviewFields = []string{"Subject:", "From:", "Date:", "Message-ID:", "References:", ":bytes", ":lines"}
+
+// Issues returns all deviations of the format from RFC 3977 section 8.4: The first seven fields must be the ones of
+// DefaultOverviewFormat in that order & all further fields must be ":full" header fields or metadata items.
+func (h *OverviewFormat) Issues() []FormatIssue {
+	var issues []FormatIssue
+
+	seen := map[string]int{}
+
+	for idx, lowercaseName := range h.lowercaseFieldNames {
+		name := h.fieldNames[idx]
+		normalized := normalizeFieldName(lowercaseName)
+
+		if first, ok := seen[normalized]; ok {
+			issues = append(issues, FormatIssue{
+				Index:   idx,
+				Field:   name,
+				Problem: fmt.Sprintf("duplicate of field %d", first),
+			})
+
+			continue
+		}
+
+		seen[normalized] = idx
+
+		if idx >= len(mandatoryOverviewFields) {
+			if !strings.HasSuffix(lowercaseName, ":full") && !strings.HasPrefix(lowercaseName, ":") {
+				issues = append(issues, FormatIssue{
+					Index:   idx,
+					Field:   name,
+					Problem: fmt.Sprintf("additional fields must be metadata items or header fields like '%sfull'", name),
+				})
+			}
+
+			continue
+		}
+
+		expected := mandatoryOverviewFields[idx]
+
+		switch {
+		case strings.EqualFold(lowercaseName, expected):
+		case normalized == normalizeFieldName(expected) && !strings.HasSuffix(lowercaseName, ":full"):
+			issues = append(issues, FormatIssue{
+				Index:   idx,
+				Field:   name,
+				Problem: fmt.Sprintf("legacy name, expected '%s'", expected),
+				Legacy:  true,
+			})
+		default:
+			issues = append(issues, FormatIssue{
+				Index:   idx,
+				Field:   name,
+				Problem: fmt.Sprintf("non-standard order, expected '%s'", expected),
+			})
+		}
+	}
+
+	for _, expected := range mandatoryOverviewFields {
+		if _, ok := seen[normalizeFieldName(expected)]; !ok {
+			issues = append(issues, FormatIssue{Index: -1, Field: expected, Problem: "missing"})
+		}
+	}
+
+	return issues
+}
+
+// Validate returns an error wrapping ErrInvalidOverviewFormat if the format has issues besides legacy ones.
+func (h *OverviewFormat) Validate() error {
+	var problems []string
+
+	for _, issue := range h.Issues() {
+		if !issue.Legacy {
+			problems = append(problems, issue.String())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOverviewFormat, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
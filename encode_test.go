@@ -0,0 +1,81 @@
+package nntp_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestOverviewFormat_FormatXoverLine(t *testing.T) {
+	format := testOverviewFormat()
+
+	header := nntp.Header{
+		MessageNumber: 3000234,
+		Subject:       "some\tsubject\r\n with folding",
+		Author:        `"Test Author" <test@example.com>`,
+		Date:          time.Date(1998, 10, 6, 4, 38, 40, 0, time.FixedZone("", -5*3600)),
+		MessageID:     "<id@example.com>",
+		References:    "<ref@example.net>",
+		Bytes:         1234,
+		Lines:         17,
+		Additional: map[string]string{
+			"Xref": "news.example.com misc.test:3000234",
+		},
+	}
+
+	line := format.FormatXoverLine(header)
+	assert.Equal(t, "3000234\tsome subject  with folding\t\"Test Author\" <test@example.com>\t"+
+		"Tue, 06 Oct 1998 04:38:40 -0500\t<id@example.com>\t<ref@example.net>\t1234\t17\t"+
+		"Xref: news.example.com misc.test:3000234", line)
+
+	parsed, err := format.ParseXoverLine(line)
+	require.NoError(t, err, "Failed to parse formatted line")
+
+	header.Subject = "some subject  with folding"
+	assert.Equal(t, header, parsed)
+}
+
+func TestOverviewFormat_WriteOverviewFmt(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, testOverviewFormat().WriteOverviewFmt(&b))
+
+	assert.Equal(
+		t,
+		"Subject:\r\nFrom:\r\nDate:\r\nMessage-ID:\r\nReferences:\r\n:bytes\r\n:lines\r\nXref:full\r\n.\r\n",
+		b.String(),
+	)
+}
+
+func TestOverviewFormat_Issues(t *testing.T) {
+	assert.Empty(t, testOverviewFormat().Issues())
+	assert.NoError(t, testOverviewFormat().Validate())
+
+	legacy := nntp.NewOverviewFormat([]string{
+		"Subject:", "From:", "Date:", "Message-ID:", "References:", "Bytes:", "Lines:", "Xref:full",
+	})
+	assert.Equal(t, []nntp.FormatIssue{
+		{Index: 5, Field: "Bytes:", Problem: "legacy name, expected ':bytes'", Legacy: true},
+		{Index: 6, Field: "Lines:", Problem: "legacy name, expected ':lines'", Legacy: true},
+	}, legacy.Issues())
+	assert.NoError(t, legacy.Validate(), "Legacy names must be valid")
+
+	invalid := nntp.NewOverviewFormat([]string{
+		"From:", "Subject:", "Date:", "Message-ID:", "References:", ":bytes", "Xref:", "subject:",
+	})
+	assert.Equal(t, []nntp.FormatIssue{
+		{Index: 0, Field: "From:", Problem: "non-standard order, expected 'Subject:'"},
+		{Index: 1, Field: "Subject:", Problem: "non-standard order, expected 'From:'"},
+		{Index: 6, Field: "Xref:", Problem: "non-standard order, expected ':lines'"},
+		{Index: 7, Field: "subject:", Problem: "duplicate of field 1"},
+		{Index: -1, Field: ":lines", Problem: "missing"},
+	}, invalid.Issues())
+
+	err := invalid.Validate()
+	assert.True(t, errors.Is(err, nntp.ErrInvalidOverviewFormat), "Expected ErrInvalidOverviewFormat, got %v", err)
+}
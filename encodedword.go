@@ -0,0 +1,94 @@
+package nntp
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultFallbackCharset is used for raw 8-bit header text which is not valid UTF-8. Windows-1252 is a superset of the
+// printable characters of ISO-8859-1 & by far the most common charset of such headers.
+const DefaultFallbackCharset = "windows-1252"
+
+// HeaderDecoder decodes RFC 2047 encoded-words, like "=?UTF-8?B?w6TDtsO8?=", within header values.
+// It's lenient: Encoded-words with unknown charsets or invalid encodings are kept as they are & raw 8-bit text which is
+// not valid UTF-8 gets converted from the fallback charset.
+type HeaderDecoder struct {
+	// Charset of raw 8-bit text which is not valid UTF-8. Defaults to DefaultFallbackCharset.
+	Fallback string
+}
+
+func NewHeaderDecoder() *HeaderDecoder {
+	return &HeaderDecoder{
+		Fallback: DefaultFallbackCharset,
+	}
+}
+
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: CharsetReader,
+}
+
+// Matches a single encoded-word. Some clients put spaces into the encoded text, which RFC 2047 does not allow.
+var encodedWordRegexp = regexp.MustCompile(`=\?[^?\s]+\?[bBqQ]\?[^?]*\?=`)
+
+// Decode returns the value with all encoded-words decoded & converted to UTF-8.
+// Whitespace between two adjacent encoded-words is removed, as required by RFC 2047 section 6.2.
+func (d *HeaderDecoder) Decode(value string) string {
+	if !strings.Contains(value, "=?") {
+		return d.decodeRaw(value)
+	}
+
+	var (
+		b    strings.Builder
+		last int
+		// Whether the previous token was a successfully decoded encoded-word
+		afterWord bool
+	)
+
+	for _, loc := range encodedWordRegexp.FindAllStringIndex(value, -1) {
+		between := value[last:loc[0]]
+
+		decoded, err := wordDecoder.Decode(value[loc[0]:loc[1]])
+		if err != nil {
+			// Keep the undecodable word as it is
+			b.WriteString(d.decodeRaw(value[last:loc[1]]))
+			last = loc[1]
+			afterWord = false
+
+			continue
+		}
+
+		if !afterWord || strings.TrimSpace(between) != "" {
+			b.WriteString(d.decodeRaw(between))
+		}
+
+		b.WriteString(d.decodeRaw(decoded))
+
+		last = loc[1]
+		afterWord = true
+	}
+
+	b.WriteString(d.decodeRaw(value[last:]))
+
+	return b.String()
+}
+
+// decodeRaw converts text which is not valid UTF-8 from the fallback charset.
+func (d *HeaderDecoder) decodeRaw(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	fallback := d.Fallback
+	if fallback == "" {
+		fallback = DefaultFallbackCharset
+	}
+
+	decoded, err := DecodeCharset(fallback, []byte(s))
+	if err != nil {
+		return strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+
+	return decoded
+}
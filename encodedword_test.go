@@ -0,0 +1,120 @@
+package nntp_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestHeaderDecoder_Decode(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{
+			name:     "plain",
+			value:    "some subject",
+			expected: "some subject",
+		},
+		{
+			name:     "base64 utf-8",
+			value:    "=?UTF-8?B?w6TDtsO8?=",
+			expected: "äöü",
+		},
+		{
+			name:     "quoted-printable latin1 with surrounding text",
+			value:    "Re: =?iso-8859-1?q?gr=FC=DFe_aus?= Berlin",
+			expected: "Re: grüße aus Berlin",
+		},
+		{
+			name:     "adjacent words",
+			value:    "=?utf-8?q?a?= \t =?utf-8?q?b?=",
+			expected: "ab",
+		},
+		{
+			name:     "windows-1252",
+			value:    "=?windows-1252?Q?=80_5?=",
+			expected: "€ 5",
+		},
+		{
+			name:     "koi8-r",
+			value:    "=?KOI8-R?B?8NLJ18XU?=",
+			expected: "Привет",
+		},
+		{
+			name:     "unknown charset is kept",
+			value:    "=?x-unknown?Q?abc?= def",
+			expected: "=?x-unknown?Q?abc?= def",
+		},
+		{
+			name:     "raw 8-bit latin1",
+			value:    "M\xfcller",
+			expected: "Müller",
+		},
+		{
+			name:     "raw utf-8",
+			value:    "Müller",
+			expected: "Müller",
+		},
+		{
+			name:     "encoded-word containing invalid utf-8",
+			value:    "=?utf-8?q?M=FCller?=",
+			expected: "Müller",
+		},
+	}
+
+	decoder := nntp.NewHeaderDecoder()
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, decoder.Decode(test.value))
+		})
+	}
+}
+
+func TestHeaderDecoder_Fallback(t *testing.T) {
+	decoder := &nntp.HeaderDecoder{Fallback: "windows-1251"}
+
+	assert.Equal(t, "Привет", decoder.Decode("\xcf\xf0\xe8\xe2\xe5\xf2"))
+}
+
+func TestCharsetReader(t *testing.T) {
+	r, err := nntp.CharsetReader("ISO_8859-15", strings.NewReader("5 \xa4"))
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "5 €", string(b))
+
+	_, err = nntp.CharsetReader("shift_jis", strings.NewReader(""))
+	assert.True(t, errors.Is(err, nntp.ErrUnknownCharset), "Expected ErrUnknownCharset, got %v", err)
+}
+
+func TestOverviewFormat_HeaderDecoder(t *testing.T) {
+	format := nntp.DefaultOverviewFormat()
+	format.SetHeaderDecoder(nntp.NewHeaderDecoder())
+
+	header, err := format.ParseXoverLine("1\t=?UTF-8?B?w6TDtsO8?=\tJ\xfcrgen <j@example.com>\tSun, 10 May 2020 00:32:22 +0000\t<id@example.com>\t\t1\t1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "=?UTF-8?B?w6TDtsO8?=", header.Subject)
+	assert.Equal(t, "äöü", header.DecodedSubject)
+	assert.Equal(t, "äöü", header.DisplaySubject())
+	assert.Equal(t, "J\xfcrgen <j@example.com>", header.Author)
+	assert.Equal(t, "Jürgen <j@example.com>", header.DisplayAuthor())
+
+	format.SetHeaderDecoder(nil)
+
+	header, err = format.ParseXoverLine("1\t=?UTF-8?B?w6TDtsO8?=\tauthor\tSun, 10 May 2020 00:32:22 +0000\t<id@example.com>\t\t1\t1")
+	require.NoError(t, err)
+	assert.Empty(t, header.DecodedSubject)
+	assert.Equal(t, "=?UTF-8?B?w6TDtsO8?=", header.DisplaySubject())
+}
@@ -0,0 +1,305 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider is a single server used by a Failover.
+// Providers with a lower Priority get asked first. Providers with the same priority keep the order they were passed in.
+type Provider struct {
+	Name     string
+	Priority int
+	Client   *Client
+}
+
+type ProviderStats struct {
+	Name string
+	// Articles, heads, bodies & stats served by this provider
+	Served uint64
+	// Requests answered with 423/430
+	Misses uint64
+	// Requests not sent because the provider already reported the message-id as missing
+	SkippedMisses uint64
+	// Overview requests served by this provider
+	Overviews uint64
+	// Requests which failed for any other reason
+	Errors uint64
+	// Size of all article heads & bodies served by this provider
+	Bytes uint64
+}
+
+type failoverProvider struct {
+	Provider
+
+	// Serializes GROUP + XOVER sequences so concurrent overview requests don't select different groups in between.
+	groupLock sync.Mutex
+
+	misses map[string]struct{}
+	// Remembered message-ids in the order they were reported missing, so the oldest ones get forgotten first.
+	// The ids before missHead have been forgotten already.
+	missOrder []string
+	missHead  int
+	stats     ProviderStats
+}
+
+// Failover asks a set of providers in priority order for articles and overview data.
+// When a provider does not have an article, the next one gets asked. Missing message-ids are remembered per provider,
+// so a provider which already reported a message-id as missing won't get asked for it again.
+// All methods are safe for concurrent use.
+type Failover struct {
+	lock      sync.Mutex
+	providers []*failoverProvider
+	maxMisses int
+}
+
+// DefaultMaxMisses is the default number of missing message-ids remembered per provider.
+const DefaultMaxMisses = 100000
+
+var ErrNoProviders = errors.New("no providers configured")
+
+func NewFailover(providers ...Provider) *Failover {
+	f := &Failover{
+		providers: make([]*failoverProvider, len(providers)),
+		maxMisses: DefaultMaxMisses,
+	}
+
+	for idx := range providers {
+		f.providers[idx] = &failoverProvider{
+			Provider: providers[idx],
+			misses:   map[string]struct{}{},
+			stats: ProviderStats{
+				Name: providers[idx].Name,
+			},
+		}
+	}
+
+	sort.SliceStable(f.providers, func(i, j int) bool {
+		return f.providers[i].Priority < f.providers[j].Priority
+	})
+
+	return f
+}
+
+func (f *Failover) Article(id string) (Article, error) {
+	return f.retrieve(id, (*Client).Article)
+}
+
+func (f *Failover) Head(id string) (Article, error) {
+	return f.retrieve(id, (*Client).Head)
+}
+
+func (f *Failover) Body(id string) (Article, error) {
+	return f.retrieve(id, (*Client).Body)
+}
+
+func (f *Failover) Stat(id string) (Article, error) {
+	return f.retrieve(id, (*Client).Stat)
+}
+
+func (f *Failover) retrieve(id string, fn func(c *Client, id string) (Article, error)) (Article, error) {
+	if len(f.providers) == 0 {
+		return Article{}, ErrNoProviders
+	}
+
+	// Article numbers are only valid within a single provider & group, so only message-ids get remembered.
+	remember := strings.HasPrefix(id, "<")
+
+	var firstErr error
+
+	for _, p := range f.providers {
+		if remember && f.knownMiss(p, id) {
+			continue
+		}
+
+		article, err := fn(p.Client, id)
+		if err == nil {
+			f.recordServed(p, article)
+			return article, nil
+		}
+
+		if IsNoSuchArticle(err) {
+			f.recordMiss(p, id, remember)
+			continue
+		}
+
+		f.recordError(p)
+
+		if firstErr == nil {
+			firstErr = fmt.Errorf("provider '%s': %w", p.Name, err)
+		}
+	}
+
+	if firstErr != nil {
+		return Article{}, firstErr
+	}
+
+	return Article{}, fmt.Errorf("%w: %s not available on any provider", ErrNoSuchArticle, id)
+}
+
+// Xover selects the group on each provider and requests the overview data for the given range.
+// Providers which don't carry the group or don't have any articles in the range get skipped.
+func (f *Failover) Xover(group, r string) ([]Header, error) {
+	if len(f.providers) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	var firstErr error
+
+	for _, p := range f.providers {
+		headers, err := f.xover(p, group, r)
+		if err == nil && len(headers) > 0 {
+			f.lock.Lock()
+			p.stats.Overviews++
+			f.lock.Unlock()
+
+			return headers, nil
+		}
+
+		if err == nil || isGroupMiss(err) {
+			f.lock.Lock()
+			p.stats.Misses++
+			f.lock.Unlock()
+
+			continue
+		}
+
+		f.recordError(p)
+
+		if firstErr == nil {
+			firstErr = fmt.Errorf("provider '%s': %w", p.Name, err)
+		}
+	}
+
+	return nil, firstErr
+}
+
+func (f *Failover) xover(p *failoverProvider, group, r string) ([]Header, error) {
+	p.groupLock.Lock()
+	defer p.groupLock.Unlock()
+
+	if _, err := p.Client.Group(group); err != nil {
+		return nil, err
+	}
+
+	return p.Client.Xover(r)
+}
+
+// Stats returns a snapshot of the per provider statistics in priority order.
+func (f *Failover) Stats() []ProviderStats {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	stats := make([]ProviderStats, len(f.providers))
+	for idx := range f.providers {
+		stats[idx] = f.providers[idx].stats
+	}
+
+	return stats
+}
+
+// ForgetMisses clears all remembered missing message-ids, so every provider gets asked again.
+func (f *Failover) ForgetMisses() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, p := range f.providers {
+		p.misses, p.missOrder, p.missHead = map[string]struct{}{}, nil, 0
+	}
+}
+
+// SetMaxMisses limits the number of missing message-ids remembered per provider. Beyond the limit the oldest ones get
+// forgotten, so long running downloads don't grow the failover forever. Zero means unlimited.
+func (f *Failover) SetMaxMisses(limit int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.maxMisses = limit
+
+	for _, p := range f.providers {
+		f.forgetOldMisses(p)
+	}
+}
+
+func (f *Failover) knownMiss(p *failoverProvider, id string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, missing := p.misses[id]
+	if missing {
+		p.stats.SkippedMisses++
+	}
+
+	return missing
+}
+
+func (f *Failover) recordMiss(p *failoverProvider, id string, remember bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p.stats.Misses++
+
+	if !remember {
+		return
+	}
+
+	if _, ok := p.misses[id]; ok {
+		return
+	}
+
+	p.misses[id] = struct{}{}
+	p.missOrder = append(p.missOrder, id)
+	f.forgetOldMisses(p)
+}
+
+func (f *Failover) forgetOldMisses(p *failoverProvider) {
+	if f.maxMisses <= 0 {
+		return
+	}
+
+	for len(p.missOrder)-p.missHead > f.maxMisses {
+		delete(p.misses, p.missOrder[p.missHead])
+		p.missOrder[p.missHead] = ""
+		p.missHead++
+	}
+
+	// Reslicing would keep the forgotten part of the backing array, so the remembered ids get copied once they only
+	// make up half of it
+	if p.missHead > len(p.missOrder)/2 {
+		p.missOrder = append([]string(nil), p.missOrder[p.missHead:]...)
+		p.missHead = 0
+	}
+}
+
+func (f *Failover) recordServed(p *failoverProvider, article Article) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p.stats.Served++
+	p.stats.Bytes += uint64(len(article.Body))
+
+	for _, field := range article.Header.Fields() {
+		p.stats.Bytes += uint64(len(field.Name) + len(field.Value))
+	}
+}
+
+func (f *Failover) recordError(p *failoverProvider) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p.stats.Errors++
+}
+
+func isGroupMiss(err error) bool {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return false
+	}
+
+	// 411: No such newsgroup, 420: No current article selected, 423: No articles in that range
+	return protoErr.Code == 411 || protoErr.Code == 420 || protoErr.Code == 423
+}
@@ -0,0 +1,183 @@
+package nntp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+// Commands sent by getAuthenticatedClient
+const authCommands = "AUTHINFO USER foo\r\nAUTHINFO PASS bar\r\n"
+
+func TestFailover_Body(t *testing.T) {
+	primary, primaryConn := getAuthenticatedClient(t)
+	block, blockConn := getAuthenticatedClient(t)
+
+	// First request: missing on primary, served by block
+	primaryConn.RecordPrintfLine(t, "430 No such article")
+	blockConn.RecordPrintfLine(t, "222 0 <a@example.com>")
+	blockConn.RecordDotMessage(t, "body a\n")
+	// Second request: primary must not be asked again for the same message-id
+	blockConn.RecordPrintfLine(t, "222 0 <a@example.com>")
+	blockConn.RecordDotMessage(t, "body a\n")
+	// Third request: served by primary
+	primaryConn.RecordPrintfLine(t, "222 0 <b@example.com>")
+	primaryConn.RecordDotMessage(t, "body b\n")
+
+	f := nntp.NewFailover(
+		nntp.Provider{Name: "block", Priority: 10, Client: block},
+		nntp.Provider{Name: "primary", Priority: 0, Client: primary},
+	)
+
+	article, err := f.Body("<a@example.com>")
+	require.NoError(t, err, "Failed to retrieve body")
+	assert.Equal(t, "body a\n", string(article.Body))
+
+	article, err = f.Body("<a@example.com>")
+	require.NoError(t, err, "Failed to retrieve body")
+	assert.Equal(t, "body a\n", string(article.Body))
+
+	article, err = f.Body("<b@example.com>")
+	require.NoError(t, err, "Failed to retrieve body")
+	assert.Equal(t, "body b\n", string(article.Body))
+
+	assert.Equal(t, []nntp.ProviderStats{
+		{Name: "primary", Served: 1, Misses: 1, SkippedMisses: 1, Bytes: 7},
+		{Name: "block", Served: 2, Bytes: 14},
+	}, f.Stats())
+
+	// The primary must not be asked for <a@example.com> again after its miss
+	assert.Equal(t, authCommands+"BODY <a@example.com>\r\nBODY <b@example.com>\r\n", primaryConn.write.String())
+	assert.Equal(t, authCommands+"BODY <a@example.com>\r\nBODY <a@example.com>\r\n", blockConn.write.String())
+}
+
+func TestFailover_Article_Missing(t *testing.T) {
+	primary, primaryConn := getAuthenticatedClient(t)
+	block, blockConn := getAuthenticatedClient(t)
+
+	primaryConn.RecordPrintfLine(t, "430 No such article")
+	blockConn.RecordPrintfLine(t, "430 No such article")
+
+	f := nntp.NewFailover(
+		nntp.Provider{Name: "primary", Client: primary},
+		nntp.Provider{Name: "block", Client: block},
+	)
+
+	_, err := f.Article("<a@example.com>")
+	assert.True(t, errors.Is(err, nntp.ErrNoSuchArticle), "Expected %v, got %v", nntp.ErrNoSuchArticle, err)
+
+	// Both providers are known to miss the article, so nothing gets sent
+	_, err = f.Article("<a@example.com>")
+	assert.True(t, errors.Is(err, nntp.ErrNoSuchArticle), "Expected %v, got %v", nntp.ErrNoSuchArticle, err)
+
+	stats := f.Stats()
+	assert.Equal(t, uint64(1), stats[0].Misses)
+	assert.Equal(t, uint64(1), stats[0].SkippedMisses)
+	assert.Equal(t, uint64(1), stats[1].Misses)
+	assert.Equal(t, uint64(1), stats[1].SkippedMisses)
+
+	assert.Equal(t, authCommands+"ARTICLE <a@example.com>\r\n", primaryConn.write.String())
+	assert.Equal(t, authCommands+"ARTICLE <a@example.com>\r\n", blockConn.write.String())
+}
+
+func TestFailover_SetMaxMisses(t *testing.T) {
+	primary, primaryConn := getAuthenticatedClient(t)
+	block, blockConn := getAuthenticatedClient(t)
+
+	// a & b are missing on primary, remembering b forgets a
+	primaryConn.RecordPrintfLine(t, "430 No such article")
+	primaryConn.RecordPrintfLine(t, "430 No such article")
+	primaryConn.RecordPrintfLine(t, "223 0 <a@example.com>")
+
+	for i := 0; i < 3; i++ {
+		blockConn.RecordPrintfLine(t, "223 0 <a@example.com>")
+	}
+
+	f := nntp.NewFailover(
+		nntp.Provider{Name: "primary", Client: primary},
+		nntp.Provider{Name: "block", Client: block},
+	)
+	f.SetMaxMisses(1)
+
+	for _, id := range []string{"<a@example.com>", "<b@example.com>", "<b@example.com>", "<a@example.com>"} {
+		_, err := f.Stat(id)
+		require.NoError(t, err, "Failed to stat %s", id)
+	}
+
+	assert.Equal(t,
+		authCommands+"STAT <a@example.com>\r\nSTAT <b@example.com>\r\nSTAT <a@example.com>\r\n",
+		primaryConn.write.String(),
+	)
+	assert.Equal(t,
+		authCommands+"STAT <a@example.com>\r\nSTAT <b@example.com>\r\nSTAT <b@example.com>\r\n",
+		blockConn.write.String(),
+	)
+}
+
+func TestFailover_SetMaxMisses_Many(t *testing.T) {
+	primary, primaryConn := getAuthenticatedClient(t)
+	block, blockConn := getAuthenticatedClient(t)
+
+	ids := []string{"<1@example.com>", "<2@example.com>", "<3@example.com>", "<4@example.com>", "<5@example.com>"}
+
+	for range ids {
+		primaryConn.RecordPrintfLine(t, "430 No such article")
+	}
+
+	primaryConn.RecordPrintfLine(t, "223 0 <3@example.com>")
+
+	for i := 0; i < len(ids)+2; i++ {
+		blockConn.RecordPrintfLine(t, "223 0 <1@example.com>")
+	}
+
+	f := nntp.NewFailover(
+		nntp.Provider{Name: "primary", Client: primary},
+		nntp.Provider{Name: "block", Client: block},
+	)
+	f.SetMaxMisses(2)
+
+	// Only the last two missing ids are remembered
+	for _, id := range append(ids, "<4@example.com>", "<5@example.com>", "<3@example.com>") {
+		_, err := f.Stat(id)
+		require.NoError(t, err, "Failed to stat %s", id)
+	}
+
+	assert.Equal(t,
+		authCommands+"STAT <1@example.com>\r\nSTAT <2@example.com>\r\nSTAT <3@example.com>\r\nSTAT <4@example.com>\r\n"+
+			"STAT <5@example.com>\r\nSTAT <3@example.com>\r\n",
+		primaryConn.write.String(),
+	)
+}
+
+func TestFailover_Xover(t *testing.T) {
+	primary, primaryConn := getAuthenticatedClient(t)
+	primary.SetOverviewFormat(nntp.DefaultOverviewFormat())
+	block, blockConn := getAuthenticatedClient(t)
+	block.SetOverviewFormat(nntp.DefaultOverviewFormat())
+
+	primaryConn.RecordPrintfLine(t, "411 No such newsgroup")
+	blockConn.RecordPrintfLine(t, "211 2 1 2 group1")
+	blockConn.RecordPrintfLine(t, "224 Overview information follows")
+	blockConn.RecordDotMessage(t, "1	some subject	some author	Sun, 10 May 2020 00:32:22 +0000	<some-msg-id>		67755	519\n")
+
+	f := nntp.NewFailover(
+		nntp.Provider{Name: "primary", Client: primary},
+		nntp.Provider{Name: "block", Client: block},
+	)
+
+	headers, err := f.Xover("group1", "1-2")
+	require.NoError(t, err, "Failed to list headers")
+	require.Len(t, headers, 1)
+	assert.Equal(t, "<some-msg-id>", headers[0].MessageID)
+
+	stats := f.Stats()
+	assert.Equal(t, uint64(1), stats[0].Misses)
+	assert.Equal(t, uint64(1), stats[1].Overviews)
+
+	assert.Equal(t, authCommands+"GROUP group1\r\n", primaryConn.write.String())
+	assert.Equal(t, authCommands+"GROUP group1\r\nXOVER 1-2\r\n", blockConn.write.String())
+}
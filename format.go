@@ -1,137 +1,496 @@
 package nntp
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 )
 
 func NewOverviewFormat(fields []string) *OverviewFormat {
 	format := &OverviewFormat{
 		fieldNames:          make([]string, len(fields)),
 		lowercaseFieldNames: make([]string, len(fields)),
+		fields:              make([]overviewField, len(fields)),
 	}
 
 	for idx := range fields {
 		format.fieldNames[idx] = fields[idx]
 		format.lowercaseFieldNames[idx] = strings.ToLower(fields[idx])
+		format.fields[idx] = newOverviewField(fields[idx])
+
+		if format.fields[idx].kind == fieldAdditional {
+			format.additionalFields++
+		}
 	}
 
 	return format
 }
 
+// clone returns a copy of the format with the same settings.
+func (h *OverviewFormat) clone() *OverviewFormat {
+	format := NewOverviewFormat(h.fieldNames)
+	format.decoder, format.interner, format.lenient = h.decoder, h.interner, h.lenient
+
+	return format
+}
+
 type OverviewFormat struct {
 	fieldNames          []string
 	lowercaseFieldNames []string
+	// Precompiled mapping of the fields, so parsing doesn't need to look at the names
+	fields           []overviewField
+	additionalFields int
+
+	decoder  *HeaderDecoder
+	interner *Interner
+	lenient  bool
+
+	// Compiled OverviewDecoders by struct type
+	decoders sync.Map
+}
+
+// SetLenient enables the lenient parse mode: Fields which can't be parsed don't fail the whole line. Instead the
+// partially parsed header is returned & the failures are recorded in Header.Errors. Lines are only rejected if their
+// article number is invalid.
+func (h *OverviewFormat) SetLenient(lenient bool) {
+	h.lenient = lenient
+}
+
+// FieldError is a single overview field which could not be parsed.
+type FieldError struct {
+	// Index of the field within the overview format, not counting the article number
+	Index int
+	// Name of the field within the overview format, like "Date:". Empty for fields not covered by the format.
+	Field string
+	// Raw value of the field
+	Value string
+	Err   error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("field %d ('%s'): %v", e.Index, e.Field, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+type overviewFieldKind int
+
+const (
+	fieldAdditional overviewFieldKind = iota
+	fieldSubject
+	fieldFrom
+	fieldDate
+	fieldMessageID
+	fieldReferences
+	fieldBytes
+	fieldLines
+)
+
+type overviewField struct {
+	kind overviewFieldKind
+	// Key within Header.Additional
+	key string
+	// Prefix of ":full" fields, like "Xref:"
+	fullPrefix string
+	// Whether the values are interned, see OverviewFormat.SetInterner
+	intern bool
+}
+
+// Additional fields with few distinct values, which are interned
+var internedAdditionalFields = []string{"Newsgroups", "Followup-To", "Distribution", "Organization"}
+
+func newOverviewField(name string) overviewField {
+	switch strings.ToLower(name) {
+	case "subject:":
+		return overviewField{kind: fieldSubject}
+	case "from:":
+		return overviewField{kind: fieldFrom}
+	case "date:":
+		return overviewField{kind: fieldDate}
+	case "message-id:":
+		return overviewField{kind: fieldMessageID}
+	case "references:":
+		return overviewField{kind: fieldReferences}
+	case "bytes:", ":bytes":
+		return overviewField{kind: fieldBytes}
+	case "lines:", ":lines":
+		return overviewField{kind: fieldLines}
+	}
+
+	var field overviewField
+
+	// Remove the 'full' prefix & suffix
+	if strings.HasSuffix(strings.ToLower(name), ":full") {
+		name = name[0 : len(name)-4]
+		field.fullPrefix = name
+	}
+
+	field.key = strings.TrimSuffix(name, ":")
+
+	for _, interned := range internedAdditionalFields {
+		if strings.EqualFold(field.key, interned) {
+			field.intern = true
+		}
+	}
+
+	return field
+}
+
+// SetInterner enables interning of values with few distinct values, like the From field & additional fields like
+// "Newsgroups:full". Interned values don't share the memory of the parsed line. Interning gets disabled by passing nil.
+func (h *OverviewFormat) SetInterner(interner *Interner) {
+	h.interner = interner
+}
+
+// SetHeaderDecoder enables decoding of encoded-words & 8-bit text within the Subject & From fields. The decoded values
+// are stored in Header.DecodedSubject & Header.DecodedAuthor, Header.Subject & Header.Author keep the raw values.
+// Decoding gets disabled by passing nil.
+func (h *OverviewFormat) SetHeaderDecoder(decoder *HeaderDecoder) {
+	h.decoder = decoder
 }
 
 var ErrInvalidHeaderCount = errors.New("invalid number of headers given")
 
-func (h *OverviewFormat) FieldToHeader(idx int, value string, header *Header) (err error) {
-	if idx+1 > len(h.fieldNames) {
+func (h *OverviewFormat) FieldToHeader(idx int, value string, header *Header) error {
+	if idx+1 > len(h.fields) {
 		return fmt.Errorf(
 			"%w: header format only knows about %d field(s). %dth field given",
 			ErrInvalidHeaderCount,
-			len(h.fieldNames),
+			len(h.fields),
 			idx+1,
 		)
 	}
 
-	fieldName := h.fieldNames[idx]
-	lowercaseFieldName := h.lowercaseFieldNames[idx]
+	return h.setField(h.fields[idx], value, header)
+}
 
-	switch lowercaseFieldName {
-	case "subject:":
+func (h *OverviewFormat) setField(field overviewField, value string, header *Header) (err error) {
+	switch field.kind {
+	case fieldSubject:
 		header.Subject = value
-	case "from:":
-		header.Author = value
-	case "date:":
+		if h.decoder != nil {
+			header.DecodedSubject = h.decoder.Decode(value)
+		}
+	case fieldFrom:
+		header.Author = h.intern(value)
+		if h.decoder != nil {
+			header.DecodedAuthor = h.intern(h.decoder.Decode(value))
+		}
+	case fieldDate:
 		if header.Date, err = ParseDate(value); err != nil {
 			return fmt.Errorf("failed to parse date '%s': %w", value, err)
 		}
-	case "message-id:":
+	case fieldMessageID:
 		header.MessageID = value
-	case "references:":
+	case fieldReferences:
 		header.References = value
-	case "bytes:", ":bytes":
+	case fieldBytes:
 		// For some reason it's not always set
-		if strings.TrimSpace(value) == "" {
-			header.Bytes = 0
-		} else {
-			if header.Bytes, err = strconv.ParseUint(value, 10, 64); err != nil {
-				return fmt.Errorf("failed to parse bytes '%s': %w", value, err)
-			}
+		if header.Bytes, err = parseOverviewNumber(value); err != nil {
+			return fmt.Errorf("failed to parse bytes '%s': %w", value, err)
 		}
-	case "lines:", ":lines":
+	case fieldLines:
 		// For some reason it's not always set
-		if strings.TrimSpace(value) == "" {
-			header.Lines = 0
-		} else {
-			if header.Lines, err = strconv.ParseUint(value, 10, 64); err != nil {
-				return fmt.Errorf("failed to parse 'lines' field '%s': %w", value, err)
-			}
+		if header.Lines, err = parseOverviewNumber(value); err != nil {
+			return fmt.Errorf("failed to parse 'lines' field '%s': %w", value, err)
 		}
-
-	default:
+	case fieldAdditional:
 		if header.Additional == nil {
-			header.Additional = map[string]string{}
+			// Sized once for all additional fields of the format
+			header.Additional = make(map[string]string, h.additionalFields)
 		}
 
-		// Remove the 'full' prefix & suffix
-		if strings.HasSuffix(lowercaseFieldName, ":full") {
-			fieldName = fieldName[0 : len(fieldName)-4]
+		if field.fullPrefix != "" {
+			value = strings.TrimPrefix(value, field.fullPrefix)
+		}
 
-			value = strings.TrimPrefix(value, fieldName)
+		value = strings.TrimSpace(value)
+		if field.intern {
+			value = h.intern(value)
 		}
 
-		header.Additional[strings.TrimSuffix(fieldName, ":")] = strings.TrimSpace(value)
+		header.Additional[field.key] = value
 	}
 
 	return nil
 }
 
+func (h *OverviewFormat) intern(s string) string {
+	if h.interner == nil {
+		return s
+	}
+
+	return h.interner.Intern(s)
+}
+
+// parseOverviewNumber parses a decimal number, an empty value is zero.
+func parseOverviewNumber(value string) (uint64, error) {
+	if value == "" || value[0] == ' ' || value[len(value)-1] == ' ' {
+		if value = strings.TrimSpace(value); value == "" {
+			return 0, nil
+		}
+	}
+
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// ParseXoverLine parses a single line of overview data. The returned values share the memory of the line, except for
+// interned ones.
 func (h *OverviewFormat) ParseXoverLine(line string) (header Header, err error) {
-	fields := strings.Split(line, "\t")
+	end := strings.IndexByte(line, '\t')
+	if end < 0 {
+		end = len(line)
+	}
+
 	// MessageNumber doesn't get mentioned in the format, but it's always the first field.
-	if header.MessageNumber, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
-		return header, fmt.Errorf("failed to parse message number '%s': %w", fields[0], err)
+	if header.MessageNumber, err = strconv.ParseUint(line[:end], 10, 64); err != nil {
+		return header, fmt.Errorf("failed to parse message number '%s': %w", line[:end], err)
 	}
 
-	fields = fields[1:]
-	for idx := range fields {
-		if err := h.FieldToHeader(idx, fields[idx], &header); err != nil {
-			return header, fmt.Errorf("failed to map field %d ('%s'): %w", idx, fields[idx], err)
+	for idx := 0; end < len(line); idx++ {
+		start := end + 1
+
+		end = strings.IndexByte(line[start:], '\t')
+		if end < 0 {
+			end = len(line)
+		} else {
+			end += start
+		}
+
+		value := line[start:end]
+
+		if err := h.FieldToHeader(idx, value, &header); err != nil {
+			if !h.lenient {
+				return header, fmt.Errorf("failed to map field %d ('%s'): %w", idx, value, err)
+			}
+
+			fieldError := FieldError{Index: idx, Value: value, Err: err}
+			if idx < len(h.fieldNames) {
+				fieldError.Field = h.fieldNames[idx]
+			}
+
+			header.Errors = append(header.Errors, fieldError)
 		}
 	}
 
-	return header, err
+	return header, nil
 }
 
-var ErrInvalidDateFormat = errors.New("invalid date format")
+// ParseXoverLineBytes is like ParseXoverLine, but parses a line as returned by textproto.Reader.ReadLineBytes or
+// bufio.Scanner, so the line may be reused afterwards. Numbers are parsed & interned values are looked up without
+// allocating, only the remaining values are copied into a single string.
+func (h *OverviewFormat) ParseXoverLineBytes(line []byte) (header Header, err error) {
+	end := bytes.IndexByte(line, '\t')
+	if end < 0 {
+		end = len(line)
+	}
+
+	if header.MessageNumber, err = parseOverviewNumberBytes(line[:end]); err == nil && end == 0 {
+		_, err = strconv.ParseUint("", 10, 64)
+	}
+
+	if err != nil {
+		return header, fmt.Errorf("failed to parse message number '%s': %w", line[:end], err)
+	}
+
+	fields := line[end:]
+
+	// Field boundaries, so the line only gets split once. Usual formats fit into the array on the stack.
+	var (
+		endsBuf [24]int
+		ends    = endsBuf[:0]
+		// Bit per field whose value gets copied
+		copiedFields uint64
+		size         int
+	)
+
+	for start := 0; start < len(fields); {
+		next := bytes.IndexByte(fields[start+1:], '\t')
+		if next < 0 {
+			next = len(fields)
+		} else {
+			next += start + 1
+		}
+
+		if idx := len(ends); idx < len(h.fields) && idx < 64 && h.copied(h.fields[idx]) {
+			copiedFields |= 1 << idx
+			size += next - start - 1
+		}
+
+		ends = append(ends, next)
+		start = next
+	}
+
+	// The copied values share one allocation. The builder must not grow, so its substrings stay valid.
+	var copied strings.Builder
+
+	copied.Grow(size)
+
+	for idx, start := 0, 0; idx < len(ends); idx++ {
+		var (
+			value    = fields[start+1 : ends[idx]]
+			fieldErr error
+		)
 
-func ParseDate(s string) (time.Time, error) {
-	layouts := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		"2 Jan 2006 15:04:05 -0700",
-		"Mon, 02 Jan 2006 15:04:05 -0700 (MST)",
-		"Mon, 02 Jan 06 15:04:05 MST",
-		"02 Jan 06 15:04:05 MST",
-		"02 Jan 2006 15:04:05 MST",
+		start = ends[idx]
+
+		switch {
+		case idx >= len(h.fields):
+			fieldErr = h.FieldToHeader(idx, string(value), &header)
+		case copiedFields&(1<<idx) != 0:
+			copied.Write(value)
+			owned := copied.String()
+			fieldErr = h.setField(h.fields[idx], owned[len(owned)-len(value):], &header)
+		default:
+			fieldErr = h.setFieldBytes(h.fields[idx], value, &header)
+		}
+
+		if fieldErr == nil {
+			continue
+		}
+
+		if !h.lenient {
+			return header, fmt.Errorf("failed to map field %d ('%s'): %w", idx, value, fieldErr)
+		}
+
+		fieldError := FieldError{Index: idx, Value: string(value), Err: fieldErr}
+		if idx < len(h.fieldNames) {
+			fieldError.Field = h.fieldNames[idx]
+		}
+
+		header.Errors = append(header.Errors, fieldError)
 	}
 
-	for _, layout := range layouts {
-		t, err := time.Parse(layout, s)
+	return header, nil
+}
+
+// copied reports whether ParseXoverLineBytes needs to copy the value of the field, because it's neither a number, a
+// date nor interned.
+func (h *OverviewFormat) copied(field overviewField) bool {
+	switch field.kind {
+	case fieldDate, fieldBytes, fieldLines:
+		return false
+	case fieldFrom:
+		return h.interner == nil
+	case fieldAdditional:
+		return h.interner == nil || !field.intern
+	default:
+		return true
+	}
+}
+
+// setFieldBytes is like setField for the fields which aren't copied.
+func (h *OverviewFormat) setFieldBytes(field overviewField, value []byte, header *Header) (err error) {
+	switch field.kind {
+	case fieldDate:
+		// The converted value doesn't escape, so usual dates are converted on the stack
+		if date, ok := parseDateFast(string(value)); ok {
+			header.Date = date
+		} else if header.Date, err = ParseDate(string(value)); err != nil {
+			return fmt.Errorf("failed to parse date '%s': %w", value, err)
+		}
+	case fieldBytes:
+		if header.Bytes, err = parseOverviewNumberBytes(bytes.TrimSpace(value)); err != nil {
+			return fmt.Errorf("failed to parse bytes '%s': %w", value, err)
+		}
+	case fieldLines:
+		if header.Lines, err = parseOverviewNumberBytes(bytes.TrimSpace(value)); err != nil {
+			return fmt.Errorf("failed to parse 'lines' field '%s': %w", value, err)
+		}
+	case fieldFrom:
+		header.Author = h.interner.InternBytes(value)
+		if h.decoder != nil {
+			header.DecodedAuthor = h.intern(h.decoder.Decode(header.Author))
+		}
+	case fieldAdditional:
+		if header.Additional == nil {
+			header.Additional = make(map[string]string, h.additionalFields)
+		}
+
+		// Comparing a converted slice with a string doesn't allocate
+		prefix := field.fullPrefix
+		if prefix != "" && len(value) >= len(prefix) && string(value[:len(prefix)]) == prefix {
+			value = value[len(prefix):]
+		}
+
+		header.Additional[field.key] = h.interner.InternBytes(bytes.TrimSpace(value))
+	}
+
+	return nil
+}
+
+// parseOverviewNumberBytes parses a decimal number without allocating, an empty value is zero.
+func parseOverviewNumberBytes(value []byte) (uint64, error) {
+	// Longer values may overflow, strconv reports the proper error
+	if len(value) > 19 {
+		return strconv.ParseUint(string(value), 10, 64)
+	}
+
+	var number uint64
+
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return strconv.ParseUint(string(value), 10, 64)
+		}
+
+		number = number*10 + uint64(c-'0')
+	}
+
+	return number, nil
+}
+
+// OverviewSummary describes the result of parsing overview data in lenient mode.
+type OverviewSummary struct {
+	Lines int
+	// Lines parsed without errors
+	Parsed int
+	// Lines returned with field errors. See Header.Errors.
+	Degraded int
+	// Lines which could not be parsed at all
+	Skipped      int
+	SkippedLines []SkippedLine
+}
+
+// SkippedLine is an overview line which could not be parsed at all.
+type SkippedLine struct {
+	Line string
+	Err  error
+}
+
+// ParseXoverLines parses all lines. Without lenient mode, the first error aborts parsing. In lenient mode, degraded
+// lines are returned with their field errors, unparsable lines are skipped & all problems are listed in the summary.
+func (h *OverviewFormat) ParseXoverLines(lines []string) ([]Header, OverviewSummary, error) {
+	summary := OverviewSummary{Lines: len(lines)}
+	headers := make([]Header, 0, len(lines))
+
+	for _, line := range lines {
+		header, err := h.ParseXoverLine(line)
 		if err != nil {
+			if !h.lenient {
+				return nil, summary, fmt.Errorf("failed to parse line '%s': %w", line, err)
+			}
+
+			summary.Skipped++
+			summary.SkippedLines = append(summary.SkippedLines, SkippedLine{Line: line, Err: err})
+
 			continue
 		}
 
-		return t, nil
+		if len(header.Errors) > 0 {
+			summary.Degraded++
+		} else {
+			summary.Parsed++
+		}
+
+		headers = append(headers, header)
 	}
 
-	return time.Time{}, fmt.Errorf("%w: does not match known format. Known formats: %v", ErrInvalidDateFormat, layouts)
+	return headers, summary, nil
 }
 
 func DefaultOverviewFormat() *OverviewFormat {
@@ -1,6 +1,10 @@
 package nntp_test
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -197,3 +201,217 @@ func TestParseDate(t *testing.T) {
 		})
 	}
 }
+
+func TestOverviewFormat_Lenient(t *testing.T) {
+	format := nntp.DefaultOverviewFormat()
+
+	line := "1\tsome subject\tsome author\tnot a date\t<some-msg-id>\t\tabc\t519\textra"
+
+	_, err := format.ParseXoverLine(line)
+	require.Error(t, err, "Strict mode must fail on invalid fields")
+
+	format.SetLenient(true)
+
+	header, err := format.ParseXoverLine(line)
+	require.NoError(t, err, "Lenient mode must not fail on invalid fields")
+
+	assert.Equal(t, "some subject", header.Subject)
+	assert.Equal(t, "<some-msg-id>", header.MessageID)
+	assert.Equal(t, uint64(519), header.Lines)
+	assert.True(t, header.Date.IsZero())
+
+	require.Len(t, header.Errors, 3)
+	assert.Equal(t, 2, header.Errors[0].Index)
+	assert.Equal(t, "Date:", header.Errors[0].Field)
+	assert.Equal(t, "not a date", header.Errors[0].Value)
+	assert.True(t, errors.Is(header.Errors[0], nntp.ErrInvalidDateFormat))
+	assert.Equal(t, ":bytes", header.Errors[1].Field)
+	assert.Equal(t, "", header.Errors[2].Field)
+	assert.True(t, errors.Is(header.Errors[2], nntp.ErrInvalidHeaderCount))
+
+	_, err = format.ParseXoverLine("x\tsome subject")
+	assert.Error(t, err, "Lines with invalid article number must fail in lenient mode")
+}
+
+func TestOverviewFormat_ParseXoverLines(t *testing.T) {
+	lines := []string{
+		"1\tsubject\tauthor\tSun, 10 May 2020 00:32:22 +0000\t<1@example.com>\t\t1\t1",
+		"2\tsubject\tauthor\tbroken\t<2@example.com>\t\t1\t1",
+		"broken",
+		"4\tsubject\tauthor\tSun, 10 May 2020 00:32:22 +0000\t<4@example.com>\t\t1\t1",
+	}
+
+	format := nntp.DefaultOverviewFormat()
+
+	_, _, err := format.ParseXoverLines(lines)
+	assert.Error(t, err, "Strict mode must fail")
+
+	format.SetLenient(true)
+
+	headers, summary, err := format.ParseXoverLines(lines)
+	require.NoError(t, err, "Lenient mode must not fail")
+	require.Len(t, headers, 3)
+	assert.Equal(t, uint64(4), headers[2].MessageNumber)
+	assert.Len(t, headers[1].Errors, 1)
+
+	assert.Equal(t, 4, summary.Lines)
+	assert.Equal(t, 2, summary.Parsed)
+	assert.Equal(t, 1, summary.Degraded)
+	assert.Equal(t, 1, summary.Skipped)
+	require.Len(t, summary.SkippedLines, 1)
+	assert.Equal(t, "broken", summary.SkippedLines[0].Line)
+}
+
+const benchmarkOverviewLine = "3000234\tRe: some other subject (1/2) \"file.rar\" yEnc\t\"Test Author\" <test@example.com>\t" +
+	"Tue, 06 Oct 1998 04:38:40 -0500\t<some-other-msg-id@example.com>\t<some-other-ref@example.net>\t1234\t17\t" +
+	"Xref: news.example.com alt.binaries.test:3000234 alt.binaries.misc:123"
+
+func BenchmarkOverviewFormat_ParseXoverLine(b *testing.B) {
+	format := testOverviewFormat()
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := format.ParseXoverLine(benchmarkOverviewLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkOverviewFormat_ParseXoverLineBytes(b *testing.B) {
+	format := testOverviewFormat()
+	format.SetInterner(nntp.NewInterner())
+
+	line := []byte(benchmarkOverviewLine)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := format.ParseXoverLineBytes(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Baseline for ParseXoverLineBytes: Parsing a line read as bytes with ParseXoverLine requires copying it first
+func BenchmarkOverviewFormat_ParseXoverLineConverted(b *testing.B) {
+	format := testOverviewFormat()
+	format.SetInterner(nntp.NewInterner())
+
+	line := []byte(benchmarkOverviewLine)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := format.ParseXoverLine(string(line)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// parseXoverLineSplit is the former implementation of ParseXoverLine, which splits the whole line first
+func parseXoverLineSplit(format *nntp.OverviewFormat, line string) (header nntp.Header, err error) {
+	fields := strings.Split(line, "\t")
+	// MessageNumber doesn't get mentioned in the format, but it's always the first field.
+	if header.MessageNumber, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return header, fmt.Errorf("failed to parse message number '%s': %w", fields[0], err)
+	}
+
+	fields = fields[1:]
+	for idx := range fields {
+		if err := format.FieldToHeader(idx, fields[idx], &header); err != nil {
+			return header, fmt.Errorf("failed to map field %d ('%s'): %w", idx, fields[idx], err)
+		}
+	}
+
+	return header, err
+}
+
+// Baseline for ParseXoverLine & ParseXoverLineConverted
+func BenchmarkOverviewFormat_ParseXoverLineSplit(b *testing.B) {
+	format := testOverviewFormat()
+	format.SetInterner(nntp.NewInterner())
+
+	line := []byte(benchmarkOverviewLine)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parseXoverLineSplit(format, string(line)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestOverviewFormat_SetInterner(t *testing.T) {
+	format := nntp.NewOverviewFormat([]string{"Subject:", "From:", "Newsgroups:full"})
+
+	interner := nntp.NewInterner()
+	format.SetInterner(interner)
+
+	first, err := format.ParseXoverLineBytes([]byte("1\tsubject\tsome author\tNewsgroups: alt.test"))
+	require.NoError(t, err)
+
+	second, err := format.ParseXoverLine("2\tother subject\tsome author\tNewsgroups: alt.test")
+	require.NoError(t, err)
+
+	assert.Equal(t, "some author", first.Author)
+	assert.Equal(t, "alt.test", first.Additional["Newsgroups"])
+	assert.Equal(t, second.Author, first.Author)
+	assert.Equal(t, 2, interner.Len(), "Expected author & group to be interned once")
+}
+
+func TestOverviewFormat_ParseXoverLineBytes(t *testing.T) {
+	lines := []string{
+		benchmarkOverviewLine,
+		"1\tsubject\tauthor\tTue, 06 Oct 1998 04:38:40 -0500\t<id>\t\t 12 \t\t",
+		"2\tsubject\tauthor\tnot a date\t<id>\t\tabc\t17\tXref: host group:2\textra",
+		"3",
+		"\tsubject",
+		"x\tsubject",
+		"18446744073709551616\tsubject",
+	}
+
+	for _, interned := range []bool{false, true} {
+		for _, lenient := range []bool{false, true} {
+			format := nntp.NewOverviewFormat([]string{
+				"Subject:", "From:", "Date:", "Message-ID:", "References:", ":bytes", ":lines", "Xref:full", "Newsgroups:full",
+			})
+			format.SetLenient(lenient)
+
+			if interned {
+				format.SetInterner(nntp.NewInterner())
+			}
+
+			for _, line := range lines {
+				expected, expectedErr := format.ParseXoverLine(line)
+
+				buf := []byte(line)
+				header, err := format.ParseXoverLineBytes(buf)
+
+				// The parsed values must not share the memory of the line
+				for idx := range buf {
+					buf[idx] = 'x'
+				}
+
+				assert.Equal(t, expected, header, "Unexpected header of '%s' (interned: %v, lenient: %v)", line, interned, lenient)
+				assert.Equal(t, fmt.Sprint(expectedErr), fmt.Sprint(err), "Unexpected error for '%s'", line)
+			}
+		}
+	}
+}
+
+func TestInterner_Limit(t *testing.T) {
+	interner := nntp.NewInterner()
+	interner.SetLimit(2)
+
+	first := interner.Intern("a")
+	interner.InternBytes([]byte("b"))
+	assert.Equal(t, "c", interner.Intern("c"))
+	assert.Equal(t, "d", interner.InternBytes([]byte("d")))
+	assert.Equal(t, 2, interner.Len(), "Values beyond the limit must not be stored")
+	assert.Equal(t, first, interner.Intern("a"))
+
+	interner.Reset()
+	assert.Equal(t, 0, interner.Len())
+}
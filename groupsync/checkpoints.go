@@ -0,0 +1,134 @@
+package groupsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp/internal/atomicfile"
+)
+
+// Checkpoint is the synchronization state of a single group on a single server.
+type Checkpoint struct {
+	Server string
+	Group  string
+	// Number of the last article fetched
+	Last uint64
+	// Water marks reported by the server during the last run
+	Low  uint64
+	High uint64
+	// Time of the last run
+	Updated time.Time
+}
+
+type checkpointKey struct {
+	server string
+	group  string
+}
+
+// Checkpoints persists checkpoints in a JSON file. Every change atomically replaces the file, so an interrupted
+// process never leaves a partially written file behind.
+// All methods are safe for concurrent use.
+type Checkpoints struct {
+	path string
+
+	lock        sync.Mutex
+	checkpoints map[checkpointKey]Checkpoint
+
+	saveLock sync.Mutex
+}
+
+// OpenCheckpoints loads the checkpoints from path. A missing file results in no checkpoints.
+func OpenCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{
+		path:        path,
+		checkpoints: map[checkpointKey]Checkpoint{},
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	if err := json.Unmarshal(b, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint file '%s': %w", path, err)
+	}
+
+	for _, checkpoint := range checkpoints {
+		c.checkpoints[checkpointKey{server: checkpoint.Server, group: checkpoint.Group}] = checkpoint
+	}
+
+	return c, nil
+}
+
+// Get returns the checkpoint of the group on the server.
+func (c *Checkpoints) Get(server, group string) (Checkpoint, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	checkpoint, ok := c.checkpoints[checkpointKey{server: server, group: group}]
+
+	return checkpoint, ok
+}
+
+// Set stores the checkpoint & persists all checkpoints.
+func (c *Checkpoints) Set(checkpoint Checkpoint) error {
+	c.lock.Lock()
+	c.checkpoints[checkpointKey{server: checkpoint.Server, group: checkpoint.Group}] = checkpoint
+	c.lock.Unlock()
+
+	return c.save()
+}
+
+// Delete removes the checkpoint of the group on the server, so the next run starts from scratch.
+func (c *Checkpoints) Delete(server, group string) error {
+	c.lock.Lock()
+	delete(c.checkpoints, checkpointKey{server: server, group: group})
+	c.lock.Unlock()
+
+	return c.save()
+}
+
+// All returns all checkpoints sorted by server & group.
+func (c *Checkpoints) All() []Checkpoint {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	checkpoints := make([]Checkpoint, 0, len(c.checkpoints))
+	for _, checkpoint := range c.checkpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		if checkpoints[i].Server != checkpoints[j].Server {
+			return checkpoints[i].Server < checkpoints[j].Server
+		}
+
+		return checkpoints[i].Group < checkpoints[j].Group
+	})
+
+	return checkpoints
+}
+
+// save atomically replaces the checkpoint file.
+func (c *Checkpoints) save() error {
+	c.saveLock.Lock()
+	defer c.saveLock.Unlock()
+
+	b, err := json.Marshal(c.All())
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+
+	return atomicfile.WriteFile(c.path, b)
+}
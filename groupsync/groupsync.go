@@ -0,0 +1,201 @@
+// Package groupsync incrementally fetches the overview data of groups, remembering the last article fetched per server
+// & group.
+package groupsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+)
+
+// DefaultChunkSize is the default number of articles requested per XOVER command.
+const DefaultChunkSize = 10000
+
+// Client is the subset of nntp.Client used by the Syncer.
+type Client interface {
+	Group(name string) (nntp.NewsgroupDetail, error)
+	Xover(r string) ([]nntp.Header, error)
+}
+
+// Syncer fetches the overview data of articles which have been added to a group since the last run.
+// After every chunk the checkpoint gets persisted, so an interrupted run continues where it left off.
+type Syncer struct {
+	// Number of articles requested per XOVER command. Defaults to DefaultChunkSize.
+	ChunkSize uint64
+	// Limits the first run of a group to the newest articles. Zero fetches all available articles.
+	InitialArticles uint64
+
+	server      string
+	client      Client
+	checkpoints *Checkpoints
+
+	// Serializes GROUP + XOVER sequences
+	lock sync.Mutex
+}
+
+// New creates a syncer for the server, which is used to identify its checkpoints.
+func New(server string, client Client, checkpoints *Checkpoints) *Syncer {
+	return &Syncer{
+		ChunkSize:   DefaultChunkSize,
+		server:      server,
+		client:      client,
+		checkpoints: checkpoints,
+	}
+}
+
+// Result describes a single run of a group.
+type Result struct {
+	Group string
+	// Range of article numbers requested. Last < First if there were no new articles.
+	First uint64
+	Last  uint64
+	// Number of headers returned by the server
+	Headers int
+	// Articles which expired before they could be fetched, because the low water mark advanced past the checkpoint
+	Expired uint64
+	// Whether the high water mark dropped below the checkpoint, which happens if the server renumbered the group. The
+	// group gets fetched from scratch in that case.
+	Reset bool
+}
+
+// HandlerFunc processes the headers of a single chunk. Returning an error aborts the run without advancing the
+// checkpoint past the chunk.
+type HandlerFunc func(headers []nntp.Header) error
+
+// Sync fetches all new articles of the group & passes them to fn chunk by chunk.
+func (s *Syncer) Sync(ctx context.Context, group string, fn HandlerFunc) (Result, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := Result{Group: group}
+
+	detail, err := s.client.Group(group)
+	if err != nil {
+		return result, fmt.Errorf("failed to select group '%s': %w", group, err)
+	}
+
+	checkpoint, known := s.checkpoints.Get(s.server, group)
+	if known && detail.High < checkpoint.Last {
+		result.Reset = true
+		known = false
+	}
+
+	start := detail.Low
+
+	switch {
+	case known:
+		start = checkpoint.Last + 1
+		if start < detail.Low {
+			result.Expired = detail.Low - start
+			start = detail.Low
+		}
+	case s.InitialArticles > 0 && detail.High >= s.InitialArticles && detail.High-s.InitialArticles+1 > start:
+		start = detail.High - s.InitialArticles + 1
+	}
+
+	// Article numbers start at 1, empty groups may report 0
+	if start == 0 {
+		start = 1
+	}
+
+	checkpoint = Checkpoint{
+		Server: s.server,
+		Group:  group,
+		Last:   start - 1,
+		Low:    detail.Low,
+		High:   detail.High,
+	}
+
+	result.First, result.Last = start, start-1
+
+	chunkSize := s.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	for first := start; first <= detail.High; first += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		last := first + chunkSize - 1
+		if last > detail.High || last < first {
+			last = detail.High
+		}
+
+		headers, err := s.xover(first, last)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch overview of '%s' %d-%d: %w", group, first, last, err)
+		}
+
+		if err := fn(headers); err != nil {
+			return result, err
+		}
+
+		result.Last = last
+		result.Headers += len(headers)
+
+		checkpoint.Last = last
+		checkpoint.Updated = time.Now()
+
+		if err := s.checkpoints.Set(checkpoint); err != nil {
+			return result, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+
+		if last == detail.High {
+			break
+		}
+	}
+
+	if result.Last < result.First {
+		// No new articles, but the water marks may have changed
+		checkpoint.Updated = time.Now()
+
+		if err := s.checkpoints.Set(checkpoint); err != nil {
+			return result, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Syncer) xover(first, last uint64) ([]nntp.Header, error) {
+	headers, err := s.client.Xover(fmt.Sprintf("%d-%d", first, last))
+
+	// 423: No articles in that range
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == 423 {
+		return nil, nil
+	}
+
+	return headers, err
+}
+
+// SyncAll syncs the groups one after another. It stops at the first error.
+func (s *Syncer) SyncAll(ctx context.Context, groups []string, fn func(group string, headers []nntp.Header) error) (
+	[]Result,
+	error,
+) {
+	results := make([]Result, 0, len(groups))
+
+	for _, group := range groups {
+		group := group
+
+		result, err := s.Sync(ctx, group, func(headers []nntp.Header) error {
+			return fn(group, headers)
+		})
+
+		results = append(results, result)
+
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,166 @@
+package groupsync_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/groupsync"
+)
+
+var _ groupsync.Client = (*nntp.Client)(nil)
+
+type fakeClient struct {
+	group    nntp.NewsgroupDetail
+	missing  map[uint64]bool
+	requests []string
+}
+
+func (c *fakeClient) Group(name string) (nntp.NewsgroupDetail, error) {
+	detail := c.group
+	detail.Name = name
+
+	return detail, nil
+}
+
+func (c *fakeClient) Xover(r string) ([]nntp.Header, error) {
+	c.requests = append(c.requests, r)
+
+	var first, last uint64
+	if _, err := fmt.Sscanf(r, "%d-%d", &first, &last); err != nil {
+		return nil, err
+	}
+
+	var headers []nntp.Header
+
+	for number := first; number <= last; number++ {
+		if !c.missing[number] {
+			headers = append(headers, nntp.Header{MessageNumber: number})
+		}
+	}
+
+	if len(headers) == 0 {
+		return nil, &textproto.Error{Code: 423, Msg: "No articles in that range"}
+	}
+
+	return headers, nil
+}
+
+func numbers(headers []nntp.Header) []uint64 {
+	var n []uint64
+	for _, header := range headers {
+		n = append(n, header.MessageNumber)
+	}
+
+	return n
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+
+	checkpoints, err := groupsync.OpenCheckpoints(path)
+	require.NoError(t, err)
+
+	client := &fakeClient{
+		group:   nntp.NewsgroupDetail{Low: 1, High: 25},
+		missing: map[uint64]bool{},
+	}
+
+	for number := uint64(11); number <= 20; number++ {
+		client.missing[number] = true
+	}
+
+	syncer := groupsync.New("news.example.com", client, checkpoints)
+	syncer.ChunkSize = 10
+	syncer.InitialArticles = 20
+
+	var fetched []uint64
+	collect := func(headers []nntp.Header) error {
+		fetched = append(fetched, numbers(headers)...)
+		return nil
+	}
+
+	result, err := syncer.Sync(context.Background(), "misc.test", collect)
+	require.NoError(t, err)
+	assert.Equal(t, groupsync.Result{Group: "misc.test", First: 6, Last: 25, Headers: 10}, result)
+	assert.Equal(t, []string{"6-15", "16-25"}, client.requests)
+	assert.Equal(t, []uint64{6, 7, 8, 9, 10, 21, 22, 23, 24, 25}, fetched)
+
+	t.Run("no new articles", func(t *testing.T) {
+		client.requests, fetched = nil, nil
+
+		result, err := syncer.Sync(context.Background(), "misc.test", collect)
+		require.NoError(t, err)
+		assert.Equal(t, groupsync.Result{Group: "misc.test", First: 26, Last: 25}, result)
+		assert.Empty(t, client.requests)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		client.requests, fetched = nil, nil
+		client.group = nntp.NewsgroupDetail{Low: 30, High: 32}
+
+		result, err := syncer.Sync(context.Background(), "misc.test", collect)
+		require.NoError(t, err)
+		assert.Equal(t, groupsync.Result{Group: "misc.test", First: 30, Last: 32, Headers: 3, Expired: 4}, result)
+		assert.Equal(t, []uint64{30, 31, 32}, fetched)
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		client.group.High = 40
+
+		errHandler := errors.New("handler failed")
+		_, err := syncer.Sync(context.Background(), "misc.test", func([]nntp.Header) error {
+			return errHandler
+		})
+		assert.True(t, errors.Is(err, errHandler), "Expected handler error, got %v", err)
+
+		checkpoint, ok := checkpoints.Get("news.example.com", "misc.test")
+		require.True(t, ok)
+		assert.Equal(t, uint64(32), checkpoint.Last, "Checkpoint must not advance past a failed chunk")
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		client.requests, fetched = nil, nil
+		client.group = nntp.NewsgroupDetail{Low: 1, High: 3}
+
+		result, err := syncer.Sync(context.Background(), "misc.test", collect)
+		require.NoError(t, err)
+		assert.Equal(t, groupsync.Result{Group: "misc.test", First: 1, Last: 3, Headers: 3, Reset: true}, result)
+	})
+
+	t.Run("persisted", func(t *testing.T) {
+		loaded, err := groupsync.OpenCheckpoints(path)
+		require.NoError(t, err)
+
+		all := loaded.All()
+		require.Len(t, all, 1)
+		assert.Equal(t, "news.example.com", all[0].Server)
+		assert.Equal(t, "misc.test", all[0].Group)
+		assert.Equal(t, uint64(3), all[0].Last)
+		assert.Equal(t, uint64(1), all[0].Low)
+		assert.Equal(t, uint64(3), all[0].High)
+	})
+}
+
+func TestSyncer_SyncCancelled(t *testing.T) {
+	checkpoints, err := groupsync.OpenCheckpoints(filepath.Join(t.TempDir(), "checkpoints.json"))
+	require.NoError(t, err)
+
+	client := &fakeClient{group: nntp.NewsgroupDetail{Low: 1, High: 10}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = groupsync.New("news.example.com", client, checkpoints).Sync(ctx, "misc.test", func([]nntp.Header) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, context.Canceled), "Expected context.Canceled, got %v", err)
+	assert.Empty(t, client.requests)
+}
@@ -0,0 +1,79 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// HeaderValue is the value of a single header field or metadata item of an article, as returned by HDR.
+type HeaderValue struct {
+	// Article number, zero if the article has been requested by message-id
+	Number uint64
+	Value  string
+}
+
+var ErrInvalidHdrLineReturned = errors.New("invalid hdr line returned. Line must start with the article number")
+
+// Hdr retrieves a single header field like "Date" or metadata item like ":bytes" of the articles identified by r, which
+// is either a range like "1000-2000" or a message-id. HDR (RFC 3977) is used if supported by the server, otherwise
+// the older XHDR command. Headers are only retrieved for articles of the currently selected group.
+func (c *Client) Hdr(field, r string) ([]HeaderValue, error) {
+	if !c.noHdr {
+		values, err := c.hdr("HDR", 225, field, r)
+
+		// 500: Unknown command
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) || protoErr.Code != 500 {
+			return values, err
+		}
+
+		c.noHdr = true
+	}
+
+	return c.hdr("XHDR", 221, field, r)
+}
+
+func (c *Client) hdr(command string, expectCode int, field, r string) ([]HeaderValue, error) {
+	id, err := c.connection.Cmd("%s %s %s", command, field, r)
+	if err != nil {
+		return nil, err
+	}
+
+	c.connection.StartResponse(id)
+	defer c.connection.EndResponse(id)
+
+	if _, _, err = c.connection.ReadCodeLine(expectCode); err != nil {
+		return nil, err
+	}
+
+	lines, err := c.connection.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]HeaderValue, 0, len(lines))
+
+	for _, line := range lines {
+		number, value := line, ""
+		if idx := strings.IndexByte(line, ' '); idx >= 0 {
+			number, value = line[:idx], line[idx+1:]
+		}
+
+		parsed, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidHdrLineReturned, line)
+		}
+
+		// XHDR marks missing fields with "(none)"
+		if command == "XHDR" && value == "(none)" {
+			value = ""
+		}
+
+		values = append(values, HeaderValue{Number: parsed, Value: value})
+	}
+
+	return values, nil
+}
@@ -0,0 +1,79 @@
+package nntp
+
+import "sync"
+
+// Interner deduplicates strings, so repeated values like posters or groups share their memory.
+// All methods are safe for concurrent use.
+type Interner struct {
+	lock    sync.Mutex
+	strings map[string]string
+	limit   int
+}
+
+func NewInterner() *Interner {
+	return &Interner{
+		strings: map[string]string{},
+	}
+}
+
+// Intern returns the stored copy of s. Unknown values are copied before they are stored, so they don't keep a larger
+// string they are part of alive.
+func (i *Interner) Intern(s string) string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if interned, ok := i.strings[s]; ok {
+		return interned
+	}
+
+	interned := string([]byte(s))
+	i.store(interned)
+
+	return interned
+}
+
+// InternBytes is like Intern, but doesn't allocate for known values.
+func (i *Interner) InternBytes(b []byte) string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if interned, ok := i.strings[string(b)]; ok {
+		return interned
+	}
+
+	interned := string(b)
+	i.store(interned)
+
+	return interned
+}
+
+// Len returns the number of distinct values.
+func (i *Interner) Len() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return len(i.strings)
+}
+
+// SetLimit limits the number of stored values, so long scans over values with many distinct values don't grow the
+// interner forever. Values beyond the limit are returned as copies without being stored. Zero means unlimited.
+func (i *Interner) SetLimit(limit int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.limit = limit
+}
+
+// Reset removes all stored values.
+func (i *Interner) Reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.strings = map[string]string{}
+}
+
+func (i *Interner) store(s string) {
+	if i.limit == 0 || len(i.strings) < i.limit {
+		i.strings[s] = s
+	}
+}
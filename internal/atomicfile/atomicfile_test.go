@@ -0,0 +1,52 @@
+package atomicfile_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp/internal/atomicfile"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	require.NoError(t, atomicfile.WriteFile(path, []byte("first")), "Failed to write file")
+	require.NoError(t, atomicfile.WriteFile(path, []byte("second")), "Failed to replace file")
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read file")
+	assert.Equal(t, "second", string(b))
+}
+
+func TestWrite_Error(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	require.NoError(t, atomicfile.WriteFile(path, []byte("first")), "Failed to write file")
+
+	failed := errors.New("failed")
+	err := atomicfile.Write(path, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "partial"); err != nil {
+			return err
+		}
+
+		return failed
+	})
+	assert.True(t, errors.Is(err, failed), "Unexpected error %v", err)
+
+	// The old content is kept & the temporary file removed
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read file")
+	assert.Equal(t, "first", string(b))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err, "Failed to list directory")
+	assert.Len(t, entries, 1)
+}
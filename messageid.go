@@ -0,0 +1,218 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MessageID is a message-id including the angle brackets, like "<part1of10.abc@example.com>".
+type MessageID string
+
+// Maximum length of a message-id according to RFC 5536 section 3.1.3
+const maxMessageIDLength = 250
+
+var ErrInvalidMessageID = errors.New("invalid message-id")
+
+// ParseMessageID validates the message-id according to RFC 5536. Surrounding whitespace is removed & missing angle
+// brackets are added, as NZB files usually contain message-ids without them.
+func ParseMessageID(s string) (MessageID, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "<") && !strings.HasSuffix(s, ">") {
+		s = "<" + s + ">"
+	}
+
+	id := MessageID(s)
+
+	return id, id.Validate()
+}
+
+// Validate checks the message-id according to RFC 5536 section 3.1.3: The id-left part must be a dot-atom-text, the
+// id-right part a dot-atom-text or a literal in square brackets.
+func (m MessageID) Validate() error {
+	s := string(m)
+
+	if len(s) > maxMessageIDLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidMessageID, maxMessageIDLength)
+	}
+
+	if !strings.HasPrefix(s, "<") || !strings.HasSuffix(s, ">") || len(s) < 2 {
+		return fmt.Errorf("%w: '%s' must be enclosed in angle brackets", ErrInvalidMessageID, s)
+	}
+
+	idx := strings.IndexByte(s, '@')
+	if idx < 0 {
+		return fmt.Errorf("%w: '%s' does not contain '@'", ErrInvalidMessageID, s)
+	}
+
+	left, right := s[1:idx], s[idx+1:len(s)-1]
+
+	if !isDotAtomText(left) {
+		return fmt.Errorf("%w: invalid left part '%s'", ErrInvalidMessageID, left)
+	}
+
+	if !isDotAtomText(right) && !isNoFoldLiteral(right) {
+		return fmt.Errorf("%w: invalid right part '%s'", ErrInvalidMessageID, right)
+	}
+
+	return nil
+}
+
+func (m MessageID) Valid() bool {
+	return m.Validate() == nil
+}
+
+// Left returns the part before the '@' without the opening angle bracket.
+func (m MessageID) Left() string {
+	bare := m.Bare()
+
+	if idx := strings.IndexByte(bare, '@'); idx >= 0 {
+		return bare[:idx]
+	}
+
+	return bare
+}
+
+// Right returns the part after the '@' without the closing angle bracket. Empty if the message-id has no '@'.
+func (m MessageID) Right() string {
+	bare := m.Bare()
+
+	if idx := strings.IndexByte(bare, '@'); idx >= 0 {
+		return bare[idx+1:]
+	}
+
+	return ""
+}
+
+// Bare returns the message-id without angle brackets, as used within NZB files.
+func (m MessageID) Bare() string {
+	return strings.TrimSuffix(strings.TrimPrefix(string(m), "<"), ">")
+}
+
+func (m MessageID) String() string {
+	return string(m)
+}
+
+func isAtext(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+func isDotAtomText(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' {
+		return false
+	}
+
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] == '.' {
+			if s[idx-1] == '.' {
+				return false
+			}
+
+			continue
+		}
+
+		if !isAtext(s[idx]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isNoFoldLiteral checks for "[" *mdtext "]", where mdtext is any printable character except '>', '[', '\' & ']'.
+func isNoFoldLiteral(s string) bool {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return false
+	}
+
+	for idx := 1; idx < len(s)-1; idx++ {
+		c := s[idx]
+		if c < 33 || c > 126 || c == '>' || c == '[' || c == '\\' || c == ']' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseReferences splits the value of a References header into message-ids, oldest first.
+// The parser is lenient towards the damage seen in real overview data: Whitespace within a message-id (caused by
+// broken folding) is removed, missing separators between message-ids are tolerated, message-ids without angle brackets
+// are accepted if they contain an '@' & unterminated message-ids are skipped. Duplicate message-ids are only returned
+// once. The returned message-ids are not validated, as many servers & clients produce message-ids violating RFC 5536.
+func ParseReferences(references string) []MessageID {
+	var (
+		ids  []MessageID
+		seen = map[MessageID]struct{}{}
+	)
+
+	add := func(id MessageID) {
+		if _, ok := seen[id]; ok {
+			return
+		}
+
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	for rest := references; rest != ""; {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			addBareReferences(rest, add)
+			break
+		}
+
+		addBareReferences(rest[:start], add)
+		rest = rest[start+1:]
+
+		end := strings.IndexAny(rest, "<>")
+		if end < 0 {
+			// Unterminated
+			break
+		}
+
+		if rest[end] == '<' {
+			// Missing closing bracket, continue with the next message-id
+			rest = rest[end:]
+			continue
+		}
+
+		if core := removeWhitespace(rest[:end]); core != "" {
+			add(MessageID("<" + core + ">"))
+		}
+
+		rest = rest[end+1:]
+	}
+
+	return ids
+}
+
+func addBareReferences(s string, add func(MessageID)) {
+	for _, field := range strings.Fields(s) {
+		field = strings.Trim(field, ",;")
+		if strings.Contains(field, "@") && !strings.Contains(field, ">") {
+			add(MessageID("<" + field + ">"))
+		}
+	}
+}
+
+func removeWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+// ReferenceIDs parses the References of the header. See ParseReferences.
+func (h Header) ReferenceIDs() []MessageID {
+	return ParseReferences(h.References)
+}
@@ -0,0 +1,100 @@
+package nntp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestParseMessageID(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   nntp.MessageID
+		expectedOK bool
+	}{
+		{input: "<part1of10.abc@example.com>", expected: "<part1of10.abc@example.com>", expectedOK: true},
+		{input: " part1of10.abc@example.com\r\n", expected: "<part1of10.abc@example.com>", expectedOK: true},
+		{input: "<abc@[127.0.0.1]>", expected: "<abc@[127.0.0.1]>", expectedOK: true},
+		{input: "<a$b!c@d.e>", expected: "<a$b!c@d.e>", expectedOK: true},
+		{input: "<abc@example com>", expected: "<abc@example com>", expectedOK: false},
+		{input: "<abc.example.com>", expected: "<abc.example.com>", expectedOK: false},
+		{input: "<a..b@example.com>", expected: "<a..b@example.com>", expectedOK: false},
+		{input: "<.ab@example.com>", expected: "<.ab@example.com>", expectedOK: false},
+		{input: "<ab@example.com", expected: "<ab@example.com", expectedOK: false},
+		{input: "<ab@[a]b]>", expected: "<ab@[a]b]>", expectedOK: false},
+		{input: "<ab@>", expected: "<ab@>", expectedOK: false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.input, func(t *testing.T) {
+			id, err := nntp.ParseMessageID(test.input)
+			assert.Equal(t, test.expected, id)
+
+			if test.expectedOK {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, nntp.ErrInvalidMessageID), "Expected ErrInvalidMessageID, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMessageID_Parts(t *testing.T) {
+	id := nntp.MessageID("<part1of10.abc@example.com>")
+
+	assert.Equal(t, "part1of10.abc", id.Left())
+	assert.Equal(t, "example.com", id.Right())
+	assert.Equal(t, "part1of10.abc@example.com", id.Bare())
+	assert.True(t, id.Valid())
+}
+
+func TestParseReferences(t *testing.T) {
+	tests := []struct {
+		name       string
+		references string
+		expected   []nntp.MessageID
+	}{
+		{
+			name:       "space separated",
+			references: "<a@example.com> <b@example.com>\t<c@example.com>",
+			expected:   []nntp.MessageID{"<a@example.com>", "<b@example.com>", "<c@example.com>"},
+		},
+		{
+			name:       "missing separators",
+			references: "<a@example.com><b@example.com>",
+			expected:   []nntp.MessageID{"<a@example.com>", "<b@example.com>"},
+		},
+		{
+			name:       "broken folding within message-id",
+			references: "<a@example.com> <b@exam\r\n\tple.com>",
+			expected:   []nntp.MessageID{"<a@example.com>", "<b@example.com>"},
+		},
+		{
+			name:       "missing brackets and closing bracket",
+			references: "a@example.com, <b@example.com <c@example.com> trailing",
+			expected:   []nntp.MessageID{"<a@example.com>", "<c@example.com>"},
+		},
+		{
+			name:       "duplicates and unterminated",
+			references: "<a@example.com> <b@example.com> <a@example.com> <c@exa",
+			expected:   []nntp.MessageID{"<a@example.com>", "<b@example.com>"},
+		},
+		{
+			name:       "empty",
+			references: " ",
+			expected:   nil,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, nntp.ParseReferences(test.references))
+			assert.Equal(t, test.expected, nntp.Header{References: test.references}.ReferenceIDs())
+		})
+	}
+}
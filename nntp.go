@@ -1,6 +1,7 @@
 package nntp
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -13,7 +14,14 @@ import (
 type Client struct {
 	connection *textproto.Conn
 
-	headerFormat *OverviewFormat
+	headerFormat    *OverviewFormat
+	headerDecoder   *HeaderDecoder
+	interner        *Interner
+	lenientOverview bool
+	// Whether SetLenientOverview has been called, so the mode overrides the one of formats set afterwards
+	lenientOverviewSet bool
+	// Whether the server rejected HDR, so XHDR is used instead
+	noHdr bool
 }
 
 var ErrInvalidGreetingResponse = errors.New("invalid greeting response returned from server")
@@ -177,6 +185,42 @@ func (c *Client) Newsgroups(since time.Time) ([]NewsgroupOverview, error) {
 	return groups, nil
 }
 
+// ListActive lists the water marks & posting status of the groups matching the wildmat, like "comp.*". An empty
+// wildmat lists all groups.
+func (c *Client) ListActive(wildmat string) ([]NewsgroupOverview, error) {
+	command := "LIST ACTIVE"
+	if wildmat != "" {
+		command += " " + wildmat
+	}
+
+	id, err := c.connection.Cmd("%s", command)
+	if err != nil {
+		return nil, err
+	}
+
+	c.connection.StartResponse(id)
+	defer c.connection.EndResponse(id)
+
+	if _, _, err := c.connection.ReadCodeLine(215); err != nil {
+		return nil, err
+	}
+
+	lines, err := c.connection.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]NewsgroupOverview, len(lines))
+	for i := range lines {
+		groups[i], err = parseNewsgroupOverview(lines[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse active line '%s'. %w", lines[i], err)
+		}
+	}
+
+	return groups, nil
+}
+
 var (
 	ErrInvalidNewsgroupOverviewLineReturned = errors.New("invalid news group overview line returned. Line must consist of 4 parts separated by space")
 
@@ -262,10 +306,39 @@ func (c *Client) Group(g string) (group NewsgroupDetail, err error) {
 	return group, err
 }
 
+// SetOverviewFormat sets the format used to parse overview data. The client uses a copy of the format, so the given
+// format is left unchanged. The settings of the format are kept, unless they have been set on the client, see
+// SetHeaderDecoder, SetLenientOverview & SetInterner.
 func (c *Client) SetOverviewFormat(format *OverviewFormat) {
+	c.setOverviewFormat(format.clone())
+}
+
+func (c *Client) setOverviewFormat(format *OverviewFormat) {
+	if c.headerDecoder != nil {
+		format.SetHeaderDecoder(c.headerDecoder)
+	}
+
+	if c.lenientOverviewSet {
+		format.SetLenient(c.lenientOverview)
+	}
+
+	if c.interner != nil {
+		format.SetInterner(c.interner)
+	}
+
 	c.headerFormat = format
 }
 
+// SetHeaderDecoder enables decoding of the Subject & From fields of overview data. See OverviewFormat.SetHeaderDecoder.
+// The decoder is used for the current & all future overview formats, including the one requested from the server.
+func (c *Client) SetHeaderDecoder(decoder *HeaderDecoder) {
+	c.headerDecoder = decoder
+
+	if c.headerFormat != nil {
+		c.headerFormat.SetHeaderDecoder(decoder)
+	}
+}
+
 func (c *Client) InitializeOverviewFormat() error {
 	id, err := c.connection.Cmd("LIST OVERVIEW.FMT")
 	if err != nil {
@@ -284,44 +357,63 @@ func (c *Client) InitializeOverviewFormat() error {
 		return err
 	}
 
-	c.headerFormat = NewOverviewFormat(lines)
+	c.setOverviewFormat(NewOverviewFormat(lines))
 
 	return nil
 }
 
+// SetInterner enables interning of repeated overview values. See OverviewFormat.SetInterner.
+// The interner is used for the current & all future overview formats, including the one requested from the server.
+func (c *Client) SetInterner(interner *Interner) {
+	c.interner = interner
+
+	if c.headerFormat != nil {
+		c.headerFormat.SetInterner(interner)
+	}
+}
+
+// SetLenientOverview enables the lenient parse mode of overview data. See OverviewFormat.SetLenient.
+// The mode is used for the current & all future overview formats, including the one requested from the server.
+func (c *Client) SetLenientOverview(lenient bool) {
+	c.lenientOverview, c.lenientOverviewSet = lenient, true
+
+	if c.headerFormat != nil {
+		c.headerFormat.SetLenient(lenient)
+	}
+}
+
 func (c *Client) Xover(r string) ([]Header, error) {
+	headers, _, err := c.XoverSummary(r)
+
+	return headers, err
+}
+
+// XoverSummary is like Xover, but additionally returns a summary of degraded & skipped lines in lenient mode.
+func (c *Client) XoverSummary(r string) ([]Header, OverviewSummary, error) {
 	if c.headerFormat == nil {
 		if err := c.InitializeOverviewFormat(); err != nil {
-			return nil, fmt.Errorf("failed to initialize overview format: %w", err)
+			return nil, OverviewSummary{}, fmt.Errorf("failed to initialize overview format: %w", err)
 		}
 	}
 
 	id, err := c.connection.Cmd("XOVER %s", r)
 	if err != nil {
-		return nil, err
+		return nil, OverviewSummary{}, err
 	}
 
 	c.connection.StartResponse(id)
 	defer c.connection.EndResponse(id)
 
 	if _, _, err = c.connection.ReadCodeLine(224); err != nil {
-		return nil, err
+		return nil, OverviewSummary{}, err
 	}
 
 	lines, err := c.connection.ReadDotLines()
 	if err != nil {
-		return nil, err
-	}
-
-	headers := make([]Header, len(lines))
-	for idx := range lines {
-		headers[idx], err = c.headerFormat.ParseXoverLine(lines[idx])
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse line '%s': %w", lines[idx], err)
-		}
+		return nil, OverviewSummary{}, err
 	}
 
-	return headers, nil
+	return c.headerFormat.ParseXoverLines(lines)
 }
 
 func (c *Client) XoverChan(r string) (chan Header, chan error, error) {
@@ -351,7 +443,7 @@ func (c *Client) XoverChan(r string) (chan Header, chan error, error) {
 		defer close(errChan)
 
 		for {
-			line, err := c.connection.ReadLine()
+			line, err := readLineSlice(c.connection.R)
 			if err != nil {
 				if err == io.EOF {
 					err = io.ErrUnexpectedEOF
@@ -368,7 +460,7 @@ func (c *Client) XoverChan(r string) (chan Header, chan error, error) {
 				line = line[1:]
 			}
 
-			header, err := c.headerFormat.ParseXoverLine(line)
+			header, err := c.headerFormat.ParseXoverLineBytes(line)
 			if err != nil {
 				errChan <- fmt.Errorf("failed to parse line '%s': %w", line, err)
 				continue
@@ -381,14 +473,60 @@ func (c *Client) XoverChan(r string) (chan Header, chan error, error) {
 	return headerChan, errChan, nil
 }
 
+// readLineSlice reads a line like textproto.Reader.ReadLineBytes, but only copies lines longer than the buffer of r.
+// The line is only valid until the next read.
+func readLineSlice(r *bufio.Reader) ([]byte, error) {
+	var buf []byte
+
+	for {
+		line, more, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if !more && len(buf) == 0 {
+			return line, nil
+		}
+
+		buf = append(buf, line...)
+		if !more {
+			return buf, nil
+		}
+	}
+}
+
 type Header struct {
 	MessageNumber uint64
-	Subject       string
-	Author        string
-	Date          time.Time
-	MessageID     string
-	References    string
-	Bytes         uint64
-	Lines         uint64
-	Additional    map[string]string
+	// Raw values, as sent by the server
+	Subject string
+	Author  string
+	// Decoded values of Subject & Author. Only set if a HeaderDecoder is configured.
+	DecodedSubject string
+	DecodedAuthor  string
+	Date           time.Time
+	MessageID      string
+	References     string
+	Bytes          uint64
+	Lines          uint64
+	Additional     map[string]string
+	// Fields which could not be parsed. Only set in lenient mode, see OverviewFormat.SetLenient.
+	Errors []FieldError
+}
+
+// DisplaySubject returns the decoded subject if available, otherwise the raw subject.
+func (h Header) DisplaySubject() string {
+	if h.DecodedSubject != "" {
+		return h.DecodedSubject
+	}
+
+	return h.Subject
+}
+
+// DisplayAuthor returns the decoded author if available, otherwise the raw author.
+func (h Header) DisplayAuthor() string {
+	if h.DecodedAuthor != "" {
+		return h.DecodedAuthor
+	}
+
+	return h.Author
 }
@@ -8,6 +8,7 @@ import (
 	"net/textproto"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -232,6 +233,48 @@ group3 99 80 m
 	})
 }
 
+func TestClient_ListActive(t *testing.T) {
+	t.Run("successful", func(t *testing.T) {
+		client, conn := getAuthenticatedClient(t)
+		conn.RecordPrintfLine(t, "215 list of newsgroups follows")
+		conn.RecordDotMessage(t, `comp.lang.go 120 3 y
+comp.lang.c 56 56 m
+`)
+
+		groups, err := client.ListActive("comp.lang.*")
+		require.NoError(t, err)
+
+		assert.Equal(t, []nntp.NewsgroupOverview{
+			{Name: "comp.lang.go", Low: 3, High: 120, Status: nntp.NewsgroupStatusPostingPermitted},
+			{Name: "comp.lang.c", Low: 56, High: 56, Status: nntp.NewsgroupStatusPostingModerated},
+		}, groups)
+		assert.True(t, strings.HasSuffix(conn.write.String(), "LIST ACTIVE comp.lang.*\r\n"), conn.write.String())
+	})
+
+	t.Run("all groups", func(t *testing.T) {
+		client, conn := getAuthenticatedClient(t)
+		conn.RecordPrintfLine(t, "215 list of newsgroups follows")
+		conn.RecordDotMessage(t, "misc.test 0 1 n")
+
+		groups, err := client.ListActive("")
+		require.NoError(t, err)
+
+		assert.Equal(t, []nntp.NewsgroupOverview{
+			{Name: "misc.test", Low: 1, High: 0, Status: nntp.NewsgroupStatusPostingProhibited},
+		}, groups)
+		assert.True(t, strings.HasSuffix(conn.write.String(), "LIST ACTIVE\r\n"), conn.write.String())
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		client, conn := getAuthenticatedClient(t)
+		conn.RecordPrintfLine(t, "215 list of newsgroups follows")
+		conn.RecordDotMessage(t, "comp.lang.go 120 3 zzz")
+
+		_, err := client.ListActive("comp.lang.go")
+		assert.True(t, errors.Is(err, nntp.ErrInvalidNewsGroupStatus), "Unexpected error %v", err)
+	})
+}
+
 func TestClient_Group(t *testing.T) {
 	t.Run("successful", func(t *testing.T) {
 		client, conn := getAuthenticatedClient(t)
@@ -386,3 +429,169 @@ func TestClient_XoverChan(t *testing.T) {
 
 	assert.Equal(t, expectedHeaders, gotHeaders)
 }
+
+func TestClient_XoverChan_LongLine(t *testing.T) {
+	// Longer than the read buffer, so the line gets assembled from several reads
+	subject := strings.Repeat("long subject ", 1000)
+
+	client, conn := getAuthenticatedClient(t)
+	client.SetOverviewFormat(nntp.DefaultOverviewFormat())
+	conn.RecordPrintfLine(t, "224 Overview information follows")
+	conn.RecordDotMessage(t, "1\t"+subject+"\tsome author\tSun, 10 May 2020 00:32:22 +0000\t<id>\t\t1\t1\n"+
+		"2\tshort subject\tsome author\tSun, 10 May 2020 00:32:22 +0000\t<id>\t\t1\t1\n")
+
+	headersChan, errChan, err := client.XoverChan("1-2")
+	require.NoError(t, err, "Failed to list compressed headers")
+
+	var subjects []string
+	for header := range headersChan {
+		subjects = append(subjects, header.Subject)
+	}
+
+	assert.Len(t, errChan, 0)
+	assert.Equal(t, []string{subject, "short subject"}, subjects)
+}
+
+func BenchmarkClient_XoverChan(b *testing.B) {
+	var lines strings.Builder
+	for i := 0; i < 1000; i++ {
+		lines.WriteString(benchmarkOverviewLine + "\n")
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+
+		client, conn := getAuthenticatedClient(b)
+		client.SetOverviewFormat(testOverviewFormat())
+		conn.RecordPrintfLine(b, "224 Overview information follows")
+		conn.RecordDotMessage(b, lines.String())
+
+		b.StartTimer()
+
+		headers, errs, err := client.XoverChan("1-1000")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for range headers {
+		}
+
+		if err := <-errs; err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestClient_XoverSummary(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	client.SetOverviewFormat(nntp.DefaultOverviewFormat())
+	client.SetLenientOverview(true)
+	conn.RecordPrintfLine(t, "224 Overview information follows")
+	conn.RecordDotMessage(t, `1	some subject	some author	Sun, 10 May 2020 00:32:22 +0000	<some-msg-id>		67755	519
+2	some subject	some author	Sun, 10 May 2020 00:32:22 +0000	<some-msg-id>		none	519
+invalid line
+`)
+
+	headers, summary, err := client.XoverSummary("1-1000")
+	require.NoError(t, err, "Failed to list compressed headers")
+	require.Len(t, headers, 2)
+	assert.Len(t, headers[1].Errors, 1)
+	assert.Equal(t, nntp.OverviewSummary{
+		Lines:        3,
+		Parsed:       1,
+		Degraded:     1,
+		Skipped:      1,
+		SkippedLines: summary.SkippedLines,
+	}, summary)
+	require.Len(t, summary.SkippedLines, 1)
+	assert.Equal(t, "invalid line", summary.SkippedLines[0].Line)
+}
+
+func TestClient_SetOverviewFormat_KeepsSettings(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	interner := nntp.NewInterner()
+	client.SetHeaderDecoder(nntp.NewHeaderDecoder())
+	client.SetLenientOverview(true)
+	client.SetInterner(interner)
+
+	format := nntp.DefaultOverviewFormat()
+	client.SetOverviewFormat(format)
+
+	conn.RecordPrintfLine(t, "224 Overview information follows")
+	conn.RecordDotMessage(t, "1\t=?UTF-8?Q?gr=C3=BC=C3=9Fe?=\tsome author\tSun, 10 May 2020 00:32:22 +0000\t<id>\t\tnone\t519\n")
+
+	headers, err := client.Xover("1")
+	require.NoError(t, err, "Lenient mode must be kept")
+	require.Len(t, headers, 1)
+	assert.Len(t, headers[0].Errors, 1)
+	assert.Equal(t, "grüße", headers[0].DecodedSubject)
+	assert.Equal(t, 1, interner.Len(), "Expected author to be interned")
+
+	// The format of the caller is left unchanged
+	_, err = format.ParseXoverLine("1\tsubject\tauthor\tSun, 10 May 2020 00:32:22 +0000\t<id>\t\tnone\t519")
+	assert.Error(t, err)
+}
+
+func TestClient_SetOverviewFormat_KeepsFormatSettings(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+
+	format := nntp.DefaultOverviewFormat()
+	format.SetLenient(true)
+	format.SetHeaderDecoder(nntp.NewHeaderDecoder())
+	client.SetOverviewFormat(format)
+
+	conn.RecordPrintfLine(t, "224 Overview information follows")
+	conn.RecordDotMessage(t, "1\t=?UTF-8?Q?gr=C3=BC=C3=9Fe?=\tsome author\tSun, 10 May 2020 00:32:22 +0000\t<id>\t\tnone\t519\n")
+
+	headers, err := client.Xover("1")
+	require.NoError(t, err, "Lenient mode of the format must be kept")
+	require.Len(t, headers, 1)
+	assert.Len(t, headers[0].Errors, 1)
+	assert.Equal(t, "grüße", headers[0].DecodedSubject, "Decoder of the format must be kept")
+
+	// Explicitly set client settings override the ones of the format
+	client.SetLenientOverview(false)
+	client.SetOverviewFormat(format)
+
+	conn.RecordPrintfLine(t, "224 Overview information follows")
+	conn.RecordDotMessage(t, "1\tsubject\tsome author\tSun, 10 May 2020 00:32:22 +0000\t<id>\t\tnone\t519\n")
+
+	_, err = client.Xover("1")
+	assert.Error(t, err)
+}
+
+func TestClient_Hdr(t *testing.T) {
+	client, conn := getAuthenticatedClient(t)
+	conn.RecordPrintfLine(t, "225 Headers follow")
+	conn.RecordDotMessage(t, "3000234 Sun, 10 May 2020 00:32:22 +0000\n3000235 \n")
+
+	values, err := client.Hdr("Date", "3000234-3000235")
+	require.NoError(t, err, "Failed to retrieve headers")
+	assert.Equal(t, []nntp.HeaderValue{
+		{Number: 3000234, Value: "Sun, 10 May 2020 00:32:22 +0000"},
+		{Number: 3000235, Value: ""},
+	}, values)
+
+	// Fallback to XHDR, which is remembered
+	conn.RecordPrintfLine(t, "500 Unknown command")
+	conn.RecordPrintfLine(t, "221 Header follows")
+	conn.RecordDotMessage(t, "1 (none)\n")
+	conn.RecordPrintfLine(t, "221 Header follows")
+	conn.RecordDotMessage(t, "2 subject\n")
+
+	values, err = client.Hdr("Date", "1")
+	require.NoError(t, err, "Failed to retrieve headers using XHDR")
+	assert.Equal(t, []nntp.HeaderValue{{Number: 1, Value: ""}}, values)
+
+	values, err = client.Hdr("Subject", "2")
+	require.NoError(t, err, "Failed to retrieve headers using XHDR")
+	assert.Equal(t, []nntp.HeaderValue{{Number: 2, Value: "subject"}}, values)
+
+	assert.True(
+		t,
+		strings.HasSuffix(conn.write.String(), "HDR Date 3000234-3000235\r\nHDR Date 1\r\nXHDR Date 1\r\nXHDR Subject 2\r\n"),
+		"Unexpected commands: %q", conn.write.String(),
+	)
+}
@@ -0,0 +1,292 @@
+// Package nzb reads & writes NZB 1.1 documents.
+package nzb
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mrincompetent/nntp"
+)
+
+const (
+	Namespace = "http://www.newzbin.com/DTD/2003/nzb"
+	Doctype   = `<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">`
+)
+
+type NZB struct {
+	XMLName xml.Name `xml:"nzb"`
+	Meta    []Meta   `xml:"head>meta"`
+	Files   []File   `xml:"file"`
+}
+
+type Meta struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type File struct {
+	Poster string `xml:"poster,attr"`
+	// Unix timestamp
+	Date     int64     `xml:"date,attr"`
+	Subject  string    `xml:"subject,attr"`
+	Groups   []string  `xml:"groups>group"`
+	Segments []Segment `xml:"segments>segment"`
+}
+
+type Segment struct {
+	Bytes  uint64 `xml:"bytes,attr"`
+	Number int    `xml:"number,attr"`
+	// Message-id without angle brackets
+	MessageID string `xml:",chardata"`
+}
+
+var (
+	ErrNoFiles         = errors.New("nzb does not contain any files")
+	ErrNoGroups        = errors.New("file does not list any groups")
+	ErrNoSegments      = errors.New("file does not contain any segments")
+	ErrInvalidSegment  = errors.New("invalid segment")
+	ErrUnknownCharset  = nntp.ErrUnknownCharset
+	ErrDuplicateNumber = errors.New("duplicate segment number with different message-id")
+)
+
+// ArticleID returns the message-id including angle brackets, as expected by the nntp.Client.
+func (s Segment) ArticleID() string {
+	return "<" + s.MessageID + ">"
+}
+
+// Parse reads an NZB document. Documents declaring a legacy charset, like ISO-8859-1, are converted to UTF-8.
+// See nntp.DecodeCharset for the supported charsets.
+func Parse(r io.Reader) (*NZB, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = nntp.CharsetReader
+	// Lots of NZB files in the wild contain unescaped ampersands in subjects
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	n := &NZB{}
+	if err := decoder.Decode(n); err != nil {
+		return nil, fmt.Errorf("failed to decode nzb: %w", err)
+	}
+
+	for fileIdx := range n.Files {
+		file := &n.Files[fileIdx]
+
+		for groupIdx := range file.Groups {
+			file.Groups[groupIdx] = strings.TrimSpace(file.Groups[groupIdx])
+		}
+
+		for segmentIdx := range file.Segments {
+			segment := &file.Segments[segmentIdx]
+			segment.MessageID = strings.Trim(strings.TrimSpace(segment.MessageID), "<>")
+		}
+	}
+
+	return n, nil
+}
+
+// Write writes the document including XML declaration & doctype.
+func (n *NZB) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header+Doctype+"\n"); err != nil {
+		return err
+	}
+
+	// The tag of NZB.XMLName doesn't contain the namespace, so documents without namespace can be parsed as well.
+	out := struct {
+		XMLName xml.Name `xml:"http://www.newzbin.com/DTD/2003/nzb nzb"`
+		Meta    []Meta   `xml:"head>meta"`
+		Files   []File   `xml:"file"`
+	}{
+		Meta:  n.Meta,
+		Files: n.Files,
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(out); err != nil {
+		return fmt.Errorf("failed to encode nzb: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// Validate checks the document for missing files, groups & segments as well as invalid segments.
+func (n *NZB) Validate() error {
+	if len(n.Files) == 0 {
+		return ErrNoFiles
+	}
+
+	for idx := range n.Files {
+		if err := n.Files[idx].Validate(); err != nil {
+			return fmt.Errorf("file %d ('%s'): %w", idx, n.Files[idx].Subject, err)
+		}
+	}
+
+	return nil
+}
+
+func (f *File) Validate() error {
+	if len(f.Groups) == 0 {
+		return ErrNoGroups
+	}
+
+	if len(f.Segments) == 0 {
+		return ErrNoSegments
+	}
+
+	numbers := map[int]string{}
+
+	for _, segment := range f.Segments {
+		if segment.Number < 1 {
+			return fmt.Errorf("%w: number must be positive. Got %d", ErrInvalidSegment, segment.Number)
+		}
+
+		if segment.MessageID == "" || strings.ContainsAny(segment.MessageID, " \t\r\n<>") {
+			return fmt.Errorf("%w: invalid message-id '%s' for segment %d", ErrInvalidSegment, segment.MessageID, segment.Number)
+		}
+
+		if id, ok := numbers[segment.Number]; ok && id != segment.MessageID {
+			return fmt.Errorf("%w: segment %d", ErrDuplicateNumber, segment.Number)
+		}
+
+		numbers[segment.Number] = segment.MessageID
+	}
+
+	return nil
+}
+
+// Normalize sorts the segments of all files by number & removes duplicate segments.
+// For segments with the same number, the first one is kept.
+func (n *NZB) Normalize() {
+	for idx := range n.Files {
+		n.Files[idx].Normalize()
+	}
+}
+
+func (f *File) Normalize() {
+	sort.SliceStable(f.Segments, func(i, j int) bool {
+		return f.Segments[i].Number < f.Segments[j].Number
+	})
+
+	var (
+		segments = f.Segments[:0]
+		seenIDs  = map[string]struct{}{}
+	)
+
+	for idx, segment := range f.Segments {
+		if idx > 0 && segment.Number == f.Segments[idx-1].Number {
+			continue
+		}
+
+		if _, seen := seenIDs[segment.MessageID]; seen {
+			continue
+		}
+
+		seenIDs[segment.MessageID] = struct{}{}
+		segments = append(segments, segment)
+	}
+
+	f.Segments = segments
+}
+
+// Bytes returns the size of all segments of all files. This is the encoded size, not the size of the decoded files.
+func (n *NZB) Bytes() (total uint64) {
+	for idx := range n.Files {
+		total += n.Files[idx].Bytes()
+	}
+
+	return total
+}
+
+// SegmentCount returns the number of segments of all files.
+func (n *NZB) SegmentCount() (total int) {
+	for idx := range n.Files {
+		total += len(n.Files[idx].Segments)
+	}
+
+	return total
+}
+
+func (f *File) Bytes() (total uint64) {
+	for _, segment := range f.Segments {
+		total += segment.Bytes
+	}
+
+	return total
+}
+
+// FromHeaders builds a document from overview headers. Headers get grouped into files by their subject without the
+// part counter & their author. The part counter of the subject determines the segment number.
+// Headers without part counter become single segment files, even if they share their subject.
+func FromHeaders(headers []nntp.Header, groups ...string) *NZB {
+	type fileKey struct {
+		subject string
+		author  string
+		// Only set for headers without part counter
+		messageID string
+	}
+
+	var (
+		n     = &NZB{}
+		files = map[fileKey]int{}
+		// Number of the segment the file subject has been taken from
+		subjectNumbers = map[int]int{}
+	)
+
+	for _, header := range headers {
+		key := fileKey{subject: nntp.StripSubjectPart(header.Subject), author: header.Author}
+
+		number := 1
+		if part, ok := nntp.ParseSubjectPart(header.Subject); ok {
+			number = part.Number
+		} else {
+			key.messageID = header.MessageID
+		}
+
+		idx, ok := files[key]
+		if !ok {
+			idx = len(n.Files)
+			files[key] = idx
+
+			n.Files = append(n.Files, File{
+				Poster:  header.Author,
+				Date:    header.Date.Unix(),
+				Subject: header.Subject,
+				Groups:  append([]string(nil), groups...),
+			})
+			subjectNumbers[idx] = number
+		}
+
+		file := &n.Files[idx]
+
+		// Like most indexers, use the date of the oldest & the subject of the first segment
+		if date := header.Date.Unix(); date < file.Date {
+			file.Date = date
+		}
+
+		if number < subjectNumbers[idx] {
+			file.Subject = header.Subject
+			subjectNumbers[idx] = number
+		}
+
+		file.Segments = append(file.Segments, Segment{
+			Bytes:     header.Bytes,
+			Number:    number,
+			MessageID: nntp.MessageID(header.MessageID).Bare(),
+		})
+	}
+
+	n.Normalize()
+
+	sort.SliceStable(n.Files, func(i, j int) bool {
+		return n.Files[i].Subject < n.Files[j].Subject
+	})
+
+	return n
+}
@@ -0,0 +1,201 @@
+package nzb_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/nzb"
+)
+
+const testNZB = `<?xml version="1.0" encoding="iso-8859-1" ?>
+<!DOCTYPE nzb PUBLIC "-//newzBin//DTD NZB 1.1//EN" "http://www.newzbin.com/DTD/nzb/nzb-1.1.dtd">
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+ <head>
+   <meta type="title">Your File!</meta>
+   <meta type="tag">Example</meta>
+ </head>
+ <file poster="Joe Bloggs &lt;bloggs@nowhere.example&gt;" date="1071674882" subject="Here's your file!  abc-mr2a.r01 (1/2)">
+   <groups>
+     <group>alt.binaries.newzbin</group>
+     <group>alt.binaries.mojo</group>
+   </groups>
+   <segments>
+     <segment bytes="102394" number="2">123456789abcdef@news.newzbin.com</segment>
+     <segment bytes="4501" number="1">&lt;987654321fedbca@news.newzbin.com&gt;</segment>
+     <segment bytes="4501" number="1">987654321fedbca@news.newzbin.com</segment>
+   </segments>
+ </file>
+</nzb>
+`
+
+func TestParse(t *testing.T) {
+	n, err := nzb.Parse(strings.NewReader(testNZB))
+	require.NoError(t, err, "Failed to parse nzb")
+	require.NoError(t, n.Validate(), "Failed to validate nzb")
+
+	assert.Equal(t, []nzb.Meta{{Type: "title", Value: "Your File!"}, {Type: "tag", Value: "Example"}}, n.Meta)
+	require.Len(t, n.Files, 1)
+
+	file := n.Files[0]
+	assert.Equal(t, "Joe Bloggs <bloggs@nowhere.example>", file.Poster)
+	assert.Equal(t, int64(1071674882), file.Date)
+	assert.Equal(t, []string{"alt.binaries.newzbin", "alt.binaries.mojo"}, file.Groups)
+	assert.Len(t, file.Segments, 3)
+
+	n.Normalize()
+	assert.Equal(t, []nzb.Segment{
+		{Bytes: 4501, Number: 1, MessageID: "987654321fedbca@news.newzbin.com"},
+		{Bytes: 102394, Number: 2, MessageID: "123456789abcdef@news.newzbin.com"},
+	}, n.Files[0].Segments)
+	assert.Equal(t, "<987654321fedbca@news.newzbin.com>", n.Files[0].Segments[0].ArticleID())
+
+	assert.Equal(t, uint64(106895), n.Bytes())
+	assert.Equal(t, 2, n.SegmentCount())
+}
+
+func TestParse_Latin1(t *testing.T) {
+	doc := []byte(`<?xml version="1.0" encoding="iso-8859-1" ?><nzb><file poster="J` + "\xf6" + `rg" date="1" subject="s"></file></nzb>`)
+
+	n, err := nzb.Parse(bytes.NewReader(doc))
+	require.NoError(t, err, "Failed to parse nzb")
+	assert.Equal(t, "Jörg", n.Files[0].Poster)
+}
+
+func TestNZB_Write(t *testing.T) {
+	n, err := nzb.Parse(strings.NewReader(testNZB))
+	require.NoError(t, err, "Failed to parse nzb")
+
+	var b bytes.Buffer
+	require.NoError(t, n.Write(&b), "Failed to write nzb")
+
+	assert.Contains(t, b.String(), nzb.Doctype)
+	assert.Contains(t, b.String(), `<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">`)
+
+	parsed, err := nzb.Parse(&b)
+	require.NoError(t, err, "Failed to parse written nzb")
+	assert.Equal(t, n.Meta, parsed.Meta)
+	assert.Equal(t, n.Files, parsed.Files)
+}
+
+func TestNZB_Validate(t *testing.T) {
+	valid := func() *nzb.NZB {
+		return &nzb.NZB{Files: []nzb.File{{
+			Subject:  "s",
+			Groups:   []string{"g"},
+			Segments: []nzb.Segment{{Number: 1, MessageID: "a@b"}},
+		}}}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(n *nzb.NZB)
+		expectedErr error
+	}{
+		{name: "valid", modify: func(n *nzb.NZB) {}},
+		{name: "no files", modify: func(n *nzb.NZB) { n.Files = nil }, expectedErr: nzb.ErrNoFiles},
+		{name: "no groups", modify: func(n *nzb.NZB) { n.Files[0].Groups = nil }, expectedErr: nzb.ErrNoGroups},
+		{name: "no segments", modify: func(n *nzb.NZB) { n.Files[0].Segments = nil }, expectedErr: nzb.ErrNoSegments},
+		{name: "invalid number", modify: func(n *nzb.NZB) { n.Files[0].Segments[0].Number = 0 }, expectedErr: nzb.ErrInvalidSegment},
+		{name: "invalid message-id", modify: func(n *nzb.NZB) { n.Files[0].Segments[0].MessageID = "a b" }, expectedErr: nzb.ErrInvalidSegment},
+		{
+			name: "duplicate number",
+			modify: func(n *nzb.NZB) {
+				n.Files[0].Segments = append(n.Files[0].Segments, nzb.Segment{Number: 1, MessageID: "c@d"})
+			},
+			expectedErr: nzb.ErrDuplicateNumber,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			n := valid()
+			test.modify(n)
+
+			err := n.Validate()
+			if test.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.True(t, errors.Is(err, test.expectedErr), "Expected %v, got %v", test.expectedErr, err)
+		})
+	}
+}
+
+func TestFromHeaders(t *testing.T) {
+	date := time.Date(2020, 5, 10, 0, 32, 22, 0, time.UTC)
+
+	headers := []nntp.Header{
+		{Subject: `"b.bin" yEnc (2/2)`, Author: "poster", Date: date, MessageID: "<b2@x>", Bytes: 20},
+		{Subject: `"a.bin" yEnc (1/1)`, Author: "poster", Date: date, MessageID: "<a1@x>", Bytes: 10},
+		{Subject: `"b.bin" yEnc (1/2)`, Author: "poster", Date: date.Add(-time.Minute), MessageID: "<b1@x>", Bytes: 30},
+		{Subject: `"b.bin" yEnc (1/2)`, Author: "other poster", Date: date, MessageID: "<c1@x>", Bytes: 40},
+	}
+
+	n := nzb.FromHeaders(headers, "alt.binaries.test")
+	require.NoError(t, n.Validate(), "Failed to validate nzb")
+
+	assert.Equal(t, []nzb.File{
+		{
+			Poster:   "poster",
+			Date:     date.Unix(),
+			Subject:  `"a.bin" yEnc (1/1)`,
+			Groups:   []string{"alt.binaries.test"},
+			Segments: []nzb.Segment{{Bytes: 10, Number: 1, MessageID: "a1@x"}},
+		},
+		{
+			Poster:  "poster",
+			Date:    date.Add(-time.Minute).Unix(),
+			Subject: `"b.bin" yEnc (1/2)`,
+			Groups:  []string{"alt.binaries.test"},
+			Segments: []nzb.Segment{
+				{Bytes: 30, Number: 1, MessageID: "b1@x"},
+				{Bytes: 20, Number: 2, MessageID: "b2@x"},
+			},
+		},
+		{
+			Poster:   "other poster",
+			Date:     date.Unix(),
+			Subject:  `"b.bin" yEnc (1/2)`,
+			Groups:   []string{"alt.binaries.test"},
+			Segments: []nzb.Segment{{Bytes: 40, Number: 1, MessageID: "c1@x"}},
+		},
+	}, n.Files)
+}
+
+func TestFromHeaders_WithoutPart(t *testing.T) {
+	date := time.Date(2020, 5, 10, 0, 32, 22, 0, time.UTC)
+
+	headers := []nntp.Header{
+		{Subject: "Re: question", Author: "poster", Date: date, MessageID: "<q1@x>", Bytes: 10},
+		{Subject: "Re: question", Author: "poster", Date: date.Add(time.Minute), MessageID: "<q2@x>", Bytes: 20},
+	}
+
+	n := nzb.FromHeaders(headers, "alt.test")
+	require.NoError(t, n.Validate(), "Failed to validate nzb")
+
+	assert.Equal(t, []nzb.File{
+		{
+			Poster:   "poster",
+			Date:     date.Unix(),
+			Subject:  "Re: question",
+			Groups:   []string{"alt.test"},
+			Segments: []nzb.Segment{{Bytes: 10, Number: 1, MessageID: "q1@x"}},
+		},
+		{
+			Poster:   "poster",
+			Date:     date.Add(time.Minute).Unix(),
+			Subject:  "Re: question",
+			Groups:   []string{"alt.test"},
+			Segments: []nzb.Segment{{Bytes: 20, Number: 1, MessageID: "q2@x"}},
+		},
+	}, n.Files)
+}
@@ -0,0 +1,191 @@
+package par2
+
+import "encoding/binary"
+
+// PAR2 uses Reed-Solomon codes over GF(2^16) with the generator polynomial x^16 + x^12 + x^3 + x + 1.
+const (
+	gfPolynomial = 0x1100b
+	gfOrder      = 65535
+)
+
+var (
+	gfLog [gfOrder + 1]int
+	// Doubled, so the sum of two logarithms can be looked up without modulo
+	gfExp [2 * gfOrder]uint16
+)
+
+func init() {
+	x := 1
+
+	for i := 0; i < gfOrder; i++ {
+		gfExp[i] = uint16(x)
+		gfExp[i+gfOrder] = uint16(x)
+		gfLog[x] = i
+
+		x <<= 1
+		if x&0x10000 != 0 {
+			x ^= gfPolynomial
+		}
+	}
+}
+
+func gfMul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b uint16) uint16 {
+	if a == 0 {
+		return 0
+	}
+
+	return gfExp[gfLog[a]+gfOrder-gfLog[b]]
+}
+
+func gfPow(a uint16, exponent uint32) uint16 {
+	if exponent == 0 {
+		return 1
+	}
+
+	if a == 0 {
+		return 0
+	}
+
+	return gfExp[int((uint64(gfLog[a])*uint64(exponent))%gfOrder)]
+}
+
+// inputConstants returns the constants of the first n input slices: The powers of 2 whose exponents are coprime to
+// 65535, in ascending order.
+func inputConstants(n int) []uint16 {
+	constants := make([]uint16, 0, n)
+
+	for exponent := 1; len(constants) < n; exponent++ {
+		if exponent%3 == 0 || exponent%5 == 0 || exponent%17 == 0 || exponent%257 == 0 {
+			continue
+		}
+
+		constants = append(constants, gfExp[exponent])
+	}
+
+	return constants
+}
+
+// mulAdd adds factor * src to dst, both interpreted as little-endian 16 bit words.
+func mulAdd(dst, src []byte, factor uint16) {
+	if factor == 0 {
+		return
+	}
+
+	logFactor := gfLog[factor]
+
+	for i := 0; i+1 < len(src) && i+1 < len(dst); i += 2 {
+		word := binary.LittleEndian.Uint16(src[i:])
+		if word == 0 {
+			continue
+		}
+
+		product := gfExp[gfLog[word]+logFactor]
+		binary.LittleEndian.PutUint16(dst[i:], binary.LittleEndian.Uint16(dst[i:])^product)
+	}
+}
+
+// invert returns the inverse of the square matrix using Gauss-Jordan elimination.
+func invert(matrix [][]uint16) ([][]uint16, bool) {
+	n := len(matrix)
+
+	work := make([][]uint16, n)
+	inverse := make([][]uint16, n)
+
+	for i := range matrix {
+		work[i] = append([]uint16(nil), matrix[i]...)
+		inverse[i] = make([]uint16, n)
+		inverse[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+
+		for row := col; row < n; row++ {
+			if work[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+
+		if pivot < 0 {
+			return nil, false
+		}
+
+		work[col], work[pivot] = work[pivot], work[col]
+		inverse[col], inverse[pivot] = inverse[pivot], inverse[col]
+
+		scale := work[col][col]
+		for k := 0; k < n; k++ {
+			work[col][k] = gfDiv(work[col][k], scale)
+			inverse[col][k] = gfDiv(inverse[col][k], scale)
+		}
+
+		for row := 0; row < n; row++ {
+			factor := work[row][col]
+			if row == col || factor == 0 {
+				continue
+			}
+
+			for k := 0; k < n; k++ {
+				work[row][k] ^= gfMul(factor, work[col][k])
+				inverse[row][k] ^= gfMul(factor, inverse[col][k])
+			}
+		}
+	}
+
+	return inverse, true
+}
+
+// independent reports whether the rows of the matrix are linearly independent.
+func independent(matrix [][]uint16) bool {
+	if len(matrix) == 0 {
+		return true
+	}
+
+	work := make([][]uint16, len(matrix))
+	for i := range matrix {
+		work[i] = append([]uint16(nil), matrix[i]...)
+	}
+
+	row := 0
+
+	for col := 0; col < len(work[0]) && row < len(work); col++ {
+		pivot := -1
+
+		for candidate := row; candidate < len(work); candidate++ {
+			if work[candidate][col] != 0 {
+				pivot = candidate
+				break
+			}
+		}
+
+		if pivot < 0 {
+			continue
+		}
+
+		work[row], work[pivot] = work[pivot], work[row]
+
+		for below := row + 1; below < len(work); below++ {
+			factor := gfDiv(work[below][col], work[row][col])
+			if factor == 0 {
+				continue
+			}
+
+			for k := col; k < len(work[below]); k++ {
+				work[below][k] ^= gfMul(factor, work[row][k])
+			}
+		}
+
+		row++
+	}
+
+	return row == len(work)
+}
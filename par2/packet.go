@@ -0,0 +1,212 @@
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const headerSize = 64
+
+// Metadata packets larger than this are considered damaged
+const maxMetadataPacketSize = 64 << 20
+
+var (
+	packetMagic = []byte("PAR2\x00PKT")
+
+	typeMain          = packetType("PAR 2.0\x00Main\x00\x00\x00\x00")
+	typeFileDesc      = packetType("PAR 2.0\x00FileDesc")
+	typeIFSC          = packetType("PAR 2.0\x00IFSC\x00\x00\x00\x00")
+	typeRecoverySlice = packetType("PAR 2.0\x00RecvSlic")
+)
+
+type ID [16]byte
+
+func (id ID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+func packetType(s string) (t [16]byte) {
+	copy(t[:], s)
+	return t
+}
+
+type packetHeader struct {
+	length int64
+	hash   [16]byte
+	setID  ID
+	typ    [16]byte
+}
+
+// packetRef points to a packet within a file. The body of recovery slice packets is only read when needed.
+type packetRef struct {
+	header packetHeader
+	path   string
+	// Offset of the packet body
+	offset int64
+	body   []byte
+}
+
+// scanPackets returns all valid packets within the file. Damaged packets are skipped by searching for the next magic
+// sequence.
+func scanPackets(path string) ([]packetRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		packets []packetRef
+		size    = info.Size()
+		offset  int64
+		header  = make([]byte, headerSize)
+	)
+
+	for offset+headerSize <= size {
+		if _, err := f.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+
+		ref, ok := parsePacket(f, path, header, offset, size)
+		if !ok {
+			next, err := findMagic(f, offset+4, size)
+			if err != nil {
+				return nil, err
+			}
+
+			offset = next
+
+			continue
+		}
+
+		packets = append(packets, ref)
+		offset += ref.header.length
+	}
+
+	return packets, nil
+}
+
+func parsePacket(f *os.File, path string, header []byte, offset, size int64) (packetRef, bool) {
+	if !bytes.Equal(header[:8], packetMagic) {
+		return packetRef{}, false
+	}
+
+	ref := packetRef{path: path, offset: offset + headerSize}
+	ref.header.length = int64(binary.LittleEndian.Uint64(header[8:16]))
+	copy(ref.header.hash[:], header[16:32])
+	copy(ref.header.setID[:], header[32:48])
+	copy(ref.header.typ[:], header[48:64])
+
+	if ref.header.length < headerSize || ref.header.length%4 != 0 || offset+ref.header.length > size {
+		return packetRef{}, false
+	}
+
+	// The packet hash covers everything from the recovery set ID to the end of the packet
+	h := md5.New()
+	h.Write(header[32:])
+
+	bodyLength := ref.header.length - headerSize
+	body := io.NewSectionReader(f, ref.offset, bodyLength)
+
+	if ref.header.typ != typeRecoverySlice && bodyLength <= maxMetadataPacketSize {
+		ref.body = make([]byte, bodyLength)
+		if _, err := io.ReadFull(body, ref.body); err != nil {
+			return packetRef{}, false
+		}
+
+		h.Write(ref.body)
+	} else if _, err := io.Copy(h, body); err != nil {
+		return packetRef{}, false
+	}
+
+	if !bytes.Equal(h.Sum(nil), ref.header.hash[:]) {
+		return packetRef{}, false
+	}
+
+	return ref, true
+}
+
+func findMagic(f *os.File, offset, size int64) (int64, error) {
+	const chunkSize = 64 << 10
+
+	buf := make([]byte, chunkSize+len(packetMagic))
+
+	for ; offset < size; offset += chunkSize {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		if idx := bytes.Index(buf[:n], packetMagic); idx >= 0 {
+			return offset + int64(idx), nil
+		}
+	}
+
+	return size, nil
+}
+
+type mainPacket struct {
+	sliceSize uint64
+	fileIDs   []ID
+}
+
+func parseMain(body []byte) (main mainPacket, err error) {
+	if len(body) < 12 {
+		return main, fmt.Errorf("%w: main packet too short", ErrInvalidPacket)
+	}
+
+	main.sliceSize = binary.LittleEndian.Uint64(body[0:8])
+	count := int(binary.LittleEndian.Uint32(body[8:12]))
+
+	if main.sliceSize == 0 || main.sliceSize%4 != 0 || len(body) < 12+count*16 {
+		return main, fmt.Errorf("%w: invalid main packet", ErrInvalidPacket)
+	}
+
+	main.fileIDs = make([]ID, count)
+	for idx := range main.fileIDs {
+		copy(main.fileIDs[idx][:], body[12+idx*16:])
+	}
+
+	return main, nil
+}
+
+func parseFileDesc(body []byte) (desc File, err error) {
+	if len(body) < 56 {
+		return desc, fmt.Errorf("%w: file description packet too short", ErrInvalidPacket)
+	}
+
+	copy(desc.ID[:], body[0:16])
+	copy(desc.MD5[:], body[16:32])
+	copy(desc.MD516k[:], body[32:48])
+	desc.Length = binary.LittleEndian.Uint64(body[48:56])
+	desc.Name = string(bytes.TrimRight(body[56:], "\x00"))
+
+	return desc, nil
+}
+
+func parseIFSC(body []byte) (id ID, checksums []sliceChecksum, err error) {
+	if len(body) < 16 || (len(body)-16)%20 != 0 {
+		return id, nil, fmt.Errorf("%w: invalid input file slice checksum packet", ErrInvalidPacket)
+	}
+
+	copy(id[:], body[0:16])
+
+	checksums = make([]sliceChecksum, (len(body)-16)/20)
+	for idx := range checksums {
+		entry := body[16+idx*20:]
+		copy(checksums[idx].md5[:], entry[0:16])
+		checksums[idx].crc32 = binary.LittleEndian.Uint32(entry[16:20])
+	}
+
+	return id, checksums, nil
+}
@@ -0,0 +1,620 @@
+// Package par2 verifies & repairs files using PAR2 recovery sets.
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type File struct {
+	ID     ID
+	Name   string
+	Length uint64
+	MD5    [16]byte
+	// MD5 of the first 16 KiB of the file
+	MD516k [16]byte
+
+	checksums []sliceChecksum
+	// Index of the first slice of this file within the recovery set
+	firstSlice int
+}
+
+// Slices returns the number of input slices of the file.
+func (f *File) Slices(sliceSize uint64) int {
+	return int((f.Length + sliceSize - 1) / sliceSize)
+}
+
+type sliceChecksum struct {
+	md5   [16]byte
+	crc32 uint32
+}
+
+type recoverySlice struct {
+	exponent uint32
+	path     string
+	// Offset of the recovery data within path
+	offset int64
+}
+
+// Set is a recovery set assembled from the packets of one or more PAR2 files.
+type Set struct {
+	ID        ID
+	SliceSize uint64
+	// Files of the recovery set, in the order their slices are numbered
+	Files []File
+
+	recovery []recoverySlice
+}
+
+var (
+	ErrInvalidPacket     = errors.New("invalid packet")
+	ErrNoMainPacket      = errors.New("no valid main packet found")
+	ErrMissingFileDesc   = errors.New("missing file description packet")
+	ErrNotEnoughRecovery = errors.New("not enough recovery slices")
+	ErrUnsolvable        = errors.New("recovery slices are linearly dependent")
+	ErrUnsafeFileName    = errors.New("file name leaves the directory of the recovery set")
+)
+
+const (
+	// Slices larger than this are considered damaged. par2cmdline uses slices of a few MiB at most.
+	maxSliceSize = 256 << 20
+	// The number of input slices is limited by the number of input constants within GF(2^16)
+	maxInputSlices = 32768
+)
+
+// Open reads the packets of all given PAR2 files, usually the index file & all volume files. Damaged packets are
+// skipped, as long as every packet is available in at least one of the files.
+func Open(paths ...string) (*Set, error) {
+	var packets []packetRef
+
+	for _, path := range paths {
+		filePackets, err := scanPackets(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", path, err)
+		}
+
+		packets = append(packets, filePackets...)
+	}
+
+	set := &Set{}
+
+	var main *mainPacket
+
+	for _, packet := range packets {
+		if packet.header.typ != typeMain {
+			continue
+		}
+
+		parsed, err := parseMain(packet.body)
+		if err != nil {
+			continue
+		}
+
+		main = &parsed
+		set.ID = packet.header.setID
+
+		break
+	}
+
+	if main == nil {
+		return nil, ErrNoMainPacket
+	}
+
+	set.SliceSize = main.sliceSize
+	if set.SliceSize > maxSliceSize {
+		return nil, fmt.Errorf("%w: slice size %d exceeds %d", ErrInvalidPacket, set.SliceSize, maxSliceSize)
+	}
+
+	descs := map[ID]File{}
+	checksums := map[ID][]sliceChecksum{}
+	exponents := map[uint32]bool{}
+
+	for _, packet := range packets {
+		if packet.header.setID != set.ID {
+			continue
+		}
+
+		switch packet.header.typ {
+		case typeFileDesc:
+			desc, err := parseFileDesc(packet.body)
+			if err == nil {
+				descs[desc.ID] = desc
+			}
+		case typeIFSC:
+			id, sums, err := parseIFSC(packet.body)
+			if err == nil {
+				checksums[id] = sums
+			}
+		case typeRecoverySlice:
+			if packet.header.length-headerSize != int64(set.SliceSize)+4 {
+				continue
+			}
+
+			exponent, err := readExponent(packet)
+			if err != nil || exponents[exponent] {
+				continue
+			}
+
+			exponents[exponent] = true
+			set.recovery = append(set.recovery, recoverySlice{exponent: exponent, path: packet.path, offset: packet.offset + 4})
+		}
+	}
+
+	sort.Slice(set.recovery, func(i, j int) bool {
+		return set.recovery[i].exponent < set.recovery[j].exponent
+	})
+
+	var (
+		slice       int
+		totalLength uint64
+	)
+
+	for _, id := range main.fileIDs {
+		desc, ok := descs[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: file %s", ErrMissingFileDesc, id)
+		}
+
+		if !safeFileName(desc.Name) {
+			return nil, fmt.Errorf("%w: '%s'", ErrUnsafeFileName, desc.Name)
+		}
+
+		if desc.Length > maxInputSlices*set.SliceSize {
+			return nil, fmt.Errorf("%w: file '%s' exceeds %d slices", ErrInvalidPacket, desc.Name, maxInputSlices)
+		}
+
+		desc.checksums = checksums[id]
+		desc.firstSlice = slice
+		slice += desc.Slices(set.SliceSize)
+		totalLength += desc.Length
+
+		if slice > maxInputSlices {
+			return nil, fmt.Errorf("%w: more than %d input slices", ErrInvalidPacket, maxInputSlices)
+		}
+
+		set.Files = append(set.Files, desc)
+	}
+
+	// Slice buffers get allocated with the slice size, so it must not exceed the data it protects
+	if len(set.Files) > 0 && set.SliceSize > (totalLength+3)/4*4 {
+		return nil, fmt.Errorf("%w: slice size %d for %d bytes of files", ErrInvalidPacket, set.SliceSize, totalLength)
+	}
+
+	return set, nil
+}
+
+// safeFileName reports whether the file name is relative & stays within the directory of the recovery set.
+// Backslashes are treated as separators, as PAR2 files created on Windows may contain them.
+func safeFileName(name string) bool {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+
+	if name == "" || path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return false
+	}
+
+	for _, component := range strings.Split(slashed, "/") {
+		if component == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+func readExponent(packet packetRef) (uint32, error) {
+	f, err := os.Open(packet.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	b := make([]byte, 4)
+	if _, err := f.ReadAt(b, packet.offset); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// RecoverySlices returns the number of usable recovery slices.
+func (s *Set) RecoverySlices() int {
+	return len(s.recovery)
+}
+
+// InputSlices returns the number of input slices of all files.
+func (s *Set) InputSlices() (total int) {
+	for idx := range s.Files {
+		total += s.Files[idx].Slices(s.SliceSize)
+	}
+
+	return total
+}
+
+type FileStatus string
+
+const (
+	FileOK      FileStatus = "ok"
+	FileDamaged FileStatus = "damaged"
+	FileMissing FileStatus = "missing"
+)
+
+type FileReport struct {
+	Name   string
+	Path   string
+	Status FileStatus
+	// Indexes of the damaged slices within the file
+	DamagedSlices []int
+}
+
+type Report struct {
+	Files []FileReport
+	// Number of input slices which need to be repaired
+	DamagedSlices int
+	// Number of usable recovery slices
+	RecoverySlices int
+	// Number of recovery slices missing to be able to repair. 0 if the files are intact or can be repaired.
+	AdditionalNeeded int
+}
+
+// Intact reports whether all files are complete & undamaged.
+func (r *Report) Intact() bool {
+	for idx := range r.Files {
+		if r.Files[idx].Status != FileOK {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Repairable reports whether there are enough recovery slices to repair all damaged slices.
+func (r *Report) Repairable() bool {
+	return r.DamagedSlices <= r.RecoverySlices
+}
+
+// Verify checks all files within dir against the MD5 hashes of the recovery set. Damaged files are checked slice by
+// slice to find out which slices need to be repaired.
+func (s *Set) Verify(dir string) (*Report, error) {
+	report := &Report{
+		RecoverySlices: len(s.recovery),
+	}
+
+	for idx := range s.Files {
+		file, err := s.verifyFile(dir, &s.Files[idx])
+		if err != nil {
+			return nil, err
+		}
+
+		report.Files = append(report.Files, file)
+		report.DamagedSlices += len(file.DamagedSlices)
+	}
+
+	if report.DamagedSlices > report.RecoverySlices {
+		report.AdditionalNeeded = report.DamagedSlices - report.RecoverySlices
+	}
+
+	return report, nil
+}
+
+func (s *Set) verifyFile(dir string, file *File) (FileReport, error) {
+	if !safeFileName(file.Name) {
+		return FileReport{}, fmt.Errorf("%w: '%s'", ErrUnsafeFileName, file.Name)
+	}
+
+	report := FileReport{
+		Name: file.Name,
+		Path: filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(file.Name, "\\", "/"))),
+	}
+
+	slices := file.Slices(s.SliceSize)
+
+	f, err := os.Open(report.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		report.Status = FileMissing
+		report.DamagedSlices = sequence(slices)
+
+		return report, nil
+	}
+
+	if err != nil {
+		return report, err
+	}
+	defer f.Close()
+
+	var (
+		fileHash = md5.New()
+		buf      = make([]byte, s.SliceSize)
+		damaged  []int
+	)
+
+	for idx := 0; idx < slices; idx++ {
+		n, err := io.ReadFull(f, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return report, err
+		}
+
+		fileHash.Write(buf[:min64(uint64(n), file.Length-uint64(idx)*s.SliceSize)])
+
+		// The last slice is padded with zeros
+		for i := n; i < len(buf); i++ {
+			buf[i] = 0
+		}
+
+		if idx >= len(file.checksums) || !file.checksums[idx].matches(buf) {
+			damaged = append(damaged, idx)
+		}
+	}
+
+	// Data beyond the expected length
+	extra, err := io.Copy(io.Discard, f)
+	if err != nil {
+		return report, err
+	}
+
+	if extra == 0 && bytes.Equal(fileHash.Sum(nil), file.MD5[:]) {
+		report.Status = FileOK
+		return report, nil
+	}
+
+	report.Status = FileDamaged
+
+	// Without slice checksums, all slices have to be considered damaged
+	if len(file.checksums) == 0 {
+		damaged = sequence(slices)
+	}
+
+	report.DamagedSlices = damaged
+
+	return report, nil
+}
+
+func (c sliceChecksum) matches(slice []byte) bool {
+	if crc32.ChecksumIEEE(slice) != c.crc32 {
+		return false
+	}
+
+	return md5.Sum(slice) == c.md5
+}
+
+// Repair verifies the files within dir & reconstructs all damaged slices. Files get truncated to their expected
+// length & missing files are created. The returned report reflects the state after the repair.
+// If there are not enough recovery slices, nothing gets changed & the returned report contains the number of
+// additionally needed recovery slices.
+func (s *Set) Repair(dir string) (*Report, error) {
+	report, err := s.Verify(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Intact() {
+		return report, nil
+	}
+
+	if !report.Repairable() {
+		return report, fmt.Errorf("%w: %d damaged slices, %d recovery slices. %d additional recovery slices needed", ErrNotEnoughRecovery, report.DamagedSlices, report.RecoverySlices, report.AdditionalNeeded)
+	}
+
+	// Global indexes of the damaged slices
+	var damaged []int
+
+	for idx := range report.Files {
+		for _, slice := range report.Files[idx].DamagedSlices {
+			damaged = append(damaged, s.Files[idx].firstSlice+slice)
+		}
+	}
+
+	reconstructed, err := s.reconstruct(dir, report, damaged)
+	if err != nil {
+		return report, err
+	}
+
+	if err := s.write(report, reconstructed); err != nil {
+		return report, err
+	}
+
+	return s.Verify(dir)
+}
+
+// reconstruct calculates the data of the damaged slices, keyed by their global index.
+func (s *Set) reconstruct(dir string, report *Report, damaged []int) (map[int][]byte, error) {
+	constants := inputConstants(s.InputSlices())
+
+	recovery, matrix, ok := s.selectRecovery(constants, damaged)
+	if !ok {
+		return nil, ErrUnsolvable
+	}
+
+	// The recovery data minus the contribution of all intact slices is the contribution of the damaged slices.
+	remainders := make([][]byte, len(recovery))
+
+	for idx := range recovery {
+		data, err := s.readRecovery(recovery[idx])
+		if err != nil {
+			return nil, err
+		}
+
+		remainders[idx] = data
+	}
+
+	isDamaged := map[int]bool{}
+	for _, slice := range damaged {
+		isDamaged[slice] = true
+	}
+
+	buf := make([]byte, s.SliceSize)
+
+	for fileIdx := range s.Files {
+		file := &s.Files[fileIdx]
+
+		if report.Files[fileIdx].Status == FileMissing {
+			continue
+		}
+
+		f, err := os.Open(report.Files[fileIdx].Path)
+		if err != nil {
+			return nil, err
+		}
+
+		for slice := 0; slice < file.Slices(s.SliceSize); slice++ {
+			global := file.firstSlice + slice
+			if isDamaged[global] {
+				continue
+			}
+
+			if err := readSlice(f, buf, int64(slice)*int64(s.SliceSize)); err != nil {
+				f.Close()
+				return nil, err
+			}
+
+			for idx := range recovery {
+				mulAdd(remainders[idx], buf, gfPow(constants[global], recovery[idx].exponent))
+			}
+		}
+
+		f.Close()
+	}
+
+	inverse, ok := invert(matrix)
+	if !ok {
+		return nil, ErrUnsolvable
+	}
+
+	reconstructed := make(map[int][]byte, len(damaged))
+
+	for col, slice := range damaged {
+		data := make([]byte, s.SliceSize)
+		for row := range recovery {
+			mulAdd(data, remainders[row], inverse[col][row])
+		}
+
+		reconstructed[slice] = data
+	}
+
+	return reconstructed, nil
+}
+
+// selectRecovery picks one recovery slice per damaged slice, so that their matrix can be inverted. Usually these are
+// the first ones, but some combinations of exponents are linearly dependent for the damaged slices.
+func (s *Set) selectRecovery(constants []uint16, damaged []int) ([]recoverySlice, [][]uint16, bool) {
+	var (
+		selected []recoverySlice
+		matrix   [][]uint16
+	)
+
+	for _, slice := range s.recovery {
+		if len(selected) == len(damaged) {
+			break
+		}
+
+		row := make([]uint16, len(damaged))
+		for col, input := range damaged {
+			row[col] = gfPow(constants[input], slice.exponent)
+		}
+
+		if !independent(append(matrix, row)) {
+			continue
+		}
+
+		selected = append(selected, slice)
+		matrix = append(matrix, row)
+	}
+
+	return selected, matrix, len(selected) == len(damaged)
+}
+
+func (s *Set) readRecovery(slice recoverySlice) ([]byte, error) {
+	f, err := os.Open(slice.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, s.SliceSize)
+	if _, err := f.ReadAt(data, slice.offset); err != nil {
+		return nil, fmt.Errorf("failed to read recovery slice %d: %w", slice.exponent, err)
+	}
+
+	return data, nil
+}
+
+// readSlice reads a slice at offset, padding missing data with zeros.
+func readSlice(f *os.File, buf []byte, offset int64) error {
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+
+	return nil
+}
+
+func (s *Set) write(report *Report, reconstructed map[int][]byte) error {
+	for fileIdx := range report.Files {
+		fileReport := report.Files[fileIdx]
+		if fileReport.Status == FileOK {
+			continue
+		}
+
+		file := &s.Files[fileIdx]
+
+		if err := os.MkdirAll(filepath.Dir(fileReport.Path), 0o755); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(fileReport.Path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Truncate(int64(file.Length)); err != nil {
+			f.Close()
+			return err
+		}
+
+		for _, slice := range fileReport.DamagedSlices {
+			offset := uint64(slice) * s.SliceSize
+			data := reconstructed[file.firstSlice+slice][:min64(s.SliceSize, file.Length-offset)]
+
+			if _, err := f.WriteAt(data, int64(offset)); err != nil {
+				f.Close()
+				return err
+			}
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sequence(n int) []int {
+	s := make([]int, n)
+	for idx := range s {
+		s[idx] = idx
+	}
+
+	return s
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
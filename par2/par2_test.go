@@ -0,0 +1,473 @@
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSliceSize = 64
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+func testFiles() []testFile {
+	r := rand.New(rand.NewSource(1))
+
+	first := make([]byte, 3*testSliceSize)
+	second := make([]byte, 2*testSliceSize+10)
+	r.Read(first)
+	r.Read(second)
+
+	return []testFile{{name: "first.bin", data: first}, {name: "second.bin", data: second}}
+}
+
+func packet(setID ID, typ [16]byte, body []byte) []byte {
+	header := make([]byte, headerSize)
+	copy(header, packetMagic)
+	binary.LittleEndian.PutUint64(header[8:], uint64(headerSize+len(body)))
+	copy(header[32:], setID[:])
+	copy(header[48:], typ[:])
+
+	hash := md5.New()
+	hash.Write(header[32:])
+	hash.Write(body)
+	copy(header[16:32], hash.Sum(nil))
+
+	return append(header, body...)
+}
+
+// createPAR2 writes a PAR2 index file & a volume file with the given exponents.
+func createPAR2(t *testing.T, dir string, files []testFile, exponents []uint32) []string {
+	var (
+		setID    = ID{1, 2, 3}
+		index    []byte
+		main     = make([]byte, 12)
+		slices   [][]byte
+		metadata [][]byte
+	)
+
+	binary.LittleEndian.PutUint64(main, testSliceSize)
+	binary.LittleEndian.PutUint32(main[8:], uint32(len(files)))
+
+	for idx, file := range files {
+		id := ID{byte(idx + 1)}
+		main = append(main, id[:]...)
+
+		desc := make([]byte, 56)
+		copy(desc, id[:])
+		sum := md5.Sum(file.data)
+		copy(desc[16:], sum[:])
+		binary.LittleEndian.PutUint64(desc[48:], uint64(len(file.data)))
+		desc = append(desc, []byte(file.name)...)
+		for len(desc)%4 != 0 {
+			desc = append(desc, 0)
+		}
+
+		metadata = append(metadata, packet(setID, typeFileDesc, desc))
+
+		ifsc := append([]byte(nil), id[:]...)
+
+		for offset := 0; offset < len(file.data); offset += testSliceSize {
+			slice := make([]byte, testSliceSize)
+			copy(slice, file.data[offset:])
+			slices = append(slices, slice)
+
+			sliceSum := md5.Sum(slice)
+			ifsc = append(ifsc, sliceSum[:]...)
+			ifsc = appendUint32(ifsc, crc32.ChecksumIEEE(slice))
+		}
+
+		metadata = append(metadata, packet(setID, typeIFSC, ifsc))
+	}
+
+	index = append(index, packet(setID, typeMain, main)...)
+	for _, m := range metadata {
+		index = append(index, m...)
+	}
+
+	var volume []byte
+
+	for _, exponent := range exponents {
+		recovery := referenceRecovery(slices, exponent)
+
+		body := appendUint32(nil, exponent)
+		volume = append(volume, packet(setID, typeRecoverySlice, append(body, recovery...))...)
+	}
+
+	// Volume files repeat the main packet
+	volume = append(volume, packet(setID, typeMain, main)...)
+
+	indexPath := filepath.Join(dir, "test.par2")
+	volumePath := filepath.Join(dir, "test.vol00+02.par2")
+	require.NoError(t, os.WriteFile(indexPath, index, 0o644))
+	require.NoError(t, os.WriteFile(volumePath, volume, 0o644))
+
+	return []string{indexPath, volumePath}
+}
+
+// referenceMul multiplies within GF(2^16) bit by bit, independent of the log tables of the package.
+func referenceMul(a, b uint16) uint16 {
+	var product uint32
+
+	for bit := 0; bit < 16; bit++ {
+		if b&(1<<bit) != 0 {
+			product ^= uint32(a) << bit
+		}
+	}
+
+	for bit := 31; bit >= 16; bit-- {
+		if product&(1<<bit) != 0 {
+			product ^= 0x1100b << (bit - 16)
+		}
+	}
+
+	return uint16(product)
+}
+
+func referencePow(a uint16, exponent uint32) uint16 {
+	result := uint16(1)
+	for i := uint32(0); i < exponent; i++ {
+		result = referenceMul(result, a)
+	}
+
+	return result
+}
+
+// referenceRecovery calculates a recovery slice as specified by PAR2: The sum of all input slices, each multiplied by
+// its input constant raised to the exponent. Input constants are 2^n for the n not divisible by 3, 5, 17 & 257.
+func referenceRecovery(slices [][]byte, exponent uint32) []byte {
+	recovery := make([]byte, testSliceSize)
+	n := uint32(0)
+
+	for _, slice := range slices {
+		n++
+		for n%3 == 0 || n%5 == 0 || n%17 == 0 || n%257 == 0 {
+			n++
+		}
+
+		factor := referencePow(referencePow(2, n), exponent)
+
+		for offset := 0; offset < len(recovery); offset += 2 {
+			word := referenceMul(binary.LittleEndian.Uint16(slice[offset:]), factor)
+			binary.LittleEndian.PutUint16(recovery[offset:], binary.LittleEndian.Uint16(recovery[offset:])^word)
+		}
+	}
+
+	return recovery
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func writeFiles(t *testing.T, dir string, files []testFile) {
+	for _, file := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file.name), file.data, 0o644))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	files := testFiles()
+	writeFiles(t, dir, files)
+
+	set, err := Open(createPAR2(t, dir, files, []uint32{0, 1})...)
+	require.NoError(t, err, "Failed to open set")
+
+	assert.Equal(t, uint64(testSliceSize), set.SliceSize)
+	assert.Equal(t, 6, set.InputSlices())
+	assert.Equal(t, 2, set.RecoverySlices())
+	require.Len(t, set.Files, 2)
+	assert.Equal(t, "second.bin", set.Files[1].Name)
+	assert.Equal(t, uint64(len(files[1].data)), set.Files[1].Length)
+
+	report, err := set.Verify(dir)
+	require.NoError(t, err, "Failed to verify")
+	assert.True(t, report.Intact())
+	assert.Equal(t, 0, report.DamagedSlices)
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name    string
+		damage  func(t *testing.T, dir string, files []testFile)
+		damaged int
+		status  []FileStatus
+	}{
+		{
+			name: "damaged slice",
+			damage: func(t *testing.T, dir string, files []testFile) {
+				data := append([]byte(nil), files[0].data...)
+				data[testSliceSize+3] ^= 0xff
+				require.NoError(t, os.WriteFile(filepath.Join(dir, files[0].name), data, 0o644))
+			},
+			damaged: 1,
+			status:  []FileStatus{FileDamaged, FileOK},
+		},
+		{
+			name: "truncated last slice and appended data",
+			damage: func(t *testing.T, dir string, files []testFile) {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, files[1].name), files[1].data[:len(files[1].data)-4], 0o644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, files[0].name), append(append([]byte(nil), files[0].data...), 'x'), 0o644))
+			},
+			damaged: 1,
+			status:  []FileStatus{FileDamaged, FileDamaged},
+		},
+		{
+			name: "two damaged slices in different files",
+			damage: func(t *testing.T, dir string, files []testFile) {
+				first := append([]byte(nil), files[0].data...)
+				first[0] ^= 1
+				second := append([]byte(nil), files[1].data...)
+				second[len(second)-1] ^= 1
+				require.NoError(t, os.WriteFile(filepath.Join(dir, files[0].name), first, 0o644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, files[1].name), second, 0o644))
+			},
+			damaged: 2,
+			status:  []FileStatus{FileDamaged, FileDamaged},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := testFiles()
+			writeFiles(t, dir, files)
+			test.damage(t, dir, files)
+
+			set, err := Open(createPAR2(t, dir, files, []uint32{0, 1})...)
+			require.NoError(t, err, "Failed to open set")
+
+			before, err := set.Verify(dir)
+			require.NoError(t, err, "Failed to verify")
+			assert.Equal(t, test.damaged, before.DamagedSlices)
+			assert.True(t, before.Repairable())
+
+			for idx, status := range test.status {
+				assert.Equal(t, status, before.Files[idx].Status, "Unexpected status of %s", before.Files[idx].Name)
+			}
+
+			after, err := set.Repair(dir)
+			require.NoError(t, err, "Failed to repair")
+			assert.True(t, after.Intact())
+
+			for _, file := range files {
+				data, err := os.ReadFile(filepath.Join(dir, file.name))
+				require.NoError(t, err)
+				assert.True(t, bytes.Equal(file.data, data), "%s was not repaired", file.name)
+			}
+		})
+	}
+}
+
+func TestRepair_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+	files := testFiles()
+	writeFiles(t, dir, files[:1])
+
+	// The second file has 3 slices
+	set, err := Open(createPAR2(t, dir, files, []uint32{0, 3, 5, 7})...)
+	require.NoError(t, err, "Failed to open set")
+
+	after, err := set.Repair(dir)
+	require.NoError(t, err, "Failed to repair")
+	assert.True(t, after.Intact())
+
+	data, err := os.ReadFile(filepath.Join(dir, files[1].name))
+	require.NoError(t, err)
+	assert.Equal(t, files[1].data, data)
+}
+
+func TestRepair_SingularRecovery(t *testing.T) {
+	dir := t.TempDir()
+	files := testFiles()
+	writeFiles(t, dir, files[:1])
+
+	first := append([]byte(nil), files[0].data...)
+	first[0] ^= 1
+	first[testSliceSize] ^= 1
+	require.NoError(t, os.WriteFile(filepath.Join(dir, files[0].name), first, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, files[1].name), files[1].data, 0o644))
+
+	// Every constant to the power of 65535 is 1, so the recovery slices with the lowest exponents are linearly dependent
+	set, err := Open(createPAR2(t, dir, files, []uint32{0, 65535, 65536})...)
+	require.NoError(t, err, "Failed to open set")
+
+	after, err := set.Repair(dir)
+	require.NoError(t, err, "Failed to repair")
+	assert.True(t, after.Intact())
+
+	data, err := os.ReadFile(filepath.Join(dir, files[0].name))
+	require.NoError(t, err)
+	assert.Equal(t, files[0].data, data)
+}
+
+// TestRepair_Par2cmdline repairs a file using recovery files created by par2cmdline, checking packet layout, slice
+// order & exponents against real files. The fixture in testdata/par2cmdline is created by:
+//
+//	par2 create -s512 -r30 -n2 source.par2 source.bin
+//	printf 'damaged' | dd of=source.bin bs=1 seek=1000 conv=notrunc
+func TestRepair_Par2cmdline(t *testing.T) {
+	fixture := filepath.Join("testdata", "par2cmdline")
+
+	paths, err := filepath.Glob(filepath.Join(fixture, "*.par2"))
+	require.NoError(t, err)
+
+	if len(paths) == 0 {
+		t.Skip("par2cmdline fixture not available")
+	}
+
+	dir := t.TempDir()
+
+	entries, err := os.ReadDir(fixture)
+	require.NoError(t, err)
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(fixture, entry.Name()))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, entry.Name()), data, 0o644))
+	}
+
+	for idx := range paths {
+		paths[idx] = filepath.Join(dir, filepath.Base(paths[idx]))
+	}
+
+	set, err := Open(paths...)
+	require.NoError(t, err, "Failed to open set")
+
+	before, err := set.Verify(dir)
+	require.NoError(t, err, "Failed to verify")
+	assert.False(t, before.Intact(), "The fixture must be damaged")
+	assert.True(t, before.Repairable())
+
+	// The repaired file must match the MD5 hash recorded by par2cmdline
+	after, err := set.Repair(dir)
+	require.NoError(t, err, "Failed to repair")
+	assert.True(t, after.Intact())
+}
+
+func TestRepair_NotEnoughRecovery(t *testing.T) {
+	dir := t.TempDir()
+	files := testFiles()
+	writeFiles(t, dir, files[:1])
+
+	set, err := Open(createPAR2(t, dir, files, []uint32{0, 1})...)
+	require.NoError(t, err, "Failed to open set")
+
+	report, err := set.Repair(dir)
+	assert.True(t, errors.Is(err, ErrNotEnoughRecovery), "Expected ErrNotEnoughRecovery, got %v", err)
+	require.NotNil(t, report)
+	assert.Equal(t, FileMissing, report.Files[1].Status)
+	assert.Equal(t, []int{0, 1, 2}, report.Files[1].DamagedSlices)
+	assert.Equal(t, 1, report.AdditionalNeeded)
+	assert.False(t, report.Repairable())
+
+	_, err = os.Stat(filepath.Join(dir, files[1].name))
+	assert.True(t, errors.Is(err, os.ErrNotExist), "Missing file must not be created without repair")
+}
+
+func TestOpen_DamagedPacket(t *testing.T) {
+	dir := t.TempDir()
+	files := testFiles()
+	writeFiles(t, dir, files)
+
+	paths := createPAR2(t, dir, files, []uint32{0, 1})
+
+	// Damage the main packet of the index file. The copy within the volume file must be used instead.
+	index, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	index[headerSize] ^= 0xff
+	require.NoError(t, os.WriteFile(paths[0], index, 0o644))
+
+	set, err := Open(paths...)
+	require.NoError(t, err, "Failed to open set")
+	assert.Len(t, set.Files, 2)
+
+	_, err = Open(paths[0])
+	assert.True(t, errors.Is(err, ErrNoMainPacket), "Expected ErrNoMainPacket, got %v", err)
+}
+
+func TestOpen_UnsafeFileName(t *testing.T) {
+	for _, name := range []string{"../escape.bin", "dir/../../escape.bin", "/etc/escape.bin", "..\\escape.bin"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := testFiles()
+			files[1].name = name
+
+			_, err := Open(createPAR2(t, dir, files, []uint32{0, 1})...)
+			assert.True(t, errors.Is(err, ErrUnsafeFileName), "Expected ErrUnsafeFileName, got %v", err)
+		})
+	}
+
+	set := &Set{SliceSize: testSliceSize, Files: []File{{Name: "../escape.bin", Length: 1}}}
+	_, err := set.Repair(t.TempDir())
+	assert.True(t, errors.Is(err, ErrUnsafeFileName), "Expected ErrUnsafeFileName, got %v", err)
+}
+
+func TestOpen_InvalidSliceSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		sliceSize uint64
+		length    uint64
+	}{
+		{name: "exceeds maximum", sliceSize: 1 << 40, length: 1 << 50},
+		{name: "exceeds files", sliceSize: 1 << 20, length: 100},
+		{name: "too many slices", sliceSize: 4, length: 4*maxInputSlices + 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setID, fileID := ID{1}, ID{2}
+
+			main := make([]byte, 12)
+			binary.LittleEndian.PutUint64(main, test.sliceSize)
+			binary.LittleEndian.PutUint32(main[8:], 1)
+			main = append(main, fileID[:]...)
+
+			desc := make([]byte, 56)
+			copy(desc, fileID[:])
+			binary.LittleEndian.PutUint64(desc[48:], test.length)
+			desc = append(desc, "file.bin"...)
+
+			path := filepath.Join(t.TempDir(), "test.par2")
+			data := append(packet(setID, typeMain, main), packet(setID, typeFileDesc, desc)...)
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+
+			_, err := Open(path)
+			assert.True(t, errors.Is(err, ErrInvalidPacket), "Expected ErrInvalidPacket, got %v", err)
+		})
+	}
+}
+
+func TestReferenceRecovery(t *testing.T) {
+	// Exponent 0 is the XOR of all input slices
+	slices := [][]byte{make([]byte, testSliceSize), make([]byte, testSliceSize)}
+	slices[0][0], slices[1][0] = 0x0f, 0xf1
+	assert.Equal(t, byte(0xfe), referenceRecovery(slices, 0)[0])
+
+	// Multiplication by x overflowing into the polynomial
+	assert.Equal(t, uint16(0x100b), referenceMul(0x8000, 2))
+	assert.Equal(t, gfMul(0x1234, 0xabcd), referenceMul(0x1234, 0xabcd))
+}
+
+func TestGF(t *testing.T) {
+	for _, a := range []uint16{1, 2, 3, 0x1234, 0xffff} {
+		for _, b := range []uint16{1, 7, 0x8000, 0xabcd} {
+			assert.Equal(t, a, gfDiv(gfMul(a, b), b))
+		}
+	}
+
+	assert.Equal(t, []uint16{2, 4, 16, 128}, inputConstants(4))
+}
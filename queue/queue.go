@@ -0,0 +1,687 @@
+// Package queue manages NZB downloads in a persistent, prioritized job queue.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp/download"
+	"github.com/mrincompetent/nntp/internal/atomicfile"
+	"github.com/mrincompetent/nntp/nzb"
+)
+
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+)
+
+// Job is a single NZB download. Only the fields up to NZB need to be set when adding a job.
+type Job struct {
+	// ID gets assigned by the queue if empty
+	ID   string
+	Name string
+	// Jobs with a higher priority get downloaded first. A running job gets interrupted when a job with a higher priority
+	// gets queued.
+	Priority int
+	// Limits of this job. 0 means unlimited.
+	Connections    int
+	BytesPerSecond int64
+	Dir            string
+	// Stored in its own file instead of the state file, it doesn't change after adding the job
+	NZB *nzb.NZB `json:"-"`
+
+	State State
+	Error string
+	Added time.Time
+	// Order of addition, used to keep the order of jobs with the same priority
+	Sequence uint64
+
+	// Message-ids of all downloaded segments
+	Done map[string]bool
+	// Files written so far, keyed by their index within the NZB
+	Files  map[int]download.FileInfo
+	Result []download.FileResult
+}
+
+// Status is a snapshot of a job without its NZB & bookkeeping.
+type Status struct {
+	ID             string
+	Name           string
+	Priority       int
+	Connections    int
+	BytesPerSecond int64
+	State          State
+	Error          string
+	Added          time.Time
+	DoneSegments   int
+	TotalSegments  int
+	Result         []download.FileResult
+}
+
+type EventType string
+
+const (
+	EventJobStarted   EventType = "job-started"
+	EventJobProgress  EventType = "job-progress"
+	EventJobStopped   EventType = "job-stopped"
+	EventJobCompleted EventType = "job-completed"
+	EventJobFailed    EventType = "job-failed"
+)
+
+type Event struct {
+	Type  EventType
+	JobID string
+	// Only set for EventJobProgress
+	Download download.Event
+	// State of the job after the event
+	State State
+	Err   error
+}
+
+var (
+	ErrUnknownJob   = errors.New("unknown job")
+	ErrInvalidState = errors.New("invalid job state")
+	ErrDuplicateJob = errors.New("job with the same id already exists")
+	ErrMissingNZB   = errors.New("job without nzb")
+)
+
+// Queue downloads the queued jobs one after another, ordered by priority.
+// The state of all jobs, including which segments have been downloaded, gets persisted to a state file. The NZBs of
+// the jobs are stored in the directory next to it, named like the state file with the suffix ".nzb". After a restart,
+// interrupted jobs continue where they left off.
+type Queue struct {
+	// SaveInterval limits how often the progress of the running job gets persisted. State changes are persisted
+	// immediately. The written files are synced before every save, which makes saving expensive.
+	SaveInterval time.Duration
+	// Events receives job events if set. It must be drained, otherwise the queue blocks.
+	Events chan<- Event
+
+	path     string
+	fetchers []download.Fetcher
+
+	lock     sync.Mutex
+	jobs     []*Job
+	sequence uint64
+	running  *runningJob
+	lastSave time.Time
+	wake     chan struct{}
+	// NZB files of removed jobs, deleted once the state file no longer references them
+	removed []string
+	// Files written since the last save, synced before the state file records their segments as done
+	unsynced map[string]bool
+
+	saveLock sync.Mutex
+}
+
+type runningJob struct {
+	job    *Job
+	cancel context.CancelFunc
+	// State the job ends up in after getting cancelled
+	stopState State
+	removed   bool
+}
+
+type stateFile struct {
+	Sequence uint64
+	Jobs     []*Job
+}
+
+// Open loads the queue state from path. A missing state file results in an empty queue.
+func Open(path string, fetchers ...download.Fetcher) (*Queue, error) {
+	q := &Queue{
+		SaveInterval: 5 * time.Second,
+		path:         path,
+		fetchers:     fetchers,
+		wake:         make(chan struct{}, 1),
+		unsynced:     map[string]bool{},
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := stateFile{}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file '%s': %w", path, err)
+	}
+
+	q.sequence = state.Sequence
+	q.jobs = state.Jobs
+
+	for _, job := range q.jobs {
+		if job.NZB, err = q.loadNZB(job); err != nil {
+			return nil, err
+		}
+
+		// The process ended while the job was running
+		if job.State == StateRunning {
+			job.State = StateQueued
+		}
+	}
+
+	return q, nil
+}
+
+// Add queues a new job & returns its ID.
+func (q *Queue) Add(job Job) (string, error) {
+	if job.NZB == nil {
+		return "", ErrMissingNZB
+	}
+
+	q.lock.Lock()
+	q.sequence++
+	job.Sequence = q.sequence
+	q.lock.Unlock()
+
+	if job.ID == "" {
+		job.ID = strconv.FormatUint(job.Sequence, 10)
+	}
+
+	// The file is named after the sequence, so it doesn't collide with other jobs
+	if err := q.saveNZB(&job); err != nil {
+		return "", err
+	}
+
+	q.lock.Lock()
+
+	if _, err := q.find(job.ID); err == nil {
+		q.lock.Unlock()
+		os.Remove(q.nzbPath(&job))
+
+		return "", fmt.Errorf("%w: '%s'", ErrDuplicateJob, job.ID)
+	}
+
+	job.State = StateQueued
+	job.Error = ""
+	// In UTC & without monotonic clock reading, so the time compares equal after loading the state file
+	job.Added = time.Now().UTC().Round(0)
+	job.Done = map[string]bool{}
+	job.Files = map[int]download.FileInfo{}
+	job.Result = nil
+
+	q.jobs = append(q.jobs, &job)
+	q.schedule()
+	q.lock.Unlock()
+
+	return job.ID, q.save()
+}
+
+// Pause stops a queued or running job. Downloaded segments are kept.
+func (q *Queue) Pause(id string) error {
+	return q.update(id, func(job *Job) error {
+		switch {
+		case q.running != nil && q.running.job == job:
+			q.running.stopState = StatePaused
+			q.running.cancel()
+		case job.State == StateQueued:
+			job.State = StatePaused
+		default:
+			return fmt.Errorf("%w: can't pause %s job", ErrInvalidState, job.State)
+		}
+
+		return nil
+	})
+}
+
+// Resume queues a paused or failed job again.
+func (q *Queue) Resume(id string) error {
+	return q.update(id, func(job *Job) error {
+		if job.State != StatePaused && job.State != StateFailed {
+			return fmt.Errorf("%w: can't resume %s job", ErrInvalidState, job.State)
+		}
+
+		job.State = StateQueued
+		job.Error = ""
+
+		return nil
+	})
+}
+
+func (q *Queue) SetPriority(id string, priority int) error {
+	return q.update(id, func(job *Job) error {
+		job.Priority = priority
+		return nil
+	})
+}
+
+// SetLimits changes the connection & bandwidth limits of a job. A running job gets restarted to apply them.
+func (q *Queue) SetLimits(id string, connections int, bytesPerSecond int64) error {
+	return q.update(id, func(job *Job) error {
+		job.Connections = connections
+		job.BytesPerSecond = bytesPerSecond
+
+		if q.running != nil && q.running.job == job {
+			q.running.stopState = StateQueued
+			q.running.cancel()
+		}
+
+		return nil
+	})
+}
+
+// Remove deletes the job from the queue. Files written by the job are kept.
+func (q *Queue) Remove(id string) error {
+	return q.update(id, func(job *Job) error {
+		if q.running != nil && q.running.job == job {
+			q.running.removed = true
+			q.running.cancel()
+
+			return nil
+		}
+
+		q.remove(job)
+
+		return nil
+	})
+}
+
+func (q *Queue) Job(id string) (Status, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	job, err := q.find(id)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return job.status(), nil
+}
+
+// Jobs returns the status of all jobs in the order they get downloaded.
+func (q *Queue) Jobs() []Status {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	jobs := append([]*Job(nil), q.jobs...)
+	sortJobs(jobs)
+
+	statuses := make([]Status, len(jobs))
+	for idx := range jobs {
+		statuses[idx] = jobs[idx].status()
+	}
+
+	return statuses
+}
+
+// Run downloads queued jobs until the context gets cancelled. Interrupted jobs stay queued.
+func (q *Queue) Run(ctx context.Context) error {
+	for {
+		job := q.next(ctx)
+		if job == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err := q.runJob(ctx, job); err != nil {
+			return err
+		}
+	}
+}
+
+// next marks the job with the highest priority as running.
+func (q *Queue) next(ctx context.Context) *Job {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var best *Job
+
+	for _, job := range q.jobs {
+		if job.State == StateQueued && (best == nil || before(job, best)) {
+			best = job
+		}
+	}
+
+	if best == nil || ctx.Err() != nil {
+		return nil
+	}
+
+	best.State = StateRunning
+
+	return best
+}
+
+func (q *Queue) runJob(ctx context.Context, job *Job) error {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.lock.Lock()
+	q.running = &runningJob{job: job, cancel: cancel}
+	d := q.downloader(job)
+	q.lock.Unlock()
+
+	if err := q.save(); err != nil {
+		return err
+	}
+
+	if err := q.emit(ctx, Event{Type: EventJobStarted, JobID: job.ID, State: StateRunning}); err != nil {
+		return err
+	}
+
+	events := make(chan download.Event, 64)
+	d.Events = events
+
+	progressDone := make(chan error, 1)
+	go func() {
+		progressDone <- q.trackProgress(ctx, job, events)
+	}()
+
+	result, downloadErr := d.Download(jobCtx, job.NZB)
+	close(events)
+
+	if err := <-progressDone; err != nil {
+		return err
+	}
+
+	q.lock.Lock()
+	running := q.running
+	q.running = nil
+
+	event := Event{JobID: job.ID}
+
+	switch {
+	case running.removed:
+		q.remove(job)
+		event.Type = EventJobStopped
+	case downloadErr == nil:
+		job.State = StateCompleted
+		job.Result = result.Files
+		event.Type = EventJobCompleted
+	case ctx.Err() != nil:
+		// The queue got stopped, continue on the next run
+		job.State = StateQueued
+		event.Type = EventJobStopped
+	case jobCtx.Err() != nil && running.stopState != "":
+		job.State = running.stopState
+		event.Type = EventJobStopped
+	default:
+		job.State = StateFailed
+		job.Error = downloadErr.Error()
+		event.Type = EventJobFailed
+		event.Err = downloadErr
+	}
+
+	event.State = job.State
+	q.lock.Unlock()
+
+	if err := q.save(); err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return q.emit(ctx, event)
+}
+
+func (q *Queue) downloader(job *Job) *download.Downloader {
+	d := download.New(job.Dir, q.fetchers...)
+	d.Connections = job.Connections
+	d.BytesPerSecond = job.BytesPerSecond
+
+	d.Skip = func(file int, segment nzb.Segment) bool {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+
+		return job.Done[segment.MessageID]
+	}
+
+	d.Resume = make(map[int]download.FileInfo, len(job.Files))
+	for idx, info := range job.Files {
+		d.Resume[idx] = info
+	}
+
+	return d
+}
+
+func (q *Queue) trackProgress(ctx context.Context, job *Job, events <-chan download.Event) error {
+	var emitErr error
+
+	for event := range events {
+		if event.Type == download.EventSegmentDone {
+			q.lock.Lock()
+			job.Done[event.Segment.MessageID] = true
+			job.Files[event.File] = event.FileInfo
+			q.unsynced[event.FileInfo.Path] = true
+			save := time.Since(q.lastSave) >= q.SaveInterval
+			q.lock.Unlock()
+
+			if save {
+				if err := q.save(); err != nil && emitErr == nil {
+					emitErr = err
+				}
+			}
+		}
+
+		// Keep draining the events after an error, the downloader blocks otherwise.
+		if emitErr == nil {
+			emitErr = q.emit(ctx, Event{Type: EventJobProgress, JobID: job.ID, Download: event, State: StateRunning})
+		}
+	}
+
+	if errors.Is(emitErr, context.Canceled) || errors.Is(emitErr, context.DeadlineExceeded) {
+		return nil
+	}
+
+	return emitErr
+}
+
+func (q *Queue) emit(ctx context.Context, event Event) error {
+	if q.Events == nil {
+		return nil
+	}
+
+	select {
+	case q.Events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update applies fn to the job, reschedules & persists the queue.
+func (q *Queue) update(id string, fn func(job *Job) error) error {
+	q.lock.Lock()
+
+	job, err := q.find(id)
+	if err == nil {
+		err = fn(job)
+	}
+
+	if err != nil {
+		q.lock.Unlock()
+		return err
+	}
+
+	q.schedule()
+	q.lock.Unlock()
+
+	return q.save()
+}
+
+// schedule wakes up Run & interrupts the running job if a job with a higher priority is queued.
+// The caller must hold the lock.
+func (q *Queue) schedule() {
+	if q.running != nil && q.running.stopState == "" {
+		for _, job := range q.jobs {
+			if job.State == StateQueued && job.Priority > q.running.job.Priority {
+				q.running.stopState = StateQueued
+				q.running.cancel()
+
+				break
+			}
+		}
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) find(id string) (*Job, error) {
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: '%s'", ErrUnknownJob, id)
+}
+
+// remove deletes the job from the queue. Its NZB file is deleted by the next save.
+// The caller must hold the lock.
+func (q *Queue) remove(job *Job) {
+	for idx := range q.jobs {
+		if q.jobs[idx] == job {
+			q.jobs = append(q.jobs[:idx], q.jobs[idx+1:]...)
+			q.removed = append(q.removed, q.nzbPath(job))
+
+			return
+		}
+	}
+}
+
+// save atomically replaces the state file.
+func (q *Queue) save() error {
+	q.saveLock.Lock()
+	defer q.saveLock.Unlock()
+
+	q.lock.Lock()
+	b, err := json.Marshal(stateFile{Sequence: q.sequence, Jobs: q.jobs})
+	q.lastSave = time.Now()
+	removed := q.removed
+	q.removed = nil
+	unsynced := q.unsynced
+	q.unsynced = map[string]bool{}
+	q.lock.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode queue state: %w", err)
+	}
+
+	// The segments recorded as done must be on disk before the state file, otherwise they're lost after a crash
+	for path := range unsynced {
+		if err := syncFile(path); err != nil {
+			q.lock.Lock()
+			for path := range unsynced {
+				q.unsynced[path] = true
+			}
+			q.lock.Unlock()
+
+			return fmt.Errorf("failed to sync '%s': %w", path, err)
+		}
+	}
+
+	if err := atomicfile.WriteFile(q.path, b); err != nil {
+		return err
+	}
+
+	for _, path := range removed {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (q *Queue) nzbPath(job *Job) string {
+	return filepath.Join(q.path+".nzb", strconv.FormatUint(job.Sequence, 10)+".nzb")
+}
+
+func (q *Queue) saveNZB(job *Job) error {
+	if err := os.MkdirAll(filepath.Dir(q.nzbPath(job)), 0o755); err != nil {
+		return err
+	}
+
+	return atomicfile.Write(q.nzbPath(job), func(w io.Writer) error {
+		return job.NZB.Write(w)
+	})
+}
+
+func (q *Queue) loadNZB(job *Job) (*nzb.NZB, error) {
+	f, err := os.Open(q.nzbPath(job))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open nzb of job '%s': %w", job.ID, err)
+	}
+
+	defer f.Close()
+
+	n, err := nzb.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse nzb of job '%s': %w", job.ID, err)
+	}
+
+	return n, nil
+}
+
+func (job *Job) status() Status {
+	status := Status{
+		ID:             job.ID,
+		Name:           job.Name,
+		Priority:       job.Priority,
+		Connections:    job.Connections,
+		BytesPerSecond: job.BytesPerSecond,
+		State:          job.State,
+		Error:          job.Error,
+		Added:          job.Added,
+		DoneSegments:   len(job.Done),
+		TotalSegments:  job.NZB.SegmentCount(),
+		Result:         append([]download.FileResult(nil), job.Result...),
+	}
+
+	return status
+}
+
+func before(a, b *Job) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+
+	return a.Sequence < b.Sequence
+}
+
+func sortJobs(jobs []*Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return before(jobs[i], jobs[j])
+	})
+}
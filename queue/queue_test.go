@@ -0,0 +1,361 @@
+package queue_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/download"
+	"github.com/mrincompetent/nntp/nzb"
+	"github.com/mrincompetent/nntp/queue"
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+type fakeFetcher struct {
+	lock     sync.Mutex
+	articles map[string][]byte
+	requests []string
+	// If set, all requests after the first passed ones block until gate gets closed
+	gate   chan struct{}
+	passed int
+}
+
+func newFakeFetcher() *fakeFetcher {
+	return &fakeFetcher{
+		articles: map[string][]byte{},
+	}
+}
+
+func (f *fakeFetcher) Body(id string) (nntp.Article, error) {
+	f.lock.Lock()
+	block := f.gate != nil && len(f.requests) >= f.passed
+	f.lock.Unlock()
+
+	if block {
+		<-f.gate
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.requests = append(f.requests, id)
+
+	body, ok := f.articles[id]
+	if !ok {
+		return nntp.Article{}, nntp.ErrNoSuchArticle
+	}
+
+	return nntp.Article{MessageID: id, Body: body}, nil
+}
+
+func (f *fakeFetcher) Requests() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]string(nil), f.requests...)
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	return data
+}
+
+func post(t testing.TB, f *fakeFetcher, name string, data []byte, partSize int64) *nzb.NZB {
+	e := yenc.NewEncoder(bytes.NewReader(data), name, int64(len(data)))
+	e.PartSize = partSize
+
+	file := nzb.File{Subject: name, Groups: []string{"alt.binaries.test"}}
+
+	for {
+		part, err := e.Next()
+		if errors.Is(err, io.EOF) {
+			return &nzb.NZB{Files: []nzb.File{file}}
+		}
+
+		require.NoError(t, err, "Failed to encode")
+
+		id := fmt.Sprintf("%s-%d@test", name, part.Number)
+		f.articles["<"+id+">"] = part.Body
+
+		file.Segments = append(file.Segments, nzb.Segment{Number: part.Number, Bytes: uint64(len(part.Body)), MessageID: id})
+	}
+}
+
+// runUntil runs the queue until done returns true for a received event.
+func runUntil(t testing.TB, q *queue.Queue, events chan queue.Event, done func(event queue.Event) bool) []queue.Event {
+	return runUntilWithRelease(t, q, events, done, func() {})
+}
+
+// runUntilWithRelease calls release after stopping the queue, which must unblock all blocked fetchers.
+func runUntilWithRelease(t testing.TB, q *queue.Queue, events chan queue.Event, done func(event queue.Event) bool, release func()) []queue.Event {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- q.Run(ctx)
+	}()
+
+	var received []queue.Event
+
+	for event := range events {
+		received = append(received, event)
+
+		if done(event) {
+			break
+		}
+	}
+
+	cancel()
+	release()
+
+	// Drain events emitted while stopping
+	go func() {
+		for range events {
+		}
+	}()
+
+	assert.True(t, errors.Is(<-runErr, context.Canceled))
+	close(events)
+
+	return received
+}
+
+func completed(ids ...string) func(event queue.Event) bool {
+	remaining := map[string]bool{}
+	for _, id := range ids {
+		remaining[id] = true
+	}
+
+	return func(event queue.Event) bool {
+		if event.Type == queue.EventJobCompleted || event.Type == queue.EventJobFailed {
+			delete(remaining, event.JobID)
+		}
+
+		return len(remaining) == 0
+	}
+}
+
+func TestQueue_Priorities(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+
+	q, err := queue.Open(filepath.Join(dir, "state.json"), fetcher)
+	require.NoError(t, err, "Failed to open queue")
+
+	low, err := q.Add(queue.Job{Name: "low", Dir: dir, NZB: post(t, fetcher, "low.bin", testData(2000), 1000)})
+	require.NoError(t, err, "Failed to add job")
+
+	high, err := q.Add(queue.Job{Name: "high", Priority: 10, Dir: dir, NZB: post(t, fetcher, "high.bin", testData(2000), 1000)})
+	require.NoError(t, err, "Failed to add job")
+
+	paused, err := q.Add(queue.Job{Name: "paused", Priority: 20, Dir: dir, NZB: post(t, fetcher, "paused.bin", testData(1000), 1000)})
+	require.NoError(t, err, "Failed to add job")
+	require.NoError(t, q.Pause(paused), "Failed to pause job")
+
+	assert.Equal(t, []string{"paused", "high", "low"}, names(q.Jobs()))
+
+	events := make(chan queue.Event, 100)
+	q.Events = events
+
+	runUntil(t, q, events, completed(low, high))
+
+	assert.Equal(t, []string{"<high.bin-1@test>", "<high.bin-2@test>", "<low.bin-1@test>", "<low.bin-2@test>"}, sortedPerJob(fetcher.Requests()))
+
+	status, err := q.Job(high)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, queue.StateCompleted, status.State)
+	assert.Equal(t, 2, status.DoneSegments)
+	require.Len(t, status.Result, 1)
+	assert.Equal(t, download.CRCValid, status.Result[0].CRCStatus)
+
+	status, err = q.Job(paused)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, queue.StatePaused, status.State)
+
+	// The state survives a restart
+	reopened, err := queue.Open(filepath.Join(dir, "state.json"), fetcher)
+	require.NoError(t, err, "Failed to reopen queue")
+	assert.Equal(t, q.Jobs(), reopened.Jobs())
+}
+
+func TestQueue_ResumeAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	fetcher := newFakeFetcher()
+	data := testData(3000)
+
+	// Only the first segment gets through during the first run
+	fetcher.gate = make(chan struct{})
+	fetcher.passed = 1
+
+	q, err := queue.Open(statePath, fetcher)
+	require.NoError(t, err, "Failed to open queue")
+	q.SaveInterval = 0
+
+	id, err := q.Add(queue.Job{Name: "job", Dir: dir, Connections: 1, NZB: post(t, fetcher, "file.bin", data, 1000)})
+	require.NoError(t, err, "Failed to add job")
+
+	// Stop after the first segment
+	events := make(chan queue.Event, 100)
+	q.Events = events
+
+	runUntilWithRelease(t, q, events, func(event queue.Event) bool {
+		return event.Type == queue.EventJobProgress && event.Download.Type == download.EventSegmentDone
+	}, func() {
+		close(fetcher.gate)
+	})
+
+	// The blocked second request may or may not finish while stopping
+	require.Less(t, len(fetcher.Requests()), 3, "Job got completely downloaded before it could be interrupted")
+
+	// Restart
+	q, err = queue.Open(statePath, fetcher)
+	require.NoError(t, err, "Failed to reopen queue")
+
+	status, err := q.Job(id)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, queue.StateQueued, status.State)
+	assert.GreaterOrEqual(t, status.DoneSegments, 1)
+
+	events = make(chan queue.Event, 100)
+	q.Events = events
+
+	runUntil(t, q, events, completed(id))
+
+	// Every segment got requested once, except the one interrupted by the stop
+	assert.LessOrEqual(t, len(fetcher.Requests()), 4)
+
+	status, err = q.Job(id)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, queue.StateCompleted, status.State)
+	assert.Equal(t, download.CRCValid, status.Result[0].CRCStatus)
+
+	written, err := ioutil.ReadFile(filepath.Join(dir, "file.bin"))
+	require.NoError(t, err, "Failed to read file")
+	assert.Equal(t, data, written)
+}
+
+func TestQueue_PauseRunning(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+	fetcher.gate = make(chan struct{})
+
+	q, err := queue.Open(filepath.Join(dir, "state.json"), fetcher)
+	require.NoError(t, err, "Failed to open queue")
+
+	id, err := q.Add(queue.Job{Name: "job", Dir: dir, NZB: post(t, fetcher, "file.bin", testData(3000), 1000)})
+	require.NoError(t, err, "Failed to add job")
+
+	events := make(chan queue.Event, 100)
+	q.Events = events
+
+	received := runUntil(t, q, events, func(event queue.Event) bool {
+		switch event.Type {
+		case queue.EventJobStarted:
+			require.NoError(t, q.Pause(id), "Failed to pause job")
+			close(fetcher.gate)
+		case queue.EventJobStopped:
+			return true
+		}
+
+		return false
+	})
+
+	assert.Equal(t, queue.StatePaused, received[len(received)-1].State)
+
+	status, err := q.Job(id)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, queue.StatePaused, status.State)
+
+	assert.True(t, errors.Is(q.Pause(id), queue.ErrInvalidState))
+	require.NoError(t, q.Resume(id), "Failed to resume job")
+
+	status, err = q.Job(id)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, queue.StateQueued, status.State)
+}
+
+func TestQueue_NZBFiles(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	fetcher := newFakeFetcher()
+
+	q, err := queue.Open(statePath, fetcher)
+	require.NoError(t, err, "Failed to open queue")
+
+	kept, err := q.Add(queue.Job{Name: "kept", Dir: dir, NZB: post(t, fetcher, "kept.bin", testData(2000), 1000)})
+	require.NoError(t, err, "Failed to add job")
+
+	removed, err := q.Add(queue.Job{Name: "removed", Dir: dir, NZB: post(t, fetcher, "removed.bin", testData(1000), 1000)})
+	require.NoError(t, err, "Failed to add job")
+
+	// The state file only holds the progress, not the NZBs
+	state, err := ioutil.ReadFile(statePath)
+	require.NoError(t, err, "Failed to read state file")
+	assert.NotContains(t, string(state), "alt.binaries.test")
+
+	require.NoError(t, q.Remove(removed), "Failed to remove job")
+
+	entries, err := os.ReadDir(statePath + ".nzb")
+	require.NoError(t, err, "Failed to list nzb directory")
+	assert.Len(t, entries, 1, "Expected the nzb of the removed job to be deleted")
+
+	reopened, err := queue.Open(statePath, fetcher)
+	require.NoError(t, err, "Failed to reopen queue")
+
+	status, err := reopened.Job(kept)
+	require.NoError(t, err, "Failed to get job")
+	assert.Equal(t, 2, status.TotalSegments)
+
+	events := make(chan queue.Event, 100)
+	reopened.Events = events
+
+	runUntil(t, reopened, events, completed(kept))
+
+	written, err := ioutil.ReadFile(filepath.Join(dir, "kept.bin"))
+	require.NoError(t, err, "Failed to read file")
+	assert.Equal(t, testData(2000), written)
+}
+
+func names(statuses []queue.Status) []string {
+	result := make([]string, len(statuses))
+	for idx := range statuses {
+		result[idx] = statuses[idx].Name
+	}
+
+	return result
+}
+
+// sortedPerJob sorts the requests within runs of the same file, as segments of a job are fetched concurrently.
+func sortedPerJob(requests []string) []string {
+	result := append([]string(nil), requests...)
+
+	for i := 1; i < len(result); i++ {
+		for j := i; j > 0 && sameFile(result[j-1], result[j]) && result[j-1] > result[j]; j-- {
+			result[j-1], result[j] = result[j], result[j-1]
+		}
+	}
+
+	return result
+}
+
+func sameFile(a, b string) bool {
+	return a[:len(a)-8] == b[:len(b)-8]
+}
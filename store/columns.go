@@ -0,0 +1,204 @@
+package store
+
+import "math"
+
+// Number of rows sharing the base of a deltaColumn
+const blockSize = 1024
+
+// deltaColumn stores integers as 32 bit deltas to the first value of their block. Values whose delta doesn't fit are
+// stored in Overflow.
+type deltaColumn struct {
+	Bases    []int64
+	Deltas   []int32
+	Overflow map[uint32]int64
+}
+
+func (c *deltaColumn) add(value int64) {
+	row := len(c.Deltas)
+	if row%blockSize == 0 {
+		c.Bases = append(c.Bases, value)
+	}
+
+	delta := value - c.Bases[len(c.Bases)-1]
+	if delta < math.MinInt32 || delta > math.MaxInt32 {
+		if c.Overflow == nil {
+			c.Overflow = map[uint32]int64{}
+		}
+
+		c.Overflow[uint32(row)] = value
+		delta = 0
+	}
+
+	c.Deltas = append(c.Deltas, int32(delta))
+}
+
+func (c *deltaColumn) get(row int) int64 {
+	if value, ok := c.Overflow[uint32(row)]; ok {
+		return value
+	}
+
+	return c.Bases[row/blockSize] + int64(c.Deltas[row])
+}
+
+func (c *deltaColumn) len() int {
+	return len(c.Deltas)
+}
+
+// valid reports whether every block of deltas has a base.
+func (c *deltaColumn) valid() bool {
+	return len(c.Bases) == (len(c.Deltas)+blockSize-1)/blockSize
+}
+
+// validOffsets reports whether the values are ascending offsets within [0, max].
+func (c *deltaColumn) validOffsets(max int) bool {
+	if !c.valid() {
+		return false
+	}
+
+	var last int64
+
+	for row := 0; row < c.len(); row++ {
+		value := c.get(row)
+		if value < last || value > int64(max) {
+			return false
+		}
+
+		last = value
+	}
+
+	return true
+}
+
+// stringColumn stores strings back to back.
+type stringColumn struct {
+	Data []byte
+	// End offset of each value within Data
+	Ends deltaColumn
+}
+
+func (c *stringColumn) add(s string) {
+	c.Data = append(c.Data, s...)
+	c.Ends.add(int64(len(c.Data)))
+}
+
+func (c *stringColumn) span(row int) (start, end int64) {
+	if row > 0 {
+		start = c.Ends.get(row - 1)
+	}
+
+	return start, c.Ends.get(row)
+}
+
+func (c *stringColumn) get(row int) string {
+	start, end := c.span(row)
+
+	return string(c.Data[start:end])
+}
+
+func (c *stringColumn) equal(row int, s string) bool {
+	start, end := c.span(row)
+
+	return string(c.Data[start:end]) == s
+}
+
+func (c *stringColumn) len() int {
+	return c.Ends.len()
+}
+
+func (c *stringColumn) valid() bool {
+	return c.Ends.validOffsets(len(c.Data))
+}
+
+// listColumn stores a list of ids per row.
+type listColumn struct {
+	Values []uint32
+	// End offset of each list within Values
+	Ends deltaColumn
+}
+
+func (c *listColumn) add(values ...uint32) {
+	c.Values = append(c.Values, values...)
+	c.Ends.add(int64(len(c.Values)))
+}
+
+func (c *listColumn) span(row int) (start, end int) {
+	if row > 0 {
+		start = int(c.Ends.get(row - 1))
+	}
+
+	return start, int(c.Ends.get(row))
+}
+
+func (c *listColumn) valid() bool {
+	return c.Ends.validOffsets(len(c.Values))
+}
+
+// dictionary stores each distinct string once & refers to it by id.
+// Its index only contains hashes, so the strings aren't kept twice.
+type dictionary struct {
+	Values stringColumn
+
+	index map[uint64]uint32
+	// Strings whose hash is taken by another one
+	collisions map[string]uint32
+}
+
+func (d *dictionary) id(s string) uint32 {
+	if d.index == nil {
+		d.rebuild()
+	}
+
+	hash := fnv64(s)
+
+	id, taken := d.index[hash]
+	if taken && d.Values.equal(int(id), s) {
+		return id
+	}
+
+	if id, ok := d.collisions[s]; ok {
+		return id
+	}
+
+	id = uint32(d.Values.len())
+	d.Values.add(s)
+
+	if taken {
+		d.collisions[s] = id
+	} else {
+		d.index[hash] = id
+	}
+
+	return id
+}
+
+func (d *dictionary) value(id uint32) string {
+	return d.Values.get(int(id))
+}
+
+// rebuild creates the index, which isn't persisted.
+func (d *dictionary) rebuild() {
+	d.index = make(map[uint64]uint32, d.Values.len())
+	d.collisions = map[string]uint32{}
+
+	for id := 0; id < d.Values.len(); id++ {
+		value := d.Values.get(id)
+		hash := fnv64(value)
+
+		if _, taken := d.index[hash]; taken {
+			d.collisions[value] = uint32(id)
+		} else {
+			d.index[hash] = uint32(id)
+		}
+	}
+}
+
+func fnv64(s string) uint64 {
+	hash := uint64(14695981039346656037)
+
+	for idx := 0; idx < len(s); idx++ {
+		hash ^= uint64(s[idx])
+		hash *= 1099511628211
+	}
+
+	return hash
+}
@@ -0,0 +1,493 @@
+// Package store keeps large numbers of overview headers in memory using a compact columnar layout.
+package store
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/internal/atomicfile"
+)
+
+// Store holds overview headers column by column instead of as nntp.Header structs:
+//
+//   - Article numbers, dates, bytes & lines are stored as 32 bit deltas to the first value of blocks of 1024 headers
+//   - Posters, message-ids & the names of additional fields are stored once in dictionaries
+//   - References are stored as lists of message-id ids
+//   - Subjects & the values of additional fields are stored back to back in a single buffer
+//
+// Dates are stored with second precision. Header.Errors is not stored.
+// All methods are safe for concurrent use.
+type Store struct {
+	lock    sync.Mutex
+	columns columns
+
+	// Locations by zone id, zone ids by zone
+	locations []*time.Location
+	zoneIDs   map[zone]uint16
+	// Whether the article numbers were appended in ascending order
+	sorted     bool
+	lastNumber uint64
+	// Rows sorted by article number. Only maintained if the numbers weren't appended in ascending order.
+	order []uint32
+}
+
+// columns are the persisted data of a Store.
+type columns struct {
+	Numbers deltaColumn
+	// Unix seconds
+	Dates deltaColumn
+	// Index into Zones plus one, zero for headers without date
+	ZoneIDs         []uint16
+	Zones           []zone
+	Subjects        stringColumn
+	DecodedSubjects stringColumn
+	// Authors & DecodedAuthors refer to Posters
+	Authors        []uint32
+	DecodedAuthors []uint32
+	Posters        dictionary
+	// MessageIDs & References refer to IDs
+	MessageIDs []uint32
+	References listColumn
+	IDs        dictionary
+	// References which are not a space separated list of message-ids, by row
+	RawReferences map[uint32]string
+	Bytes         deltaColumn
+	Lines         deltaColumn
+	// Names of the additional fields, referring to AdditionalKeys. Their values are stored at the same position in
+	// AdditionalValues.
+	Additional       listColumn
+	AdditionalValues stringColumn
+	AdditionalKeys   dictionary
+}
+
+type zone struct {
+	Name   string
+	Offset int
+}
+
+var (
+	ErrFull         = errors.New("store is full")
+	ErrInvalidFile  = errors.New("invalid store file")
+	ErrInvalidIndex = errors.New("invalid index")
+)
+
+func New() *Store {
+	return &Store{
+		zoneIDs: map[zone]uint16{},
+		sorted:  true,
+	}
+}
+
+// Append adds the headers.
+func (s *Store) Append(headers ...nntp.Header) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for idx := range headers {
+		if err := s.append(headers[idx]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AppendChan adds all headers from the channel until both channels get closed, like the ones returned by
+// nntp.Client.XoverChan. Both are drained, so the sender doesn't block. The first error of appending a header or
+// received from errs gets returned. errs may be nil.
+func (s *Store) AppendChan(headers <-chan nntp.Header, errs <-chan error) error {
+	var err error
+
+	for headers != nil || errs != nil {
+		select {
+		case header, ok := <-headers:
+			if !ok {
+				headers = nil
+				continue
+			}
+
+			if err == nil {
+				err = s.Append(header)
+			}
+		case received, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+
+			if err == nil {
+				err = received
+			}
+		}
+	}
+
+	return err
+}
+
+func (s *Store) append(header nntp.Header) error {
+	c := &s.columns
+
+	row := c.Numbers.len()
+	if row >= math.MaxUint32 {
+		return ErrFull
+	}
+
+	zoneID, err := s.zoneID(header.Date)
+	if err != nil {
+		return err
+	}
+
+	if row > 0 && header.MessageNumber < s.lastNumber {
+		s.sorted = false
+	}
+
+	s.lastNumber = header.MessageNumber
+	s.order = nil
+
+	c.Numbers.add(int64(header.MessageNumber))
+	c.Dates.add(header.Date.Unix())
+	c.ZoneIDs = append(c.ZoneIDs, zoneID)
+	c.Subjects.add(header.Subject)
+	c.DecodedSubjects.add(header.DecodedSubject)
+	c.Authors = append(c.Authors, c.Posters.id(header.Author))
+	c.DecodedAuthors = append(c.DecodedAuthors, c.Posters.id(header.DecodedAuthor))
+	c.MessageIDs = append(c.MessageIDs, c.IDs.id(header.MessageID))
+	c.Bytes.add(int64(header.Bytes))
+	c.Lines.add(int64(header.Lines))
+
+	s.appendReferences(row, header.References)
+	s.appendAdditional(header.Additional)
+
+	return nil
+}
+
+func (s *Store) zoneID(date time.Time) (uint16, error) {
+	if date.IsZero() {
+		return 0, nil
+	}
+
+	name, offset := date.Zone()
+	key := zone{Name: name, Offset: offset}
+
+	if id, ok := s.zoneIDs[key]; ok {
+		return id, nil
+	}
+
+	if len(s.columns.Zones) >= math.MaxUint16-1 {
+		return 0, fmt.Errorf("%w: too many zones", ErrFull)
+	}
+
+	s.columns.Zones = append(s.columns.Zones, key)
+	s.locations = append(s.locations, time.FixedZone(name, offset))
+
+	id := uint16(len(s.columns.Zones))
+	s.zoneIDs[key] = id
+
+	return id, nil
+}
+
+func (s *Store) appendReferences(row int, references string) {
+	c := &s.columns
+
+	ids := nntp.ParseReferences(references)
+	values := make([]string, len(ids))
+
+	for idx := range ids {
+		values[idx] = string(ids[idx])
+	}
+
+	// Keep references which can't be restored from their message-ids as they are
+	if strings.Join(values, " ") != references {
+		if c.RawReferences == nil {
+			c.RawReferences = map[uint32]string{}
+		}
+
+		c.RawReferences[uint32(row)] = references
+		c.References.add()
+
+		return
+	}
+
+	list := make([]uint32, len(values))
+	for idx := range values {
+		list[idx] = c.IDs.id(values[idx])
+	}
+
+	c.References.add(list...)
+}
+
+func (s *Store) appendAdditional(additional map[string]string) {
+	c := &s.columns
+
+	keys := make([]string, 0, len(additional))
+	for key := range additional {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	list := make([]uint32, len(keys))
+	for idx, key := range keys {
+		list[idx] = c.AdditionalKeys.id(key)
+		c.AdditionalValues.add(additional[key])
+	}
+
+	c.Additional.add(list...)
+}
+
+// Len returns the number of headers.
+func (s *Store) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.columns.Numbers.len()
+}
+
+// Header returns the header with the given index, in the order they have been appended.
+func (s *Store) Header(idx int) (nntp.Header, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if idx < 0 || idx >= s.columns.Numbers.len() {
+		return nntp.Header{}, fmt.Errorf("%w: %d", ErrInvalidIndex, idx)
+	}
+
+	return s.header(idx), nil
+}
+
+func (s *Store) header(row int) nntp.Header {
+	c := &s.columns
+
+	header := nntp.Header{
+		MessageNumber:  uint64(c.Numbers.get(row)),
+		Subject:        c.Subjects.get(row),
+		DecodedSubject: c.DecodedSubjects.get(row),
+		Author:         c.Posters.value(c.Authors[row]),
+		DecodedAuthor:  c.Posters.value(c.DecodedAuthors[row]),
+		MessageID:      c.IDs.value(c.MessageIDs[row]),
+		Bytes:          uint64(c.Bytes.get(row)),
+		Lines:          uint64(c.Lines.get(row)),
+	}
+
+	if zoneID := c.ZoneIDs[row]; zoneID != 0 {
+		header.Date = time.Unix(c.Dates.get(row), 0).In(s.locations[zoneID-1])
+	}
+
+	if raw, ok := c.RawReferences[uint32(row)]; ok {
+		header.References = raw
+	} else {
+		start, end := c.References.span(row)
+		values := make([]string, 0, end-start)
+
+		for _, id := range c.References.Values[start:end] {
+			values = append(values, c.IDs.value(id))
+		}
+
+		header.References = strings.Join(values, " ")
+	}
+
+	start, end := c.Additional.span(row)
+	if start < end {
+		header.Additional = make(map[string]string, end-start)
+
+		for pos := start; pos < end; pos++ {
+			header.Additional[c.AdditionalKeys.value(c.Additional.Values[pos])] = c.AdditionalValues.get(pos)
+		}
+	}
+
+	return header
+}
+
+// Lookup returns the header with the given article number. If the number has been appended multiple times, the first
+// header is returned.
+func (s *Store) Lookup(number uint64) (nntp.Header, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rows := s.columns.Numbers.len()
+
+	if s.sorted {
+		row := sort.Search(rows, func(row int) bool {
+			return uint64(s.columns.Numbers.get(row)) >= number
+		})
+		if row < rows && uint64(s.columns.Numbers.get(row)) == number {
+			return s.header(row), true
+		}
+
+		return nntp.Header{}, false
+	}
+
+	if s.order == nil {
+		s.order = make([]uint32, rows)
+		for row := range s.order {
+			s.order[row] = uint32(row)
+		}
+
+		sort.SliceStable(s.order, func(i, j int) bool {
+			numbers := &s.columns.Numbers
+
+			return uint64(numbers.get(int(s.order[i]))) < uint64(numbers.get(int(s.order[j])))
+		})
+	}
+
+	idx := sort.Search(rows, func(idx int) bool {
+		return uint64(s.columns.Numbers.get(int(s.order[idx]))) >= number
+	})
+	if idx < rows && uint64(s.columns.Numbers.get(int(s.order[idx]))) == number {
+		return s.header(int(s.order[idx])), true
+	}
+
+	return nntp.Header{}, false
+}
+
+// Range calls fn for all headers in the order they have been appended, until fn returns false.
+// Headers appended while iterating are included.
+func (s *Store) Range(fn func(idx int, header nntp.Header) bool) {
+	for idx := 0; ; idx++ {
+		s.lock.Lock()
+		if idx >= s.columns.Numbers.len() {
+			s.lock.Unlock()
+			return
+		}
+
+		header := s.header(idx)
+		s.lock.Unlock()
+
+		if !fn(idx, header) {
+			return
+		}
+	}
+}
+
+// valid reports whether all ids & offsets are within range.
+func (c *columns) valid() bool {
+	for _, ids := range []struct {
+		values []uint32
+		max    int
+	}{
+		{values: c.Authors, max: c.Posters.Values.len()},
+		{values: c.DecodedAuthors, max: c.Posters.Values.len()},
+		{values: c.MessageIDs, max: c.IDs.Values.len()},
+		{values: c.References.Values, max: c.IDs.Values.len()},
+		{values: c.Additional.Values, max: c.AdditionalKeys.Values.len()},
+	} {
+		for _, id := range ids.values {
+			if int(id) >= ids.max {
+				return false
+			}
+		}
+	}
+
+	for _, id := range c.ZoneIDs {
+		if int(id) > len(c.Zones) {
+			return false
+		}
+	}
+
+	return c.Numbers.valid() && c.Dates.valid() && c.Bytes.valid() && c.Lines.valid() &&
+		c.Additional.valid() && c.References.valid() && c.AdditionalValues.valid() &&
+		c.Subjects.valid() && c.DecodedSubjects.valid() && c.Posters.Values.valid() && c.IDs.Values.valid() &&
+		c.AdditionalKeys.Values.valid() && c.AdditionalValues.len() == len(c.Additional.Values)
+}
+
+// Identifies store files & their version
+const fileMagic = "nntp header store 1\n"
+
+// Save atomically writes the store to a single file.
+func (s *Store) Save(path string) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if err := s.write(w); err != nil {
+			return fmt.Errorf("failed to write store: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) write(w io.Writer) error {
+	if _, err := io.WriteString(w, fileMagic); err != nil {
+		return err
+	}
+
+	return gob.NewEncoder(w).Encode(&s.columns)
+}
+
+// Load reads a store written by Save.
+func Load(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != fileMagic {
+		return nil, fmt.Errorf("%w: '%s' is no store file", ErrInvalidFile, path)
+	}
+
+	s := New()
+	if err := gob.NewDecoder(r).Decode(&s.columns); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// restore validates the loaded columns & rebuilds the state which isn't persisted.
+func (s *Store) restore() error {
+	c := &s.columns
+	rows := c.Numbers.len()
+
+	for _, length := range []int{
+		c.Dates.len(), len(c.ZoneIDs), c.Subjects.len(), c.DecodedSubjects.len(), len(c.Authors),
+		len(c.DecodedAuthors), len(c.MessageIDs), c.References.Ends.len(), c.Bytes.len(), c.Lines.len(),
+		c.Additional.Ends.len(),
+	} {
+		if length != rows {
+			return fmt.Errorf("%w: columns differ in length", ErrInvalidFile)
+		}
+	}
+
+	if !c.valid() {
+		return fmt.Errorf("%w: references or offsets out of range", ErrInvalidFile)
+	}
+
+	for id, key := range c.Zones {
+		s.zoneIDs[key] = uint16(id + 1)
+		s.locations = append(s.locations, time.FixedZone(key.Name, key.Offset))
+	}
+
+	c.Posters.rebuild()
+	c.IDs.rebuild()
+	c.AdditionalKeys.rebuild()
+
+	for row := 0; row < rows; row++ {
+		number := uint64(c.Numbers.get(row))
+		if row > 0 && number < s.lastNumber {
+			s.sorted = false
+		}
+
+		s.lastNumber = number
+	}
+
+	return nil
+}
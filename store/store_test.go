@@ -0,0 +1,254 @@
+package store_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/store"
+)
+
+func testHeaders(count int) []nntp.Header {
+	headers := make([]nntp.Header, count)
+	start := time.Date(2020, 5, 10, 0, 32, 22, 0, time.FixedZone("", -5*3600))
+
+	for idx := range headers {
+		headers[idx] = nntp.Header{
+			MessageNumber: uint64(1000 + idx*3),
+			Subject:       fmt.Sprintf("subject %d", idx),
+			Author:        fmt.Sprintf("poster%d@example.com", idx%7),
+			Date:          start.Add(time.Duration(idx) * time.Minute),
+			MessageID:     fmt.Sprintf("<%d@example.com>", idx),
+			Bytes:         uint64(idx * 1000),
+			Lines:         uint64(idx),
+		}
+
+		if idx > 0 {
+			headers[idx].References = fmt.Sprintf("<%d@example.com> <%d@example.com>", 0, idx-1)
+		}
+
+		if idx%2 == 0 {
+			headers[idx].Additional = map[string]string{
+				"Xref":       fmt.Sprintf("news.example.com misc.test:%d", idx),
+				"Newsgroups": "misc.test",
+			}
+		}
+	}
+
+	return headers
+}
+
+func TestStore(t *testing.T) {
+	headers := testHeaders(2500)
+
+	// Special cases
+	headers[3].Date = time.Time{}
+	headers[4].Date = time.Date(1970, 1, 1, 0, 0, 0, 0, time.FixedZone("EDT", -4*3600))
+	headers[5].Date = time.Date(2150, 1, 1, 0, 0, 0, 0, time.FixedZone("", 0))
+	headers[6].References = "broken  <reference>"
+	headers[7].DecodedSubject = "decoded"
+	headers[7].DecodedAuthor = "decoded author"
+
+	s := store.New()
+	require.NoError(t, s.Append(headers[:1000]...))
+
+	ch := make(chan nntp.Header)
+	go func() {
+		defer close(ch)
+
+		for _, header := range headers[1000:] {
+			ch <- header
+		}
+	}()
+	require.NoError(t, s.AppendChan(ch, nil))
+
+	assertStore := func(t *testing.T, s *store.Store) {
+		require.Equal(t, len(headers), s.Len())
+
+		for idx := range headers {
+			header, err := s.Header(idx)
+			require.NoError(t, err)
+			require.Equal(t, headers[idx], header, "Header %d differs", idx)
+		}
+
+		header, ok := s.Lookup(headers[1234].MessageNumber)
+		require.True(t, ok, "Failed to lookup header")
+		assert.Equal(t, headers[1234], header)
+
+		_, ok = s.Lookup(headers[1234].MessageNumber + 1)
+		assert.False(t, ok, "Lookup of unknown number must fail")
+
+		var count int
+		s.Range(func(idx int, header nntp.Header) bool {
+			count++
+			return idx < 9
+		})
+		assert.Equal(t, 10, count, "Range must stop when fn returns false")
+
+		_, err := s.Header(len(headers))
+		assert.True(t, errors.Is(err, store.ErrInvalidIndex), "Expected ErrInvalidIndex, got %v", err)
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		assertStore(t, s)
+	})
+
+	path := filepath.Join(t.TempDir(), "headers.store")
+	require.NoError(t, s.Save(path), "Failed to save store")
+
+	t.Run("loaded", func(t *testing.T) {
+		loaded, err := store.Load(path)
+		require.NoError(t, err, "Failed to load store")
+
+		assertStore(t, loaded)
+
+		// Dictionaries must be usable after loading
+		require.NoError(t, loaded.Append(headers[0]))
+		header, err := loaded.Header(len(headers))
+		require.NoError(t, err)
+		assert.Equal(t, headers[0], header)
+	})
+}
+
+func TestStore_LookupUnsorted(t *testing.T) {
+	s := store.New()
+	require.NoError(t, s.Append(
+		nntp.Header{MessageNumber: 30, Subject: "30"},
+		nntp.Header{MessageNumber: 10, Subject: "10"},
+		nntp.Header{MessageNumber: 20, Subject: "20"},
+		nntp.Header{MessageNumber: 10, Subject: "10 again"},
+	))
+
+	for _, number := range []uint64{10, 20, 30} {
+		header, ok := s.Lookup(number)
+		require.True(t, ok, "Failed to lookup %d", number)
+		assert.Equal(t, fmt.Sprint(number), header.Subject, "Expected the first header with number %d", number)
+	}
+
+	_, ok := s.Lookup(15)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Append(nntp.Header{MessageNumber: 15, Subject: "15"}))
+
+	header, ok := s.Lookup(15)
+	require.True(t, ok, "Lookup must include headers appended after the last lookup")
+	assert.Equal(t, "15", header.Subject)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.store")
+	require.NoError(t, ioutil.WriteFile(path, []byte("no store"), 0o600))
+
+	_, err := store.Load(path)
+	assert.True(t, errors.Is(err, store.ErrInvalidFile), "Expected ErrInvalidFile, got %v", err)
+}
+
+func TestStore_AppendChan_Errors(t *testing.T) {
+	headers := make(chan nntp.Header)
+	errs := make(chan error)
+	failed := errors.New("failed to parse line")
+
+	// Sends like nntp.Client.XoverChan, blocking until each value is received
+	go func() {
+		defer close(headers)
+		defer close(errs)
+
+		headers <- nntp.Header{MessageNumber: 1}
+		errs <- failed
+		headers <- nntp.Header{MessageNumber: 2}
+		errs <- errors.New("another error")
+	}()
+
+	s := store.New()
+	err := s.AppendChan(headers, errs)
+	assert.True(t, errors.Is(err, failed), "Expected the first error, got %v", err)
+	assert.Equal(t, 1, s.Len(), "Headers after the first error must be drained, but not added")
+}
+
+// Mirrors of the persisted columns, used to corrupt store files
+type (
+	testDeltaColumn struct {
+		Bases    []int64
+		Deltas   []int32
+		Overflow map[uint32]int64
+	}
+	testStringColumn struct {
+		Data []byte
+		Ends testDeltaColumn
+	}
+	testListColumn struct {
+		Values []uint32
+		Ends   testDeltaColumn
+	}
+	testDictionary struct {
+		Values testStringColumn
+	}
+	testColumns struct {
+		Numbers testDeltaColumn
+		Dates   testDeltaColumn
+		ZoneIDs []uint16
+		Zones   []struct {
+			Name   string
+			Offset int
+		}
+		Subjects         testStringColumn
+		DecodedSubjects  testStringColumn
+		Authors          []uint32
+		DecodedAuthors   []uint32
+		Posters          testDictionary
+		MessageIDs       []uint32
+		References       testListColumn
+		IDs              testDictionary
+		RawReferences    map[uint32]string
+		Bytes            testDeltaColumn
+		Lines            testDeltaColumn
+		Additional       testListColumn
+		AdditionalValues testStringColumn
+		AdditionalKeys   testDictionary
+	}
+)
+
+func TestLoad_MissingBases(t *testing.T) {
+	s := store.New()
+	require.NoError(t, s.Append(testHeaders(3)...))
+
+	path := filepath.Join(t.TempDir(), "headers.store")
+	require.NoError(t, s.Save(path), "Failed to save store")
+
+	saved, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	magic := saved[:bytes.IndexByte(saved, '\n')+1]
+
+	for name, corrupt := range map[string]func(c *testColumns){
+		"numbers": func(c *testColumns) { c.Numbers.Bases = nil },
+		"dates":   func(c *testColumns) { c.Dates.Bases = nil },
+		"bytes":   func(c *testColumns) { c.Bytes.Bases = nil },
+		"lines":   func(c *testColumns) { c.Lines.Bases = nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var c testColumns
+			require.NoError(t, gob.NewDecoder(bytes.NewReader(saved[len(magic):])).Decode(&c))
+
+			corrupt(&c)
+
+			buf := bytes.NewBuffer(append([]byte(nil), magic...))
+			require.NoError(t, gob.NewEncoder(buf).Encode(&c))
+
+			corrupted := filepath.Join(t.TempDir(), "corrupted.store")
+			require.NoError(t, ioutil.WriteFile(corrupted, buf.Bytes(), 0o600))
+
+			_, err := store.Load(corrupted)
+			assert.True(t, errors.Is(err, store.ErrInvalidFile), "Expected ErrInvalidFile, got %v", err)
+		})
+	}
+}
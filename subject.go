@@ -0,0 +1,51 @@
+package nntp
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SubjectPart is the part counter of a multi article post, like the "(12/87)" in `"show.s01e02" yEnc (12/87)`.
+type SubjectPart struct {
+	Number int
+	Total  int
+}
+
+// Matches "(12/87)" & "[12/87]", optionally with spaces or "of" instead of the slash.
+var subjectPartRegexp = regexp.MustCompile(`[(\[]\s*(\d+)\s*(?:/|of)\s*(\d+)\s*[)\]]`)
+
+// ParseSubjectPart returns the last part counter of the subject. Earlier counters usually number the files of a
+// collection instead of the articles of a file.
+func ParseSubjectPart(subject string) (part SubjectPart, ok bool) {
+	matches := subjectPartRegexp.FindAllStringSubmatch(subject, -1)
+	if len(matches) == 0 {
+		return part, false
+	}
+
+	last := matches[len(matches)-1]
+
+	var err error
+	if part.Number, err = strconv.Atoi(last[1]); err != nil {
+		return part, false
+	}
+
+	if part.Total, err = strconv.Atoi(last[2]); err != nil {
+		return part, false
+	}
+
+	return part, true
+}
+
+// StripSubjectPart removes the last part counter from the subject. All articles of a multi article post share the
+// resulting subject.
+func StripSubjectPart(subject string) string {
+	locations := subjectPartRegexp.FindAllStringIndex(subject, -1)
+	if len(locations) == 0 {
+		return subject
+	}
+
+	last := locations[len(locations)-1]
+
+	return strings.TrimSpace(subject[:last[0]] + subject[last[1]:])
+}
@@ -0,0 +1,52 @@
+package nntp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestParseSubjectPart(t *testing.T) {
+	tests := []struct {
+		subject      string
+		expectedPart nntp.SubjectPart
+		expectedOK   bool
+	}{
+		{
+			subject:      `"show.s01e02.mkv" yEnc (12/87)`,
+			expectedPart: nntp.SubjectPart{Number: 12, Total: 87},
+			expectedOK:   true,
+		},
+		{
+			subject:      `[01/10] - "show.s01e02.part01.rar" yEnc (1/50)`,
+			expectedPart: nntp.SubjectPart{Number: 1, Total: 50},
+			expectedOK:   true,
+		},
+		{
+			subject:      `picture.jpg [2 of 3]`,
+			expectedPart: nntp.SubjectPart{Number: 2, Total: 3},
+			expectedOK:   true,
+		},
+		{
+			subject:      `some discussion (was: other discussion)`,
+			expectedPart: nntp.SubjectPart{},
+			expectedOK:   false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.subject, func(t *testing.T) {
+			part, ok := nntp.ParseSubjectPart(test.subject)
+			assert.Equal(t, test.expectedOK, ok)
+			assert.Equal(t, test.expectedPart, part)
+		})
+	}
+}
+
+func TestStripSubjectPart(t *testing.T) {
+	assert.Equal(t, `[01/10] - "show.s01e02.part01.rar" yEnc`, nntp.StripSubjectPart(`[01/10] - "show.s01e02.part01.rar" yEnc (1/50)`))
+	assert.Equal(t, `some discussion`, nntp.StripSubjectPart(`some discussion`))
+}
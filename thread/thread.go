@@ -0,0 +1,317 @@
+// Package thread threads overview headers into conversations using the JWZ algorithm.
+// See https://www.jwz.org/doc/threading.html
+package thread
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+)
+
+// Node is a message within a thread.
+type Node struct {
+	// Nil for messages which are referenced but have not been seen, like expired articles.
+	// Their children are kept together below the empty node.
+	Header *nntp.Header
+	// Empty for nodes gathering threads with the same subject
+	MessageID nntp.MessageID
+	Children  []*Node
+}
+
+// Date returns the date of the message. Empty nodes use the date of their oldest child.
+func (n *Node) Date() time.Time {
+	if n.Header != nil {
+		return n.Header.Date
+	}
+
+	var date time.Time
+
+	for _, child := range n.Children {
+		if childDate := child.Date(); date.IsZero() || childDate.Before(date) {
+			date = childDate
+		}
+	}
+
+	return date
+}
+
+// Subject returns the subject of the message. Empty nodes use the subject of their first child.
+func (n *Node) Subject() string {
+	if n.Header != nil {
+		return n.Header.Subject
+	}
+
+	for _, child := range n.Children {
+		if subject := child.Subject(); subject != "" {
+			return subject
+		}
+	}
+
+	return ""
+}
+
+// Walk calls fn for the node & all its descendants, depth first. The depth of the node itself is 0.
+func (n *Node) Walk(fn func(node *Node, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(node *Node, depth int), depth int) {
+	fn(n, depth)
+
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Count returns the number of messages within the thread, not counting empty nodes.
+func (n *Node) Count() (count int) {
+	n.Walk(func(node *Node, _ int) {
+		if node.Header != nil {
+			count++
+		}
+	})
+
+	return count
+}
+
+type container struct {
+	id       nntp.MessageID
+	header   *nntp.Header
+	parent   *container
+	children []*container
+}
+
+// hasDescendant reports whether other is c or one of its descendants.
+func (c *container) hasDescendant(other *container) bool {
+	for ; other != nil; other = other.parent {
+		if other == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *container) setParent(parent *container) {
+	if c.parent == parent {
+		return
+	}
+
+	if c.parent != nil {
+		siblings := c.parent.children
+		for idx := range siblings {
+			if siblings[idx] == c {
+				c.parent.children = append(siblings[:idx], siblings[idx+1:]...)
+				break
+			}
+		}
+	}
+
+	c.parent = parent
+
+	if parent != nil {
+		parent.children = append(parent.children, c)
+	}
+}
+
+// Tree threads overview headers. Headers can be added at any time, like after each Xover call, without rebuilding the
+// links between the already added messages. Messages referencing a message which gets added later are moved below it.
+// All methods are safe for concurrent use.
+type Tree struct {
+	lock       sync.Mutex
+	containers map[nntp.MessageID]*container
+	messages   int
+}
+
+func New() *Tree {
+	return &Tree{
+		containers: map[nntp.MessageID]*container{},
+	}
+}
+
+// Add threads the given headers. Headers with an already known message-id are ignored, so overlapping ranges can be
+// added.
+func (t *Tree) Add(headers ...nntp.Header) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for idx := range headers {
+		t.add(headers[idx])
+	}
+}
+
+// Len returns the number of added messages.
+func (t *Tree) Len() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.messages
+}
+
+func (t *Tree) add(header nntp.Header) {
+	id := nntp.MessageID(strings.TrimSpace(header.MessageID))
+	if id == "" {
+		return
+	}
+
+	c := t.container(id)
+	if c.header != nil {
+		return
+	}
+
+	c.header = &header
+	t.messages++
+
+	// Link the references to each other, without overriding links known from the messages themselves
+	var parent *container
+
+	for _, reference := range header.ReferenceIDs() {
+		ref := t.container(reference)
+
+		if parent != nil && ref.parent == nil && !ref.hasDescendant(parent) {
+			ref.setParent(parent)
+		}
+
+		parent = ref
+	}
+
+	// The own references of a message are authoritative
+	if parent != nil && c.hasDescendant(parent) {
+		parent = nil
+	}
+
+	c.setParent(parent)
+}
+
+func (t *Tree) container(id nntp.MessageID) *container {
+	c, ok := t.containers[id]
+	if !ok {
+		c = &container{id: id}
+		t.containers[id] = c
+	}
+
+	return c
+}
+
+// Threads returns the current threads, sorted by date. Empty nodes without children are pruned & empty nodes with a
+// single child are replaced by the child. Threads without common references but with the same subject are gathered
+// below each other or a common empty node.
+func (t *Tree) Threads() []*Node {
+	t.lock.Lock()
+
+	var roots []*Node
+
+	for _, c := range t.containers {
+		if c.parent == nil {
+			roots = append(roots, prune(c, true)...)
+		}
+	}
+
+	t.lock.Unlock()
+
+	// Sort first, so gathering does not depend on the map order
+	sortNodes(roots)
+	roots = gatherSubjects(roots)
+	sortNodes(roots)
+
+	return roots
+}
+
+// prune converts the container into nodes, dropping empty containers wherever possible.
+func prune(c *container, root bool) []*Node {
+	var children []*Node
+	for _, child := range c.children {
+		children = append(children, prune(child, false)...)
+	}
+
+	if c.header != nil {
+		return []*Node{{Header: c.header, MessageID: c.id, Children: children}}
+	}
+
+	// Promote the children of empty containers, except if that would split a thread into multiple threads
+	if len(children) == 0 || !root || len(children) == 1 {
+		return children
+	}
+
+	return []*Node{{MessageID: c.id, Children: children}}
+}
+
+var replyPrefixRegexp = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv)\s*(\[\d+\])?\s*:\s*)+`)
+
+// baseSubject removes reply prefixes like "Re:" from the subject.
+func baseSubject(subject string) (base string, reply bool) {
+	base = replyPrefixRegexp.ReplaceAllString(subject, "")
+
+	return strings.ToLower(strings.TrimSpace(base)), len(base) != len(subject)
+}
+
+func gatherSubjects(roots []*Node) []*Node {
+	subjects := map[string]*Node{}
+
+	// Prefer empty nodes, then non-replies as the node others get gathered below
+	for _, root := range roots {
+		subject, reply := baseSubject(root.Subject())
+		if subject == "" {
+			continue
+		}
+
+		existing, ok := subjects[subject]
+		if !ok || (existing.Header != nil && root.Header == nil) {
+			subjects[subject] = root
+			continue
+		}
+
+		_, existingReply := baseSubject(existing.Subject())
+		if existing.Header != nil && existingReply && !reply {
+			subjects[subject] = root
+		}
+	}
+
+	var gathered []*Node
+
+	for _, root := range roots {
+		subject, reply := baseSubject(root.Subject())
+
+		target, ok := subjects[subject]
+		if subject == "" || !ok || target == root {
+			gathered = append(gathered, root)
+			continue
+		}
+
+		_, targetReply := baseSubject(target.Subject())
+
+		switch {
+		case target.Header == nil && root.Header == nil:
+			target.Children = append(target.Children, root.Children...)
+		case target.Header == nil:
+			target.Children = append(target.Children, root)
+		case !targetReply && reply:
+			target.Children = append(target.Children, root)
+		default:
+			// Neither is the obvious parent, so both become siblings below a new empty node
+			sibling := &Node{Header: target.Header, MessageID: target.MessageID, Children: target.Children}
+			target.Header, target.MessageID, target.Children = nil, "", []*Node{sibling, root}
+		}
+	}
+
+	return gathered
+}
+
+func sortNodes(nodes []*Node) {
+	for _, node := range nodes {
+		sortNodes(node.Children)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		di, dj := nodes[i].Date(), nodes[j].Date()
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+
+		return nodes[i].MessageID < nodes[j].MessageID
+	})
+}
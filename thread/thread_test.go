@@ -0,0 +1,126 @@
+package thread_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/thread"
+)
+
+var start = time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func header(id string, minute int, subject string, references ...string) nntp.Header {
+	return nntp.Header{
+		MessageID:  "<" + id + ">",
+		Subject:    subject,
+		Date:       start.Add(time.Duration(minute) * time.Minute),
+		References: strings.Join(references, " "),
+	}
+}
+
+// render returns one line per node, indented by depth. Empty nodes are shown as "-".
+func render(threads []*thread.Node) string {
+	var b strings.Builder
+
+	for _, root := range threads {
+		root.Walk(func(node *thread.Node, depth int) {
+			b.WriteString(strings.Repeat("  ", depth))
+
+			if node.Header == nil {
+				b.WriteString("-\n")
+				return
+			}
+
+			b.WriteString(node.MessageID.Bare() + "\n")
+		})
+	}
+
+	return b.String()
+}
+
+func TestTree(t *testing.T) {
+	tree := thread.New()
+	tree.Add(
+		header("a", 0, "Question"),
+		header("c", 2, "Re: Question", "<a>", "<b>"),
+		header("d", 3, "Re: Question", "<a>"),
+		header("e", 1, "Re: Question", "<a>", "<b>", "<c>"),
+		header("x", 5, "Other topic"),
+		header("d", 3, "Re: Question", "<a>"),
+	)
+
+	assert.Equal(t, 5, tree.Len())
+
+	// b has never been seen. As it has a single child, c takes its place.
+	assert.Equal(t, `a
+  c
+    e
+  d
+x
+`, render(tree.Threads()))
+
+	threads := tree.Threads()
+	require.Len(t, threads, 2)
+	assert.Equal(t, 4, threads[0].Count())
+	assert.Equal(t, start, threads[0].Date())
+}
+
+func TestTree_Incremental(t *testing.T) {
+	tree := thread.New()
+	tree.Add(
+		header("c", 2, "Re: Question", "<a>", "<b>"),
+		header("d", 3, "Re: Question", "<a>", "<b>"),
+	)
+
+	// The parents have not been seen yet. The empty root keeps both replies together.
+	assert.Equal(t, `-
+  c
+  d
+`, render(tree.Threads()))
+
+	tree.Add(header("b", 1, "Re: Question", "<a>"))
+	tree.Add(header("a", 0, "Question"))
+
+	assert.Equal(t, `a
+  b
+    c
+    d
+`, render(tree.Threads()))
+}
+
+func TestTree_Loops(t *testing.T) {
+	tree := thread.New()
+	tree.Add(
+		header("a", 0, "Loop", "<b>"),
+		header("b", 1, "Re: Loop", "<a>"),
+		header("c", 2, "Re: Loop", "<c>"),
+	)
+
+	threads := tree.Threads()
+	require.Len(t, threads, 1)
+	assert.Equal(t, 3, threads[0].Count())
+}
+
+func TestTree_GatherSubjects(t *testing.T) {
+	tree := thread.New()
+	tree.Add(
+		header("reply", 1, "Re: Topic", "<expired>"),
+		header("topic", 0, "Topic"),
+		header("second", 2, "Topic"),
+		header("fwd", 3, "Fwd: RE: Unrelated"),
+	)
+
+	// The reply lost its parent & gets gathered below the original post by subject.
+	// Two posts with the same subject which are no replies become siblings below an empty node.
+	assert.Equal(t, `-
+  topic
+    reply
+  second
+fwd
+`, render(tree.Threads()))
+}
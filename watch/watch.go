@@ -0,0 +1,384 @@
+// Package watch polls the water marks of groups over a pool of clients & reports their changes as events.
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/mrincompetent/nntp"
+)
+
+const (
+	// DefaultInterval is the default time between two polls of a group.
+	DefaultInterval = 5 * time.Minute
+	// DefaultStatusInterval is the default time between two posting status checks of a group.
+	DefaultStatusInterval = time.Hour
+	// DefaultMinBackoff is the default time a group waits after its first failed poll.
+	DefaultMinBackoff = 10 * time.Second
+	// DefaultMaxBackoff is the default upper limit of the time a group waits after consecutive failed polls.
+	DefaultMaxBackoff = 10 * time.Minute
+)
+
+// Client is the subset of nntp.Client used by the Watcher.
+type Client interface {
+	Group(name string) (nntp.NewsgroupDetail, error)
+	ListActive(wildmat string) ([]nntp.NewsgroupOverview, error)
+}
+
+type EventType string
+
+const (
+	// Articles have been added to the group
+	EventNewArticles EventType = "new-articles"
+	// The low water mark advanced, so the oldest articles expired
+	EventExpired EventType = "expired"
+	// The group no longer exists on the server
+	EventGroupGone EventType = "group-gone"
+	// The posting status of the group changed
+	EventStatusChanged EventType = "status-changed"
+	// A poll failed & the group backs off before its next poll
+	EventError EventType = "error"
+)
+
+type Event struct {
+	Type  EventType
+	Group string
+	// Range of article numbers added for EventNewArticles, or expired for EventExpired
+	First uint64
+	Last  uint64
+	// Only set for EventStatusChanged
+	OldStatus nntp.NewsgroupStatus
+	Status    nntp.NewsgroupStatus
+	// Only set for EventError: The error of the poll & the time until the next poll of the group
+	Err     error
+	Backoff time.Duration
+	// Water marks reported by the poll, unset for EventGroupGone & EventError
+	Detail nntp.NewsgroupDetail
+	// Time of the poll
+	Time time.Time
+}
+
+var ErrNoClients = errors.New("no clients configured")
+
+// Watcher polls GROUP for a set of groups. Every client polls one group at a time, so each client should use its own
+// connection. The first successful poll of a group only records its water marks, later polls emit the changes.
+// If the high water mark drops, the server renumbered the group & its water marks are recorded again without events.
+type Watcher struct {
+	// Time between two polls of a group. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Time between two LIST ACTIVE requests of a group, used to detect posting status changes. Defaults to
+	// DefaultStatusInterval. Zero disables status checks. A failed status check doesn't fail the poll, it's retried
+	// after the interval.
+	StatusInterval time.Duration
+	// A group is polled again MinBackoff after a failed poll, doubling with every consecutive failure of the group up
+	// to MaxBackoff. Meanwhile the client polls the other groups. Default to DefaultMinBackoff & DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	clients []Client
+
+	lock   sync.Mutex
+	groups []*groupState
+	// Wakes idle clients once a polled group is available again
+	wake chan struct{}
+}
+
+type groupState struct {
+	name string
+	// Time of the next poll
+	next time.Time
+	// Whether a client currently polls the group
+	busy bool
+	// Number of consecutive failed polls
+	failures int
+
+	// Whether the water marks of the group have been recorded
+	known  bool
+	detail nntp.NewsgroupDetail
+	gone   bool
+
+	status        nntp.NewsgroupStatus
+	statusChecked time.Time
+}
+
+func New(groups []string, clients ...Client) *Watcher {
+	w := &Watcher{
+		Interval:       DefaultInterval,
+		StatusInterval: DefaultStatusInterval,
+		MinBackoff:     DefaultMinBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		clients:        clients,
+	}
+
+	for _, group := range groups {
+		w.groups = append(w.groups, &groupState{name: group})
+	}
+
+	return w
+}
+
+// Run polls the groups until the context gets cancelled & sends the resulting events to the channel, which must be
+// drained. It returns the error of the context. The recorded water marks are kept, so another run only reports the
+// changes since the last one.
+func (w *Watcher) Run(ctx context.Context, events chan<- Event) error {
+	if len(w.clients) == 0 {
+		return ErrNoClients
+	}
+
+	w.lock.Lock()
+	w.wake = make(chan struct{}, len(w.clients))
+	w.lock.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, client := range w.clients {
+		wg.Add(1)
+
+		go func(client Client) {
+			defer wg.Done()
+
+			w.watch(ctx, client, events)
+		}(client)
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// watch polls due groups with the client until the context gets cancelled.
+func (w *Watcher) watch(ctx context.Context, client Client, events chan<- Event) {
+	for ctx.Err() == nil {
+		group, wait := w.take(time.Now())
+		if group == nil {
+			w.sleep(ctx, wait, w.wake)
+
+			continue
+		}
+
+		polled, pollEvents, err := w.poll(client, *group, time.Now())
+		if err != nil {
+			polled.failures++
+			backoff := w.backoff(polled.failures)
+
+			// The group is retried after the backoff, even if another client is idle
+			w.release(group, polled, time.Now().Add(backoff))
+
+			if !emit(ctx, events, Event{Type: EventError, Group: group.name, Err: err, Backoff: backoff, Time: time.Now()}) {
+				return
+			}
+
+			continue
+		}
+
+		polled.failures = 0
+
+		w.release(group, polled, time.Now().Add(w.interval()))
+
+		for _, event := range pollEvents {
+			if !emit(ctx, events, event) {
+				return
+			}
+		}
+	}
+}
+
+// take marks the group due the earliest as busy & returns it. If no group is due yet, the time until the next one is
+// returned.
+func (w *Watcher) take(now time.Time) (*groupState, time.Duration) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var next *groupState
+
+	for _, group := range w.groups {
+		if !group.busy && (next == nil || group.next.Before(next.next)) {
+			next = group
+		}
+	}
+
+	if next == nil {
+		// All groups are being polled, wait for one to be released
+		return nil, w.interval()
+	}
+
+	if next.next.After(now) {
+		return nil, next.next.Sub(now)
+	}
+
+	next.busy = true
+
+	return next, 0
+}
+
+// release stores the polled state of the group & schedules its next poll.
+func (w *Watcher) release(group *groupState, polled groupState, next time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	*group = polled
+	group.busy = false
+	group.next = next
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// poll selects the group & compares the result with the recorded state.
+func (w *Watcher) poll(client Client, group groupState, now time.Time) (groupState, []Event, error) {
+	detail, err := client.Group(group.name)
+
+	// 411: No such newsgroup
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == 411 {
+		var events []Event
+		if !group.gone {
+			events = append(events, Event{Type: EventGroupGone, Group: group.name, Time: now})
+		}
+
+		// Reappearing groups are recorded from scratch
+		group.gone, group.known, group.status = true, false, ""
+
+		return group, events, nil
+	}
+
+	if err != nil {
+		return group, nil, err
+	}
+
+	group.gone = false
+
+	var events []Event
+
+	if w.StatusInterval > 0 && now.Sub(group.statusChecked) >= w.StatusInterval {
+		// Not every server supports LIST ACTIVE, so the GROUP result is kept without the status
+		status, err := w.status(client, group.name)
+		if err != nil {
+			status = group.status
+		}
+
+		if status != "" && group.status != "" && status != group.status {
+			events = append(events, Event{
+				Type:      EventStatusChanged,
+				Group:     group.name,
+				OldStatus: group.status,
+				Status:    status,
+				Detail:    detail,
+				Time:      now,
+			})
+		}
+
+		group.status, group.statusChecked = status, now
+	}
+
+	previous := group.detail
+	group.detail = detail
+
+	if !group.known || detail.High < previous.High {
+		group.known = true
+
+		return group, events, nil
+	}
+
+	if detail.High > previous.High {
+		events = append(events, Event{
+			Type:   EventNewArticles,
+			Group:  group.name,
+			First:  previous.High + 1,
+			Last:   detail.High,
+			Detail: detail,
+			Time:   now,
+		})
+	}
+
+	// Empty groups may report a low water mark of high + 1, which doesn't expire anything
+	if detail.Low > previous.Low && previous.Low <= previous.High {
+		last := detail.Low - 1
+		if last > previous.High {
+			last = previous.High
+		}
+
+		events = append(events, Event{
+			Type:   EventExpired,
+			Group:  group.name,
+			First:  previous.Low,
+			Last:   last,
+			Detail: detail,
+			Time:   now,
+		})
+	}
+
+	return group, events, nil
+}
+
+// status returns the posting status of the group listed by LIST ACTIVE, empty if it's not listed.
+func (w *Watcher) status(client Client, group string) (nntp.NewsgroupStatus, error) {
+	groups, err := client.ListActive(group)
+	if err != nil {
+		return "", err
+	}
+
+	for _, listed := range groups {
+		if listed.Name == group {
+			return listed.Status, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (w *Watcher) interval() time.Duration {
+	if w.Interval <= 0 {
+		return DefaultInterval
+	}
+
+	return w.Interval
+}
+
+// backoff returns the time to wait after the given number of consecutive failures.
+func (w *Watcher) backoff(failures int) time.Duration {
+	backoff, limit := w.MinBackoff, w.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultMinBackoff
+	}
+
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+
+	for i := 1; i < failures && backoff < limit; i++ {
+		backoff *= 2
+	}
+
+	if backoff > limit {
+		backoff = limit
+	}
+
+	return backoff
+}
+
+// sleep waits for the duration, a wake up or the cancellation of the context.
+func (w *Watcher) sleep(ctx context.Context, d time.Duration, wake <-chan struct{}) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	case <-wake:
+	}
+}
+
+func emit(ctx context.Context, events chan<- Event, event Event) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case events <- event:
+		return true
+	}
+}
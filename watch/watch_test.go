@@ -0,0 +1,338 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+	"github.com/mrincompetent/nntp/watch"
+)
+
+var _ watch.Client = (*nntp.Client)(nil)
+
+type response struct {
+	low, high uint64
+	status    nntp.NewsgroupStatus
+	err       error
+	// Error of the LIST ACTIVE request
+	statusErr error
+}
+
+// fakeServer serves scripted responses per group. The last response of a group is repeated.
+type fakeServer struct {
+	lock      sync.Mutex
+	responses map[string][]response
+	polls     map[string]int
+	busy      map[string]bool
+	t         *testing.T
+}
+
+func newFakeServer(t *testing.T, responses map[string][]response) *fakeServer {
+	return &fakeServer{responses: responses, polls: map[string]int{}, busy: map[string]bool{}, t: t}
+}
+
+// fakeClient is a single connection to the fake server, remembering the selected group.
+type fakeClient struct {
+	server   *fakeServer
+	selected response
+}
+
+func (c *fakeClient) Group(name string) (nntp.NewsgroupDetail, error) {
+	s := c.server
+
+	s.lock.Lock()
+	if s.busy[name] {
+		s.t.Errorf("Group '%s' polled concurrently", name)
+	}
+
+	s.busy[name] = true
+
+	responses := s.responses[name]
+	idx := s.polls[name]
+	if idx >= len(responses) {
+		idx = len(responses) - 1
+	}
+
+	s.polls[name]++
+	s.lock.Unlock()
+
+	// Give other clients the chance to poll the group at the same time
+	time.Sleep(time.Millisecond)
+
+	s.lock.Lock()
+	s.busy[name] = false
+	s.lock.Unlock()
+
+	c.selected = responses[idx]
+	if c.selected.err != nil {
+		return nntp.NewsgroupDetail{}, c.selected.err
+	}
+
+	return nntp.NewsgroupDetail{Name: name, Low: c.selected.low, High: c.selected.high}, nil
+}
+
+func (c *fakeClient) ListActive(wildmat string) ([]nntp.NewsgroupOverview, error) {
+	if c.selected.statusErr != nil {
+		return nil, c.selected.statusErr
+	}
+
+	return []nntp.NewsgroupOverview{
+		{Name: wildmat, Low: c.selected.low, High: c.selected.high, Status: c.selected.status},
+	}, nil
+}
+
+func newWatcher(groups []string, server *fakeServer, clients int) *watch.Watcher {
+	pool := make([]watch.Client, clients)
+	for idx := range pool {
+		pool[idx] = &fakeClient{server: server}
+	}
+
+	w := watch.New(groups, pool...)
+	w.Interval = time.Millisecond
+	w.StatusInterval = time.Nanosecond
+	w.MinBackoff = 5 * time.Millisecond
+	w.MaxBackoff = 15 * time.Millisecond
+
+	return w
+}
+
+// collect runs the watcher until n events have been received.
+func collect(t *testing.T, w *watch.Watcher, n int) []watch.Event {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan watch.Event)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- w.Run(ctx, events)
+	}()
+
+	var received []watch.Event
+
+	for len(received) < n {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-ctx.Done():
+			t.Fatalf("Received only %d of %d events: %+v", len(received), n, received)
+		}
+	}
+
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	return received
+}
+
+func TestWatcher_Run(t *testing.T) {
+	server := newFakeServer(t, map[string][]response{
+		"misc.test": {
+			{low: 1, high: 10, status: nntp.NewsgroupStatusPostingPermitted},
+			{low: 1, high: 15, status: nntp.NewsgroupStatusPostingPermitted},
+			{low: 5, high: 15, status: nntp.NewsgroupStatusPostingModerated},
+			{err: &textproto.Error{Code: 411, Msg: "No such newsgroup"}},
+		},
+	})
+
+	events := collect(t, newWatcher([]string{"misc.test"}, server, 1), 4)
+
+	type summary struct {
+		Type        watch.EventType
+		First, Last uint64
+		Old, New    nntp.NewsgroupStatus
+	}
+
+	summaries := make([]summary, len(events))
+	for idx, event := range events {
+		assert.Equal(t, "misc.test", event.Group)
+		assert.False(t, event.Time.IsZero())
+
+		summaries[idx] = summary{
+			Type:  event.Type,
+			First: event.First,
+			Last:  event.Last,
+			Old:   event.OldStatus,
+			New:   event.Status,
+		}
+	}
+
+	assert.Equal(t, []summary{
+		{Type: watch.EventNewArticles, First: 11, Last: 15},
+		{
+			Type: watch.EventStatusChanged,
+			Old:  nntp.NewsgroupStatusPostingPermitted,
+			New:  nntp.NewsgroupStatusPostingModerated,
+		},
+		{Type: watch.EventExpired, First: 1, Last: 4},
+		{Type: watch.EventGroupGone},
+	}, summaries)
+	assert.Equal(t, uint64(15), events[0].Detail.High)
+}
+
+func TestWatcher_Run_Backoff(t *testing.T) {
+	unavailable := &textproto.Error{Code: 502, Msg: "Service unavailable"}
+
+	server := newFakeServer(t, map[string][]response{
+		"misc.test": {
+			{err: unavailable},
+			{err: unavailable},
+			{err: unavailable},
+			{low: 1, high: 10},
+			{low: 1, high: 12},
+		},
+	})
+
+	w := newWatcher([]string{"misc.test"}, server, 1)
+	w.StatusInterval = 0
+
+	start := time.Now()
+	events := collect(t, w, 4)
+
+	require.Len(t, events, 4)
+
+	for idx, backoff := range []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 15 * time.Millisecond} {
+		assert.Equal(t, watch.EventError, events[idx].Type)
+		assert.Equal(t, backoff, events[idx].Backoff)
+
+		var protoErr *textproto.Error
+		assert.True(t, errors.As(events[idx].Err, &protoErr) && protoErr.Code == 502, "Unexpected error %v", events[idx].Err)
+	}
+
+	assert.Equal(t, watch.EventNewArticles, events[3].Type)
+	assert.Equal(t, uint64(11), events[3].First)
+	assert.Equal(t, uint64(12), events[3].Last)
+	assert.True(t, time.Since(start) >= 30*time.Millisecond, "Expected the client to back off")
+}
+
+func TestWatcher_Run_Pool(t *testing.T) {
+	groups := []string{"alt.a", "alt.b", "alt.c", "alt.d", "alt.e"}
+
+	responses := map[string][]response{}
+	for _, group := range groups {
+		responses[group] = []response{{low: 1, high: 1}, {low: 1, high: 3}}
+	}
+
+	server := newFakeServer(t, responses)
+
+	events := collect(t, newWatcher(groups, server, 3), len(groups))
+
+	received := map[string]bool{}
+
+	for _, event := range events {
+		assert.Equal(t, watch.EventNewArticles, event.Type)
+		assert.Equal(t, uint64(2), event.First)
+		assert.Equal(t, uint64(3), event.Last)
+		assert.False(t, received[event.Group], "Received a second event for '%s'", event.Group)
+
+		received[event.Group] = true
+	}
+}
+
+func TestWatcher_Run_NoClients(t *testing.T) {
+	err := watch.New([]string{"misc.test"}).Run(context.Background(), make(chan watch.Event))
+	assert.True(t, errors.Is(err, watch.ErrNoClients))
+}
+
+func TestWatcher_Run_StatusError(t *testing.T) {
+	unknown := &textproto.Error{Code: 500, Msg: "Unknown command"}
+
+	server := newFakeServer(t, map[string][]response{
+		"misc.test": {
+			{low: 1, high: 10, status: nntp.NewsgroupStatusPostingPermitted},
+			{low: 1, high: 12, statusErr: unknown},
+			{low: 1, high: 14, status: nntp.NewsgroupStatusPostingModerated},
+		},
+	})
+
+	events := collect(t, newWatcher([]string{"misc.test"}, server, 1), 3)
+
+	assert.Equal(t, watch.EventNewArticles, events[0].Type)
+	assert.Equal(t, uint64(11), events[0].First)
+	assert.Equal(t, uint64(12), events[0].Last)
+
+	// The status before the failed check is kept
+	assert.Equal(t, watch.EventStatusChanged, events[1].Type)
+	assert.Equal(t, nntp.NewsgroupStatusPostingPermitted, events[1].OldStatus)
+	assert.Equal(t, nntp.NewsgroupStatusPostingModerated, events[1].Status)
+
+	assert.Equal(t, watch.EventNewArticles, events[2].Type)
+	assert.Equal(t, uint64(13), events[2].First)
+	assert.Equal(t, uint64(14), events[2].Last)
+}
+
+func TestWatcher_Run_BackoffPool(t *testing.T) {
+	unavailable := &textproto.Error{Code: 502, Msg: "Service unavailable"}
+
+	server := newFakeServer(t, map[string][]response{
+		"misc.test": {{err: unavailable}, {low: 1, high: 10}, {low: 1, high: 12}},
+	})
+
+	w := newWatcher([]string{"misc.test"}, server, 3)
+	w.MinBackoff = 20 * time.Millisecond
+
+	start := time.Now()
+	events := collect(t, w, 2)
+
+	assert.Equal(t, watch.EventError, events[0].Type)
+	assert.Equal(t, watch.EventNewArticles, events[1].Type)
+	// Idle clients wait for the backoff instead of retrying the failed group right away
+	assert.True(t, time.Since(start) >= 15*time.Millisecond, "Expected the group to be retried after the backoff")
+}
+
+func TestWatcher_Run_BackoffPerGroup(t *testing.T) {
+	unavailable := &textproto.Error{Code: 502, Msg: "Service unavailable"}
+
+	server := newFakeServer(t, map[string][]response{
+		"alt.a": {{err: unavailable}},
+		"alt.b": {{err: unavailable}},
+	})
+
+	w := newWatcher([]string{"alt.a", "alt.b"}, server, 1)
+	w.MaxBackoff = time.Second
+
+	backoffs := map[string][]time.Duration{}
+
+	for _, event := range collect(t, w, 4) {
+		assert.Equal(t, watch.EventError, event.Type)
+
+		backoffs[event.Group] = append(backoffs[event.Group], event.Backoff)
+	}
+
+	// The failures of one group don't increase the backoff of the other
+	expected := []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}
+	assert.Equal(t, map[string][]time.Duration{"alt.a": expected, "alt.b": expected}, backoffs)
+}
+
+func TestWatcher_Run_BackoffOtherGroups(t *testing.T) {
+	unavailable := &textproto.Error{Code: 502, Msg: "Service unavailable"}
+
+	server := newFakeServer(t, map[string][]response{
+		"alt.broken": {{err: unavailable}},
+		"alt.test":   {{low: 1, high: 1}, {low: 1, high: 2}, {low: 1, high: 3}, {low: 1, high: 4}},
+	})
+
+	w := newWatcher([]string{"alt.broken", "alt.test"}, server, 1)
+	w.StatusInterval = 0
+	w.MinBackoff = time.Second
+
+	start := time.Now()
+	events := collect(t, w, 4)
+
+	assert.Equal(t, watch.EventError, events[0].Type)
+
+	for _, event := range events[1:] {
+		assert.Equal(t, watch.EventNewArticles, event.Type)
+		assert.Equal(t, "alt.test", event.Group)
+	}
+
+	// The client keeps polling the other groups while the failed one backs off
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "Expected the client not to back off")
+}
@@ -0,0 +1,231 @@
+package nntp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Xref is the parsed value of an Xref header, like "news.example.com misc.test:3000234 alt.test:42".
+type Xref struct {
+	// Name of the server which assigned the article numbers
+	Server  string
+	Entries []XrefEntry
+}
+
+// XrefEntry is the article number of an article within a single group.
+type XrefEntry struct {
+	Group  string
+	Number uint64
+}
+
+var ErrInvalidXref = errors.New("invalid xref")
+
+// ParseXref parses the value of an Xref header. A leading "Xref:" (as sent for "Xref:full" overview fields) is removed.
+func ParseXref(s string) (xref Xref, err error) {
+	fields := strings.Fields(s)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "Xref:") {
+		fields = fields[1:]
+	}
+
+	if len(fields) < 2 {
+		return xref, fmt.Errorf("%w: '%s' must contain the server & at least one entry", ErrInvalidXref, s)
+	}
+
+	xref.Server = fields[0]
+
+	for _, field := range fields[1:] {
+		idx := strings.LastIndexByte(field, ':')
+		if idx <= 0 {
+			return xref, fmt.Errorf("%w: entry '%s' must be 'group:number'", ErrInvalidXref, field)
+		}
+
+		entry := XrefEntry{Group: field[:idx]}
+		if entry.Number, err = strconv.ParseUint(field[idx+1:], 10, 64); err != nil {
+			return xref, fmt.Errorf("%w: invalid article number in entry '%s'", ErrInvalidXref, field)
+		}
+
+		xref.Entries = append(xref.Entries, entry)
+	}
+
+	return xref, nil
+}
+
+// Number returns the article number within the group.
+func (x Xref) Number(group string) (uint64, bool) {
+	for _, entry := range x.Entries {
+		if entry.Group == group {
+			return entry.Number, true
+		}
+	}
+
+	return 0, false
+}
+
+// Xref parses the Xref field of the overview data, which is available if the overview format contains "Xref:full".
+// ok is false if the field is missing or invalid.
+func (h Header) Xref() (xref Xref, ok bool) {
+	for name, value := range h.Additional {
+		if !strings.EqualFold(name, "Xref") {
+			continue
+		}
+
+		parsed, err := ParseXref(value)
+
+		return parsed, err == nil
+	}
+
+	return xref, false
+}
+
+// CrossPost is a single article, which may be posted to multiple groups.
+type CrossPost struct {
+	// Header as seen first
+	Header Header
+	// Server which assigned the article numbers
+	Server string
+	// Article numbers of all groups the article is known in
+	Entries []XrefEntry
+}
+
+// Number returns the article number within the group.
+func (c *CrossPost) Number(group string) (uint64, bool) {
+	return Xref{Entries: c.Entries}.Number(group)
+}
+
+func (c *CrossPost) copy() *CrossPost {
+	copied := *c
+	copied.Entries = append([]XrefEntry(nil), c.Entries...)
+
+	return &copied
+}
+
+func (c *CrossPost) addEntry(entry XrefEntry) bool {
+	if _, ok := c.Number(entry.Group); ok {
+		return false
+	}
+
+	c.Entries = append(c.Entries, entry)
+
+	return true
+}
+
+// CrossPostIndex deduplicates the overview headers of several groups of a single server.
+// Articles are identified by their message-id. The Xref field is used to map article numbers between groups, so a
+// cross-posted article is known in all its groups as soon as it has been seen in one of them.
+// All methods are safe for concurrent use.
+type CrossPostIndex struct {
+	lock     sync.Mutex
+	articles []*CrossPost
+	ids      map[string]*CrossPost
+	numbers  map[XrefEntry]*CrossPost
+}
+
+func NewCrossPostIndex() *CrossPostIndex {
+	return &CrossPostIndex{
+		ids:     map[string]*CrossPost{},
+		numbers: map[XrefEntry]*CrossPost{},
+	}
+}
+
+// Add indexes the headers retrieved from group & returns the ones which have not been seen before in any group.
+func (i *CrossPostIndex) Add(group string, headers ...Header) []Header {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	var added []Header
+
+	for idx := range headers {
+		if i.add(group, headers[idx]) {
+			added = append(added, headers[idx])
+		}
+	}
+
+	return added
+}
+
+func (i *CrossPostIndex) add(group string, header Header) bool {
+	entries := []XrefEntry{{Group: group, Number: header.MessageNumber}}
+
+	xref, hasXref := header.Xref()
+	if hasXref {
+		entries = append(entries, xref.Entries...)
+	}
+
+	article, known := i.ids[header.MessageID]
+	if !known || header.MessageID == "" {
+		// Articles without message-id can still be identified by their article numbers
+		article, known = i.numbers[entries[0]]
+	}
+
+	if !known {
+		article = &CrossPost{Header: header}
+		i.articles = append(i.articles, article)
+	}
+
+	if article.Server == "" && hasXref {
+		article.Server = xref.Server
+	}
+
+	if header.MessageID != "" {
+		i.ids[header.MessageID] = article
+	}
+
+	for _, entry := range entries {
+		if article.addEntry(entry) {
+			i.numbers[entry] = article
+		}
+	}
+
+	return !known
+}
+
+// Len returns the number of unique articles.
+func (i *CrossPostIndex) Len() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return len(i.articles)
+}
+
+// Articles returns copies of all unique articles in the order they have been added, so later additions don't change
+// them.
+func (i *CrossPostIndex) Articles() []*CrossPost {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	articles := make([]*CrossPost, len(i.articles))
+	for idx, article := range i.articles {
+		articles[idx] = article.copy()
+	}
+
+	return articles
+}
+
+// Lookup returns a copy of the article with the given number within group.
+func (i *CrossPostIndex) Lookup(group string, number uint64) (*CrossPost, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	article, ok := i.numbers[XrefEntry{Group: group, Number: number}]
+	if !ok {
+		return nil, false
+	}
+
+	return article.copy(), true
+}
+
+// Map returns the number within the group to of the article with the given number within the group from.
+func (i *CrossPostIndex) Map(from string, number uint64, to string) (uint64, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	article, ok := i.numbers[XrefEntry{Group: from, Number: number}]
+	if !ok {
+		return 0, false
+	}
+
+	return article.Number(to)
+}
@@ -0,0 +1,139 @@
+package nntp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp"
+)
+
+func TestParseXref(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    nntp.Xref
+		expectedErr error
+	}{
+		{
+			value: "news.example.com misc.test:3000234 alt.test:42",
+			expected: nntp.Xref{
+				Server:  "news.example.com",
+				Entries: []nntp.XrefEntry{{Group: "misc.test", Number: 3000234}, {Group: "alt.test", Number: 42}},
+			},
+		},
+		{
+			value: "Xref: news.example.com  misc.test:1\t",
+			expected: nntp.Xref{
+				Server:  "news.example.com",
+				Entries: []nntp.XrefEntry{{Group: "misc.test", Number: 1}},
+			},
+		},
+		{
+			value:       "news.example.com",
+			expected:    nntp.Xref{},
+			expectedErr: nntp.ErrInvalidXref,
+		},
+		{
+			value:       "news.example.com misc.test:abc",
+			expected:    nntp.Xref{Server: "news.example.com"},
+			expectedErr: nntp.ErrInvalidXref,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			xref, err := nntp.ParseXref(test.value)
+			assert.Equal(t, test.expected, xref)
+
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr), "Expected %v, got %v", test.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHeader_Xref(t *testing.T) {
+	header := nntp.Header{Additional: map[string]string{"Xref": "news.example.com a.b:1 c.d:2"}}
+
+	xref, ok := header.Xref()
+	require.True(t, ok)
+
+	number, ok := xref.Number("c.d")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), number)
+
+	_, ok = nntp.Header{}.Xref()
+	assert.False(t, ok)
+}
+
+func TestCrossPostIndex(t *testing.T) {
+	xref := func(value string) map[string]string {
+		return map[string]string{"Xref": value}
+	}
+
+	index := nntp.NewCrossPostIndex()
+
+	added := index.Add("a.b",
+		nntp.Header{MessageNumber: 1, MessageID: "<1@example.com>", Additional: xref("news.example.com a.b:1 c.d:10 e.f:100")},
+		nntp.Header{MessageNumber: 2, MessageID: "<2@example.com>", Additional: xref("news.example.com a.b:2")},
+	)
+	assert.Len(t, added, 2)
+
+	// The cross-posted article shows up again in c.d, the other article has no Xref at all
+	added = index.Add("c.d",
+		nntp.Header{MessageNumber: 10, MessageID: "<1@example.com>", Additional: xref("news.example.com a.b:1 c.d:10 e.f:100")},
+		nntp.Header{MessageNumber: 11, MessageID: "<3@example.com>"},
+	)
+	require.Len(t, added, 1)
+	assert.Equal(t, "<3@example.com>", added[0].MessageID)
+
+	// Articles without message-id are identified by their number
+	added = index.Add("c.d", nntp.Header{MessageNumber: 11})
+	assert.Empty(t, added)
+
+	assert.Equal(t, 3, index.Len())
+
+	number, ok := index.Map("c.d", 10, "e.f")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(100), number)
+
+	_, ok = index.Map("a.b", 2, "c.d")
+	assert.False(t, ok)
+
+	article, ok := index.Lookup("e.f", 100)
+	require.True(t, ok)
+	assert.Equal(t, "<1@example.com>", article.Header.MessageID)
+	assert.Equal(t, "news.example.com", article.Server)
+	assert.Equal(t, []nntp.XrefEntry{{Group: "a.b", Number: 1}, {Group: "c.d", Number: 10}, {Group: "e.f", Number: 100}}, article.Entries)
+
+	articles := index.Articles()
+	require.Len(t, articles, 3)
+	assert.Equal(t, "<3@example.com>", articles[2].Header.MessageID)
+}
+
+func TestCrossPostIndex_Copies(t *testing.T) {
+	index := nntp.NewCrossPostIndex()
+	index.Add("a.b", nntp.Header{MessageNumber: 1, MessageID: "<1@example.com>"})
+
+	articles := index.Articles()
+	require.Len(t, articles, 1)
+
+	article, ok := index.Lookup("a.b", 1)
+	require.True(t, ok)
+
+	// Later additions don't change the returned articles & changes to them don't reach the index
+	index.Add("c.d", nntp.Header{MessageNumber: 10, MessageID: "<1@example.com>"})
+	article.Entries[0].Number = 2
+
+	assert.Equal(t, []nntp.XrefEntry{{Group: "a.b", Number: 1}}, articles[0].Entries)
+	assert.Equal(t, []nntp.XrefEntry{{Group: "a.b", Number: 2}}, article.Entries)
+
+	article, ok = index.Lookup("c.d", 10)
+	require.True(t, ok)
+	assert.Equal(t, []nntp.XrefEntry{{Group: "a.b", Number: 1}, {Group: "c.d", Number: 10}}, article.Entries)
+}
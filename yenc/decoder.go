@@ -0,0 +1,486 @@
+// Package yenc implements the yEnc binary encoding used for posting binaries to Usenet.
+package yenc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// Header contains the metadata of the =ybegin & =ypart lines.
+type Header struct {
+	Name string
+	// Size of the whole file
+	Size int64
+	Line int
+	// Part & Total are 0 for single part posts
+	Part  int
+	Total int
+	// Begin & End are the 1-based, inclusive offsets of this part within the whole file. Both are 0 for single part posts.
+	Begin int64
+	End   int64
+}
+
+// MultiPart reports whether the header belongs to a single part of a multi part post.
+func (h Header) MultiPart() bool {
+	return h.Part > 0
+}
+
+// PartSize returns the expected number of decoded bytes in this part.
+func (h Header) PartSize() int64 {
+	if h.Begin > 0 && h.End >= h.Begin {
+		return h.End - h.Begin + 1
+	}
+
+	return h.Size
+}
+
+// Offset returns the zero based offset of this part within the whole file.
+func (h Header) Offset() int64 {
+	if h.Begin > 0 {
+		return h.Begin - 1
+	}
+
+	return 0
+}
+
+// Trailer contains the metadata of the =yend line.
+type Trailer struct {
+	Size         int64
+	Part         int
+	PartCRC32    uint32
+	HasPartCRC32 bool
+	// CRC32 of the whole file
+	CRC32    uint32
+	HasCRC32 bool
+}
+
+var (
+	ErrNoHeader      = errors.New("no =ybegin line found")
+	ErrNoTrailer     = errors.New("no =yend line found")
+	ErrInvalidHeader = errors.New("invalid yEnc keyword line")
+	ErrSizeMismatch  = errors.New("size mismatch")
+	ErrCRCMismatch   = errors.New("crc32 mismatch")
+)
+
+// SizeError gets returned when the amount of decoded data doesn't match the size announced in the header or trailer.
+type SizeError struct {
+	// The keyword line announcing the size
+	Source   string
+	Expected int64
+	Actual   int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%v: %s announced %d bytes, decoded %d bytes", ErrSizeMismatch, e.Source, e.Expected, e.Actual)
+}
+
+func (e *SizeError) Unwrap() error {
+	return ErrSizeMismatch
+}
+
+// CRCError gets returned when the checksum of the decoded data doesn't match the checksum of the trailer.
+type CRCError struct {
+	// Either "pcrc32" or "crc32"
+	Field    string
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("%v: %s expected %08x, got %08x", ErrCRCMismatch, e.Field, e.Expected, e.Actual)
+}
+
+func (e *CRCError) Unwrap() error {
+	return ErrCRCMismatch
+}
+
+// Decoder is an io.Reader which decodes the first yEnc block of an article body.
+// Lines before the =ybegin line and everything after the =yend line are ignored.
+// Read returns io.EOF only after the decoded data has been verified against the =yend line.
+type Decoder struct {
+	// DotStuffed must be set when the input still contains the doubled leading dots of the NNTP transport.
+	DotStuffed bool
+
+	lines *bufio.Scanner
+
+	header  Header
+	trailer Trailer
+
+	started bool
+	done    bool
+	err     error
+
+	// Decoded but not yet read data
+	pending []byte
+	buf     []byte
+
+	crc  hash.Hash32
+	size int64
+}
+
+// Lines are usually 128 or 256 characters long. Anything longer than this is most likely not yEnc encoded at all.
+const maxLineLength = 1 << 20
+
+func NewDecoder(r io.Reader) *Decoder {
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 4096), maxLineLength)
+	lines.Split(splitLines)
+
+	return &Decoder{
+		lines: lines,
+		crc:   crc32.NewIEEE(),
+	}
+}
+
+// Header reads the input up to the beginning of the encoded data & returns the =ybegin & =ypart metadata.
+func (d *Decoder) Header() (Header, error) {
+	if !d.started && d.err == nil {
+		d.err = d.readHeader()
+	}
+
+	if !d.started {
+		return d.header, d.err
+	}
+
+	return d.header, nil
+}
+
+// Trailer returns the =yend metadata. It is only set after Read returned io.EOF.
+func (d *Decoder) Trailer() Trailer {
+	return d.trailer
+}
+
+func (d *Decoder) Read(p []byte) (int, error) {
+	if !d.started && d.err == nil {
+		d.err = d.readHeader()
+	}
+
+	for len(d.pending) == 0 && d.err == nil {
+		d.err = d.decodeLine()
+	}
+
+	if len(d.pending) > 0 {
+		n := copy(p, d.pending)
+		d.pending = d.pending[n:]
+
+		return n, nil
+	}
+
+	return 0, d.err
+}
+
+// readLine returns the next non empty line without line endings. Both CR & LF count as line endings, as both are
+// always escaped within yEnc data.
+func (d *Decoder) readLine() ([]byte, error) {
+	for d.lines.Scan() {
+		line := d.lines.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		if d.DotStuffed && bytes.HasPrefix(line, []byte("..")) {
+			line = line[1:]
+		}
+
+		return line, nil
+	}
+
+	if err := d.lines.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+func splitLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if idx := bytes.IndexAny(data, "\r\n"); idx >= 0 {
+		return idx + 1, data[:idx], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+func (d *Decoder) readHeader() error {
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return ErrNoHeader
+			}
+
+			return err
+		}
+
+		if bytes.HasPrefix(line, []byte("=ybegin ")) {
+			if d.header, err = parseBegin(line); err != nil {
+				return err
+			}
+
+			break
+		}
+	}
+
+	if d.header.MultiPart() {
+		line, err := d.readLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+
+			return err
+		}
+
+		if !bytes.HasPrefix(line, []byte("=ypart ")) {
+			return fmt.Errorf("%w: expected =ypart line after =ybegin of a multi part post", ErrInvalidHeader)
+		}
+
+		if err := parsePart(line, &d.header); err != nil {
+			return err
+		}
+	}
+
+	d.started = true
+
+	return nil
+}
+
+func (d *Decoder) decodeLine() error {
+	line, err := d.readLine()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return ErrNoTrailer
+		}
+
+		return err
+	}
+
+	if bytes.HasPrefix(line, []byte("=yend")) {
+		if d.trailer, err = parseEnd(line); err != nil {
+			return err
+		}
+
+		return d.verify()
+	}
+
+	d.buf = decode(d.buf[:0], line)
+	d.pending = d.buf
+
+	_, _ = d.crc.Write(d.buf)
+	d.size += int64(len(d.buf))
+
+	return nil
+}
+
+func decode(dst, line []byte) []byte {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		if c == '=' {
+			i++
+			if i == len(line) {
+				// A trailing escape character without following character is invalid. Ignore it.
+				break
+			}
+
+			c = line[i] - 64
+		}
+
+		dst = append(dst, c-42)
+	}
+
+	return dst
+}
+
+func (d *Decoder) verify() error {
+	if d.trailer.Size != d.size {
+		return &SizeError{Source: "=yend", Expected: d.trailer.Size, Actual: d.size}
+	}
+
+	if expected := d.header.PartSize(); expected != d.size {
+		source := "=ybegin"
+		if d.header.MultiPart() {
+			source = "=ypart"
+		}
+
+		return &SizeError{Source: source, Expected: expected, Actual: d.size}
+	}
+
+	actual := d.crc.Sum32()
+
+	if d.trailer.HasPartCRC32 && d.trailer.PartCRC32 != actual {
+		return &CRCError{Field: "pcrc32", Expected: d.trailer.PartCRC32, Actual: actual}
+	}
+
+	// For multi part posts, crc32 covers the whole file & can't be verified by a single part.
+	if !d.header.MultiPart() && d.trailer.HasCRC32 && d.trailer.CRC32 != actual {
+		return &CRCError{Field: "crc32", Expected: d.trailer.CRC32, Actual: actual}
+	}
+
+	return io.EOF
+}
+
+// keywords splits a keyword line into its key=value pairs.
+// The value of "name" always extends to the end of the line, as file names may contain spaces.
+func keywords(line []byte) map[string]string {
+	values := map[string]string{}
+
+	// Skip the keyword itself
+	idx := bytes.IndexByte(line, ' ')
+	if idx < 0 {
+		return values
+	}
+
+	rest := line[idx+1:]
+
+	for len(rest) > 0 {
+		rest = bytes.TrimLeft(rest, " ")
+
+		if bytes.HasPrefix(rest, []byte("name=")) {
+			values["name"] = string(bytes.TrimRight(rest[len("name="):], " \t"))
+			break
+		}
+
+		token := rest
+		if idx := bytes.IndexByte(rest, ' '); idx >= 0 {
+			token, rest = rest[:idx], rest[idx+1:]
+		} else {
+			rest = nil
+		}
+
+		if eq := bytes.IndexByte(token, '='); eq > 0 {
+			values[string(token[:eq])] = string(token[eq+1:])
+		}
+	}
+
+	return values
+}
+
+func parseBegin(line []byte) (header Header, err error) {
+	values := keywords(line)
+
+	name, ok := values["name"]
+	if !ok {
+		return header, fmt.Errorf("%w: =ybegin without name", ErrInvalidHeader)
+	}
+
+	header.Name = name
+
+	if header.Size, err = parseInt(values, "size", true); err != nil {
+		return header, err
+	}
+
+	line64, err := parseInt(values, "line", false)
+	if err != nil {
+		return header, err
+	}
+
+	part, err := parseInt(values, "part", false)
+	if err != nil {
+		return header, err
+	}
+
+	total, err := parseInt(values, "total", false)
+	if err != nil {
+		return header, err
+	}
+
+	header.Line, header.Part, header.Total = int(line64), int(part), int(total)
+
+	return header, nil
+}
+
+func parsePart(line []byte, header *Header) (err error) {
+	values := keywords(line)
+
+	if header.Begin, err = parseInt(values, "begin", true); err != nil {
+		return err
+	}
+
+	if header.End, err = parseInt(values, "end", true); err != nil {
+		return err
+	}
+
+	if header.Begin < 1 || header.End < header.Begin-1 {
+		return fmt.Errorf("%w: invalid =ypart range %d-%d", ErrInvalidHeader, header.Begin, header.End)
+	}
+
+	return nil
+}
+
+func parseEnd(line []byte) (trailer Trailer, err error) {
+	values := keywords(line)
+
+	if trailer.Size, err = parseInt(values, "size", true); err != nil {
+		return trailer, err
+	}
+
+	part, err := parseInt(values, "part", false)
+	if err != nil {
+		return trailer, err
+	}
+
+	trailer.Part = int(part)
+
+	if trailer.PartCRC32, trailer.HasPartCRC32, err = parseCRC(values, "pcrc32"); err != nil {
+		return trailer, err
+	}
+
+	if trailer.CRC32, trailer.HasCRC32, err = parseCRC(values, "crc32"); err != nil {
+		return trailer, err
+	}
+
+	return trailer, nil
+}
+
+func parseInt(values map[string]string, key string, required bool) (int64, error) {
+	s, ok := values[key]
+	if !ok {
+		if required {
+			return 0, fmt.Errorf("%w: missing '%s'", ErrInvalidHeader, key)
+		}
+
+		return 0, nil
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to parse '%s' value '%s': %v", ErrInvalidHeader, key, s, err)
+	}
+
+	return i, nil
+}
+
+func parseCRC(values map[string]string, key string) (uint32, bool, error) {
+	s, ok := values[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	// Some encoders don't pad the checksum or prefix it with 0x
+	s = trimHexPrefix(s)
+
+	crc, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: failed to parse '%s' value '%s': %v", ErrInvalidHeader, key, s, err)
+	}
+
+	return uint32(crc), true, nil
+}
+
+func trimHexPrefix(s string) string {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+
+	return s
+}
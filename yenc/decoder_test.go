@@ -0,0 +1,189 @@
+package yenc_test
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+// encodeLines is a minimal yEnc encoder used to build test fixtures.
+func encodeLines(data []byte, lineLength int) []string {
+	var (
+		lines []string
+		b     strings.Builder
+	)
+
+	for _, c := range data {
+		e := c + 42
+		switch e {
+		case 0x00, '\n', '\r', '=':
+			b.WriteByte('=')
+			e += 64
+		case '.':
+			if b.Len() == 0 {
+				b.WriteByte('=')
+				e += 64
+			}
+		}
+
+		b.WriteByte(e)
+
+		if b.Len() >= lineLength {
+			lines = append(lines, b.String())
+			b.Reset()
+		}
+	}
+
+	if b.Len() > 0 {
+		lines = append(lines, b.String())
+	}
+
+	return lines
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	return data
+}
+
+func singlePart(name string, data []byte, crc uint32) string {
+	lines := []string{
+		"Some article text before the encoded data",
+		fmt.Sprintf("=ybegin line=32 size=%d name=%s", len(data), name),
+	}
+	lines = append(lines, encodeLines(data, 32)...)
+	lines = append(lines, fmt.Sprintf("=yend size=%d crc32=%08x", len(data), crc))
+
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestDecoder_SinglePart(t *testing.T) {
+	data := testData(1000)
+	body := singlePart("some file.bin", data, crc32.ChecksumIEEE(data))
+
+	d := yenc.NewDecoder(strings.NewReader(body))
+
+	header, err := d.Header()
+	require.NoError(t, err, "Failed to read header")
+	assert.Equal(t, yenc.Header{Name: "some file.bin", Size: 1000, Line: 32}, header)
+
+	decoded, err := ioutil.ReadAll(d)
+	require.NoError(t, err, "Failed to decode")
+	assert.Equal(t, data, decoded)
+
+	trailer := d.Trailer()
+	assert.True(t, trailer.HasCRC32)
+	assert.False(t, trailer.HasPartCRC32)
+	assert.Equal(t, crc32.ChecksumIEEE(data), trailer.CRC32)
+}
+
+func TestDecoder_MultiPart(t *testing.T) {
+	data := testData(500)
+	part := data[100:300]
+
+	lines := []string{
+		"=ybegin part=2 total=3 line=64 size=500 name=file.bin",
+		"=ypart begin=101 end=300",
+	}
+	lines = append(lines, encodeLines(part, 64)...)
+	lines = append(lines, fmt.Sprintf("=yend size=200 part=2 pcrc32=%08x crc32=%08x", crc32.ChecksumIEEE(part), crc32.ChecksumIEEE(data)))
+
+	d := yenc.NewDecoder(strings.NewReader(strings.Join(lines, "\n")))
+
+	decoded, err := ioutil.ReadAll(d)
+	require.NoError(t, err, "Failed to decode")
+	assert.Equal(t, part, decoded)
+
+	header, err := d.Header()
+	require.NoError(t, err, "Failed to get header")
+	assert.Equal(t, yenc.Header{Name: "file.bin", Size: 500, Line: 64, Part: 2, Total: 3, Begin: 101, End: 300}, header)
+	assert.True(t, header.MultiPart())
+	assert.Equal(t, int64(100), header.Offset())
+	assert.Equal(t, int64(200), header.PartSize())
+
+	assert.Equal(t, 2, d.Trailer().Part)
+}
+
+func TestDecoder_LineEndings(t *testing.T) {
+	data := testData(300)
+	body := singlePart("file.bin", data, crc32.ChecksumIEEE(data))
+
+	for name, ending := range map[string]string{"LF": "\n", "CR": "\r", "CRLF": "\r\n", "CRCRLF": "\r\r\n"} {
+		ending := ending
+		t.Run(name, func(t *testing.T) {
+			d := yenc.NewDecoder(strings.NewReader(strings.ReplaceAll(body, "\r\n", ending)))
+
+			decoded, err := ioutil.ReadAll(d)
+			require.NoError(t, err, "Failed to decode")
+			assert.Equal(t, data, decoded)
+		})
+	}
+}
+
+func TestDecoder_DotStuffed(t *testing.T) {
+	// 0x04 encodes to a dot. Unescaped leading dots get doubled by the NNTP transport.
+	data := []byte{0x04, 0x04, 0x05}
+	body := fmt.Sprintf("=ybegin line=128 size=3 name=dots\r\n.../\r\n=yend size=3 crc32=%08x\r\n", crc32.ChecksumIEEE(data))
+
+	d := yenc.NewDecoder(strings.NewReader(body))
+	d.DotStuffed = true
+
+	decoded, err := ioutil.ReadAll(d)
+	require.NoError(t, err, "Failed to decode")
+	assert.Equal(t, data, decoded)
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	data := testData(100)
+
+	t.Run("crc mismatch", func(t *testing.T) {
+		_, err := ioutil.ReadAll(yenc.NewDecoder(strings.NewReader(singlePart("f", data, 0xdeadbeef))))
+
+		var crcErr *yenc.CRCError
+		require.True(t, errors.As(err, &crcErr), "Expected %T, got %v", crcErr, err)
+		assert.True(t, errors.Is(err, yenc.ErrCRCMismatch))
+		assert.Equal(t, uint32(0xdeadbeef), crcErr.Expected)
+		assert.Equal(t, crc32.ChecksumIEEE(data), crcErr.Actual)
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		body := strings.Replace(singlePart("f", data, crc32.ChecksumIEEE(data)), "=yend size=100", "=yend size=99", 1)
+		_, err := ioutil.ReadAll(yenc.NewDecoder(strings.NewReader(body)))
+
+		var sizeErr *yenc.SizeError
+		require.True(t, errors.As(err, &sizeErr), "Expected %T, got %v", sizeErr, err)
+		assert.True(t, errors.Is(err, yenc.ErrSizeMismatch))
+		assert.Equal(t, int64(99), sizeErr.Expected)
+		assert.Equal(t, int64(100), sizeErr.Actual)
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		_, err := ioutil.ReadAll(yenc.NewDecoder(strings.NewReader("just some text\r\n")))
+		assert.True(t, errors.Is(err, yenc.ErrNoHeader), "Expected %v, got %v", yenc.ErrNoHeader, err)
+	})
+
+	t.Run("missing trailer", func(t *testing.T) {
+		body := singlePart("f", data, crc32.ChecksumIEEE(data))
+		body = body[:strings.Index(body, "=yend")]
+
+		_, err := ioutil.ReadAll(yenc.NewDecoder(strings.NewReader(body)))
+		assert.True(t, errors.Is(err, yenc.ErrNoTrailer), "Expected %v, got %v", yenc.ErrNoTrailer, err)
+	})
+
+	t.Run("missing ypart", func(t *testing.T) {
+		_, err := ioutil.ReadAll(yenc.NewDecoder(strings.NewReader("=ybegin part=1 line=128 size=3 name=f\r\nabc\r\n")))
+		assert.True(t, errors.Is(err, yenc.ErrInvalidHeader), "Expected %v, got %v", yenc.ErrInvalidHeader, err)
+	})
+}
@@ -0,0 +1,223 @@
+package yenc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	DefaultLineLength = 128
+	// DefaultPartSize is the usual amount of raw data per article
+	DefaultPartSize = 716800
+)
+
+// Part is a single encoded article body.
+type Part struct {
+	// Number & Total are 1-based. Both are 1 for single part encodings.
+	Number int
+	Total  int
+	// Begin & End are the 1-based, inclusive offsets of this part within the whole file
+	Begin int64
+	End   int64
+	// CRC32 of the raw data of this part
+	CRC32 uint32
+	// Body contains the complete yEnc block including the keyword lines. Lines are terminated by CRLF & leading dots
+	// are escaped, so the body can be posted without further dot-stuffing.
+	Body []byte
+}
+
+var ErrInvalidSize = errors.New("invalid size")
+
+// Encoder splits the data of a reader into yEnc encoded parts.
+// The fields must be set before the first call to Next.
+type Encoder struct {
+	Name string
+	// Size is the exact number of bytes r will return. It is required upfront, as every =ybegin line contains it.
+	Size int64
+	// PartSize is the maximum amount of raw data per part. If the whole file fits into one part, a single part
+	// encoding without =ypart line is produced.
+	PartSize int64
+	// LineLength is the number of encoded characters per line. Escaped characters may exceed it by one.
+	LineLength int
+
+	r io.Reader
+
+	next   int
+	offset int64
+	crc    uint32
+	buf    []byte
+}
+
+func NewEncoder(r io.Reader, name string, size int64) *Encoder {
+	return &Encoder{
+		Name:       name,
+		Size:       size,
+		PartSize:   DefaultPartSize,
+		LineLength: DefaultLineLength,
+		r:          r,
+	}
+}
+
+// Total returns the number of parts the encoder produces.
+func (e *Encoder) Total() int {
+	if e.PartSize <= 0 || e.Size <= e.PartSize {
+		return 1
+	}
+
+	return int((e.Size + e.PartSize - 1) / e.PartSize)
+}
+
+// CRC32 returns the checksum of the whole file. It is complete after Next returned io.EOF.
+func (e *Encoder) CRC32() uint32 {
+	return e.crc
+}
+
+// Next reads & encodes the next part. It returns io.EOF after the last part.
+func (e *Encoder) Next() (Part, error) {
+	if e.Size < 0 {
+		return Part{}, fmt.Errorf("%w: %d", ErrInvalidSize, e.Size)
+	}
+
+	total := e.Total()
+	if e.next >= total {
+		return Part{}, io.EOF
+	}
+
+	partSize := e.Size
+	if total > 1 {
+		partSize = e.PartSize
+		if remaining := e.Size - e.offset; remaining < partSize {
+			partSize = remaining
+		}
+	}
+
+	if int64(cap(e.buf)) < partSize {
+		e.buf = make([]byte, partSize)
+	}
+
+	data := e.buf[:partSize]
+
+	if n, err := io.ReadFull(e.r, data); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Part{}, &SizeError{Source: "input", Expected: e.Size, Actual: e.offset + int64(n)}
+		}
+
+		return Part{}, err
+	}
+
+	e.next++
+
+	part := Part{
+		Number: e.next,
+		Total:  total,
+		Begin:  e.offset + 1,
+		End:    e.offset + partSize,
+		CRC32:  crc32.ChecksumIEEE(data),
+	}
+
+	e.offset += partSize
+	e.crc = crc32.Update(e.crc, crc32.IEEETable, data)
+
+	if e.next == total {
+		if err := e.checkExhausted(); err != nil {
+			return Part{}, err
+		}
+	}
+
+	part.Body = e.encodePart(part, data)
+
+	return part, nil
+}
+
+// checkExhausted makes sure the reader doesn't contain more data than announced.
+func (e *Encoder) checkExhausted() error {
+	var b [1]byte
+
+	n, err := e.r.Read(b[:])
+	for n == 0 && err == nil {
+		n, err = e.r.Read(b[:])
+	}
+
+	if n > 0 {
+		// The reader contains at least one byte more than announced
+		return &SizeError{Source: "input", Expected: e.Size, Actual: e.Size + 1}
+	}
+
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodePart(part Part, data []byte) []byte {
+	lineLength := e.LineLength
+	if lineLength <= 0 {
+		lineLength = DefaultLineLength
+	}
+
+	b := bytes.NewBuffer(make([]byte, 0, len(data)+len(data)/32+256))
+
+	if part.Total > 1 {
+		fmt.Fprintf(b, "=ybegin part=%d total=%d line=%d size=%d name=%s\r\n", part.Number, part.Total, lineLength, e.Size, e.Name)
+		fmt.Fprintf(b, "=ypart begin=%d end=%d\r\n", part.Begin, part.End)
+	} else {
+		fmt.Fprintf(b, "=ybegin line=%d size=%d name=%s\r\n", lineLength, e.Size, e.Name)
+	}
+
+	b.Write(encode(nil, data, lineLength))
+
+	switch {
+	case part.Total == 1:
+		fmt.Fprintf(b, "=yend size=%d crc32=%08x\r\n", len(data), part.CRC32)
+	case part.Number == part.Total:
+		fmt.Fprintf(b, "=yend size=%d part=%d pcrc32=%08x crc32=%08x\r\n", len(data), part.Number, part.CRC32, e.crc)
+	default:
+		fmt.Fprintf(b, "=yend size=%d part=%d pcrc32=%08x\r\n", len(data), part.Number, part.CRC32)
+	}
+
+	return b.Bytes()
+}
+
+// encode appends the encoded lines of data to dst. Every line is terminated by CRLF.
+func encode(dst, data []byte, lineLength int) []byte {
+	col := 0
+
+	for i, c := range data {
+		c += 42
+
+		var escape bool
+
+		switch c {
+		case 0x00, '\n', '\r', '=':
+			escape = true
+		case '.':
+			escape = col == 0
+		case ' ', '\t':
+			// Whitespace at the beginning or end of a line may get stripped in transit
+			escape = col == 0 || col+1 >= lineLength || i == len(data)-1
+		}
+
+		if escape {
+			dst = append(dst, '=', c+64)
+			col += 2
+		} else {
+			dst = append(dst, c)
+			col++
+		}
+
+		if col >= lineLength {
+			dst = append(dst, '\r', '\n')
+			col = 0
+		}
+	}
+
+	if col > 0 {
+		dst = append(dst, '\r', '\n')
+	}
+
+	return dst
+}
@@ -0,0 +1,122 @@
+package yenc_test
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mrincompetent/nntp/yenc"
+)
+
+func encodeAll(t testing.TB, e *yenc.Encoder) []yenc.Part {
+	var parts []yenc.Part
+
+	for {
+		part, err := e.Next()
+		if errors.Is(err, io.EOF) {
+			return parts
+		}
+
+		require.NoError(t, err, "Failed to encode part")
+
+		parts = append(parts, part)
+	}
+}
+
+func TestEncoder_MultiPart(t *testing.T) {
+	data := testData(2500)
+
+	e := yenc.NewEncoder(bytes.NewReader(data), "some file.bin", int64(len(data)))
+	e.PartSize = 1000
+	e.LineLength = 64
+
+	parts := encodeAll(t, e)
+	require.Len(t, parts, 3)
+	assert.Equal(t, crc32.ChecksumIEEE(data), e.CRC32())
+
+	var decoded []byte
+
+	for idx, part := range parts {
+		assert.Equal(t, idx+1, part.Number)
+		assert.Equal(t, 3, part.Total)
+		assert.Equal(t, int64(idx*1000+1), part.Begin)
+
+		d := yenc.NewDecoder(bytes.NewReader(part.Body))
+
+		partData, err := ioutil.ReadAll(d)
+		require.NoError(t, err, "Failed to decode part %d", part.Number)
+
+		header, err := d.Header()
+		require.NoError(t, err, "Failed to get header of part %d", part.Number)
+		assert.Equal(t, yenc.Header{Name: "some file.bin", Size: 2500, Line: 64, Part: part.Number, Total: 3, Begin: part.Begin, End: part.End}, header)
+		assert.Equal(t, part.CRC32, d.Trailer().PartCRC32)
+		assert.Equal(t, part.CRC32, crc32.ChecksumIEEE(partData))
+
+		decoded = append(decoded, partData...)
+	}
+
+	assert.Equal(t, int64(2500), parts[2].End)
+	assert.Equal(t, data, decoded)
+
+	// Only the last part knows the checksum of the whole file
+	assert.NotContains(t, string(parts[0].Body), " crc32=")
+	assert.Contains(t, string(parts[2].Body), " crc32=")
+}
+
+func TestEncoder_SinglePart(t *testing.T) {
+	data := testData(100)
+
+	parts := encodeAll(t, yenc.NewEncoder(bytes.NewReader(data), "file.bin", int64(len(data))))
+	require.Len(t, parts, 1)
+
+	assert.True(t, strings.HasPrefix(string(parts[0].Body), "=ybegin line=128 size=100 name=file.bin\r\n"))
+
+	decoded, err := ioutil.ReadAll(yenc.NewDecoder(bytes.NewReader(parts[0].Body)))
+	require.NoError(t, err, "Failed to decode")
+	assert.Equal(t, data, decoded)
+}
+
+func TestEncoder_Escaping(t *testing.T) {
+	// Raw bytes which encode to '.', ' ', '\t', '=', NUL, LF & CR
+	data := []byte{0x04, 0xf6, 0xdf, 0x13, 0xd6, 0xe0, 0xe3, 0xf6, 0x04, 0x04, 0xdf}
+
+	e := yenc.NewEncoder(bytes.NewReader(data), "f", int64(len(data)))
+	e.LineLength = 8
+
+	parts := encodeAll(t, e)
+	require.Len(t, parts, 1)
+
+	assert.Equal(t, []string{
+		"=ybegin line=8 size=11 name=f",
+		// Leading dot is escaped, whitespace in the middle of a line isn't
+		"=n \t=}=@",
+		// Trailing tab is escaped
+		"=J=M ..=I",
+		"=yend size=11 crc32=98a1fd84",
+	}, strings.Split(strings.TrimSuffix(string(parts[0].Body), "\r\n"), "\r\n"))
+
+	decoded, err := ioutil.ReadAll(yenc.NewDecoder(bytes.NewReader(parts[0].Body)))
+	require.NoError(t, err, "Failed to decode")
+	assert.Equal(t, data, decoded)
+}
+
+func TestEncoder_SizeMismatch(t *testing.T) {
+	data := testData(100)
+
+	t.Run("short input", func(t *testing.T) {
+		_, err := yenc.NewEncoder(bytes.NewReader(data), "f", 101).Next()
+		assert.True(t, errors.Is(err, yenc.ErrSizeMismatch), "Expected %v, got %v", yenc.ErrSizeMismatch, err)
+	})
+
+	t.Run("long input", func(t *testing.T) {
+		_, err := yenc.NewEncoder(bytes.NewReader(data), "f", 99).Next()
+		assert.True(t, errors.Is(err, yenc.ErrSizeMismatch), "Expected %v, got %v", yenc.ErrSizeMismatch, err)
+	})
+}